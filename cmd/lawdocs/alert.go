@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/redis/go-redis/v9"
+	"github.com/wilbyang/law-docs/internal/alerting"
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/lifecycle"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/streams"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// alertFlags are the settings of `lawdocs alert run`.
+type alertFlags struct {
+	pipeline, alerts, consumer string
+	dedup                      time.Duration
+
+	failureWindow time.Duration
+	failureRate   float64
+	failureMin    int
+	dlqURL        string
+	dlqMin        float64
+	stuckAfter    time.Duration
+	checkInterval time.Duration
+
+	smtpAddr, smtpFrom, smtpTo, smtpUser string
+	webhookURL, slackURL                 string
+
+	metricsAddr string
+}
+
+func runAlert(ctx context.Context, cfg *config.Config, args []string) error {
+	hostname, _ := os.Hostname()
+	var f alertFlags
+	fs := flag.NewFlagSet("alert", flag.ExitOnError)
+	fs.StringVar(&f.pipeline, "pipeline-stream", "pipeline", "Redis stream of pipeline events to evaluate")
+	fs.StringVar(&f.alerts, "stream", "alerts", "Redis stream the alerts are published to")
+	fs.StringVar(&f.consumer, "consumer", hostname, "consumer name in the stream consumer groups, stable across restarts")
+	fs.DurationVar(&f.dedup, "dedup", time.Hour, "window in which an alert fires only once")
+	fs.DurationVar(&f.failureWindow, "failure-window", 10*time.Minute, "window of the processing failure rate")
+	fs.Float64Var(&f.failureRate, "failure-rate", 0.25, "processing failure rate that fires an alert")
+	fs.IntVar(&f.failureMin, "failure-min", 10, "processed documents in the window before the failure rate is evaluated")
+	fs.StringVar(&f.dlqURL, "dlq-url", "", "SQS dead-letter queue URL to watch, empty to disable")
+	fs.Float64Var(&f.dlqMin, "dlq-min", 1, "dead-letter queue depth from which growth fires an alert")
+	fs.DurationVar(&f.stuckAfter, "stuck-after", 72*time.Hour, "time without an update after which an auditing document is stuck")
+	fs.DurationVar(&f.checkInterval, "check-interval", time.Minute, "interval of the dead-letter queue and stuck document checks")
+	fs.StringVar(&f.smtpAddr, "smtp-addr", "", "SMTP server host:port for alert emails, empty to disable")
+	fs.StringVar(&f.smtpFrom, "smtp-from", "lawdocs@localhost", "sender of alert emails")
+	fs.StringVar(&f.smtpTo, "smtp-to", "", "comma-separated recipients of alert emails")
+	fs.StringVar(&f.smtpUser, "smtp-user", "", "SMTP user, with the password in SMTP_PASSWORD")
+	fs.StringVar(&f.webhookURL, "webhook-url", "", "URL alerts are posted to as JSON, empty to disable")
+	fs.StringVar(&f.slackURL, "slack-url", "", "Slack-compatible incoming webhook URL, empty to disable")
+	fs.StringVar(&f.metricsAddr, "metrics-addr", ":9092", "listen address for the /metrics endpoint, empty to disable")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lawdocs alert [flags] run | silence <rule>[/<key>] <duration> | unsilence <rule>[/<key>]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	state := alerting.NewRedis(client, "lawdocs:alerts:")
+
+	switch fs.Arg(0) {
+	case "run":
+		return runAlerting(ctx, cfg, f, client, state)
+	case "silence", "unsilence":
+		defer client.Close()
+		if fs.NArg() < 2 || (fs.Arg(0) == "silence" && fs.NArg() < 3) {
+			fs.Usage()
+			return fmt.Errorf("%s requires a rule", fs.Arg(0))
+		}
+		rule, key, _ := strings.Cut(fs.Arg(1), "/")
+		var d time.Duration
+		if fs.Arg(0) == "silence" {
+			var err error
+			if d, err = time.ParseDuration(fs.Arg(2)); err != nil || d <= 0 {
+				return fmt.Errorf("invalid silence duration %q", fs.Arg(2))
+			}
+		}
+		if err := state.Silence(ctx, rule, key, d); err != nil {
+			return err
+		}
+		logging.Component("alerting").InfoContext(ctx, "Silence updated", "rule", rule, "key", key, "duration", d)
+		return nil
+	default:
+		client.Close()
+		fs.Usage()
+		return fmt.Errorf("unknown alert action %q", fs.Arg(0))
+	}
+}
+
+func runAlerting(ctx context.Context, cfg *config.Config, f alertFlags, client *redis.Client, state alerting.State) error {
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	store := tenant.NewStore(pool)
+	engine := alerting.NewEngine(state, streams.NewProducer(client, f.alerts, 0), f.dedup,
+		&alerting.FailureRate{Window: f.failureWindow, Threshold: f.failureRate, MinEvents: f.failureMin},
+		&alerting.DLQGrowth{MinDepth: f.dlqMin},
+		alerting.Infected{},
+		alerting.StuckAudit{},
+	)
+
+	m := lifecycle.New(cfg.ShutdownTimeout)
+	m.Add(lifecycle.Component{
+		Name: "redis",
+		Stop: func(context.Context) error { return client.Close() },
+	})
+	m.Add(postgresComponent(pool))
+	if f.metricsAddr != "" {
+		m.Add(metricsServer(f.metricsAddr))
+	}
+	m.Add(streamComponent("pipeline", streams.NewConsumer(client, f.pipeline, streams.Options{
+		Group:      "alerting",
+		Consumer:   f.consumer,
+		DeadLetter: f.pipeline + ":dead",
+	}), engine.Handle))
+	m.Add(lifecycle.Component{
+		Name: "stuck-documents",
+		Run: func(ctx context.Context) error {
+			return engine.WatchStuckAudits(ctx, store, f.stuckAfter, f.checkInterval)
+		},
+	})
+	if f.dlqURL != "" {
+		awsCfg, err := loadAWS(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		sqsClient := sqs.NewFromConfig(awsCfg)
+		m.Add(lifecycle.Component{
+			Name: "dead-letter-queue",
+			Run: func(ctx context.Context) error {
+				return engine.WatchDLQ(ctx, sqsClient, f.dlqURL, f.checkInterval)
+			},
+		})
+	}
+
+	sinks := alertSinks(f)
+	if len(sinks) == 0 {
+		logging.Component("alerting").WarnContext(ctx, "No alert sinks configured, alerts are only published to the stream", "stream", f.alerts)
+	}
+	for _, sink := range sinks {
+		// every sink has its own consumer group, so it retries its deliveries independently
+		m.Add(streamComponent("sink-"+sink.Name(), streams.NewConsumer(client, f.alerts, streams.Options{
+			Group:      "sink-" + sink.Name(),
+			Consumer:   f.consumer,
+			DeadLetter: f.alerts + ":dead",
+		}), alerting.Deliver(sink)))
+	}
+	return m.Run(ctx)
+}
+
+func alertSinks(f alertFlags) []alerting.Sink {
+	var sinks []alerting.Sink
+	if f.smtpAddr != "" {
+		s := &alerting.SMTP{Addr: f.smtpAddr, From: f.smtpFrom, To: splitList(f.smtpTo)}
+		if f.smtpUser != "" {
+			host, _, _ := strings.Cut(f.smtpAddr, ":")
+			s.Auth = smtp.PlainAuth("", f.smtpUser, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		sinks = append(sinks, s)
+	}
+	if f.webhookURL != "" {
+		sinks = append(sinks, &alerting.Webhook{URL: f.webhookURL})
+	}
+	if f.slackURL != "" {
+		sinks = append(sinks, &alerting.Slack{URL: f.slackURL})
+	}
+	return sinks
+}
+
+func streamComponent(name string, c *streams.Consumer, handle streams.Handler) lifecycle.Component {
+	return lifecycle.Component{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			return c.Run(ctx, handle)
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wilbyang/law-docs/internal/cdc"
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/lifecycle"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/streams"
+)
+
+func runCDC(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("cdc", flag.ExitOnError)
+	slot := fs.String("slot", "lawdocs_cdc", "logical replication slot, created on first use")
+	publication := fs.String("publication", "lawdocs_documents", "publication of the documents table")
+	stream := fs.String("stream", "document-changes", "Redis stream the document events are published to")
+	maxLen := fs.Int64("maxlen", streams.DefaultMaxLen, "approximate number of entries the stream is trimmed to when publishing")
+	metricsAddr := fs.String("metrics-addr", ":9093", "listen address for the /metrics endpoint, empty to disable")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lawdocs cdc [flags] run | setup | teardown\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch fs.Arg(0) {
+	case "run":
+	case "setup", "teardown":
+		pool, err := openPool(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		log := logging.Component("cdc")
+		if fs.Arg(0) == "teardown" {
+			if err := cdc.Teardown(ctx, pool, *publication); err != nil {
+				return err
+			}
+			log.InfoContext(ctx, "Dropped publication and reset the replica identity of documents", "publication", *publication)
+			return nil
+		}
+		if err := cdc.Setup(ctx, pool, *publication); err != nil {
+			return err
+		}
+		log.InfoContext(ctx, "Publication ready", "publication", *publication)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown cdc action %q", fs.Arg(0))
+	}
+
+	reader, err := cdc.New(cfg.DatabaseURL, cdc.Options{Slot: *slot, Publication: *publication})
+	if err != nil {
+		return err
+	}
+	client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	handler := cdc.Publisher(streams.NewProducer(client, *stream, *maxLen))
+
+	m := lifecycle.New(cfg.ShutdownTimeout)
+	m.Add(lifecycle.Component{
+		Name: "redis",
+		Stop: func(context.Context) error { return client.Close() },
+	})
+	if *metricsAddr != "" {
+		m.Add(metricsServer(*metricsAddr))
+	}
+	m.Add(lifecycle.Component{
+		Name: "replication",
+		Run: func(ctx context.Context) error {
+			return reader.Run(ctx, handler)
+		},
+	})
+	return m.Run(ctx)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/lifecycle"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/services"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/tracing"
+)
+
+func openPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database url: %w", err)
+	}
+	poolCfg.ConnConfig.Tracer = multitracer.New(metrics.PgxTracer{}, tracing.PgxTracer{})
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+	return pool, nil
+}
+
+func loadAWS(ctx context.Context, cfg *config.Config) (aws.Config, error) {
+	awsCfg, err := cfg.AWS(ctx)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	metrics.InstrumentAWS(&awsCfg)
+	tracing.InstrumentAWS(&awsCfg)
+	return awsCfg, nil
+}
+
+// metricsServer serves /metrics for commands that do not run the API server.
+func metricsServer(addr string) lifecycle.Component {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return lifecycle.HTTPServer("metrics", &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}, "", "")
+}
+
+// postgresComponent verifies the pool can connect on startup and closes it last on shutdown.
+func postgresComponent(pool *pgxpool.Pool) lifecycle.Component {
+	return lifecycle.Component{
+		Name:  "postgres",
+		Start: pool.Ping,
+		Stop: func(context.Context) error {
+			pool.Close()
+			return nil
+		},
+	}
+}
+
+// tenantIsolationComponent warns at startup when the database role ignores row-level security,
+// in which case tenants are not isolated from each other.
+func tenantIsolationComponent(store *tenant.Store) lifecycle.Component {
+	return lifecycle.Component{
+		Name: "tenant-isolation",
+		Start: func(ctx context.Context) error {
+			bypass, err := store.BypassesRLS(ctx)
+			if err != nil {
+				return fmt.Errorf("check row-level security: %w", err)
+			}
+			if bypass {
+				slog.WarnContext(ctx, "Database role bypasses row-level security, tenants are NOT isolated; connect as a role without SUPERUSER or BYPASSRLS")
+			}
+			return nil
+		},
+	}
+}
+
+func newNotifier(ctx context.Context, cfg *config.Config) (*services.Notifier, error) {
+	awsCfg, err := loadAWS(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewNotifier(ctx, awsCfg, cfg.QueueURL)
+}
+
+func newUploader(ctx context.Context, cfg *config.Config) (*services.S3Uploader, error) {
+	awsCfg, err := loadAWS(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewS3Uploader(ctx, awsCfg, cfg.Bucket, func(o *s3.Options) {
+		// LocalStack only serves buckets on path-style URLs.
+		o.UsePathStyle = cfg.AWSEndpoint != ""
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/streams"
+)
+
+func runEvents(ctx context.Context, cfg *config.Config, args []string) error {
+	hostname, _ := os.Hostname()
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	stream := fs.String("stream", "alerts", "Redis stream name")
+	eventType := fs.String("type", "message", "event type to publish")
+	maxLen := fs.Int64("maxlen", streams.DefaultMaxLen, "approximate number of entries the stream is trimmed to when publishing")
+	group := fs.String("group", "lawdocs", "consumer group to listen in")
+	consumer := fs.String("consumer", hostname, "consumer name within the group, stable across restarts")
+	start := fs.String("start", "$", "where a new consumer group starts: $ for new entries, 0 for the whole stream")
+	deadLetter := fs.String("dead-letter", "", "stream for entries that keep failing (default <stream>:dead)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lawdocs events [flags] listen|publish <json or text>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *deadLetter == "" {
+		*deadLetter = *stream + ":dead"
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	defer client.Close()
+	log := logging.Component("events")
+
+	switch fs.Arg(0) {
+	case "listen":
+		c := streams.NewConsumer(client, *stream, streams.Options{
+			Group:      *group,
+			Consumer:   *consumer,
+			Start:      *start,
+			DeadLetter: *deadLetter,
+		})
+		return c.Run(ctx, func(ctx context.Context, e streams.Envelope) error {
+			log.InfoContext(ctx, "Received event", "stream", *stream, "id", e.ID, "type", e.Type, "time", e.Time, "data", string(e.Data))
+			return nil
+		})
+	case "publish":
+		if fs.NArg() < 2 {
+			fs.Usage()
+			return fmt.Errorf("publish requires a message")
+		}
+		// a message that is not JSON is published as a string
+		var data any = fs.Arg(1)
+		if json.Valid([]byte(fs.Arg(1))) {
+			data = json.RawMessage(fs.Arg(1))
+		}
+		id, err := streams.NewProducer(client, *stream, *maxLen).Publish(ctx, *eventType, data)
+		if err != nil {
+			return fmt.Errorf("failed to publish event: %w", err)
+		}
+		log.InfoContext(ctx, "Event published", "stream", *stream, "id", id, "type", *eventType)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown events action %q", fs.Arg(0))
+	}
+}
@@ -0,0 +1,104 @@
+// Command lawdocs is the single entrypoint for every law-docs role.
+// The role is chosen by the first argument, e.g. `lawdocs serve` or `lawdocs process`.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/tracing"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = []command{
+	{name: "serve", summary: "run the HTTP API server", run: runServe},
+	{name: "process", summary: "consume upload notifications from SQS and process documents", run: runProcess},
+	{name: "migrate", summary: "apply or revert database migrations (up, down, status, goto)", run: runMigrate},
+	{name: "relay", summary: "listen for document change notifications from Postgres", run: runRelay},
+	{name: "cdc", summary: "publish document changes from Postgres logical replication to a Redis stream", run: runCDC},
+	{name: "events", summary: "publish or consume events on a Redis stream", run: runEvents},
+	{name: "alert", summary: "evaluate alerting rules on pipeline events and deliver alerts", run: runAlert},
+	{name: "p2p", summary: "send or receive a file over WebRTC, signaling through the websocket hub", run: runP2P},
+	{name: "admin", summary: "administrative tools (run `lawdocs admin` for a list)", run: runAdmin},
+}
+
+// @title Law Docs API
+// @version 1.0
+// @description Upload, organise and audit law documents.
+// @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Send "Bearer <token>": a tenant API token for document routes, or the server's ADMIN_TOKEN for /api/v1/admin.
+func main() {
+	cfg := config.Load()
+	fs := flag.NewFlagSet("lawdocs", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+
+	logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	name, args := fs.Arg(0), fs.Args()[1:]
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := run(ctx, cfg, cmd, args); err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("Command failed", "command", name, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "lawdocs: unknown command %q\n\n", name)
+	fs.Usage()
+	os.Exit(2)
+}
+
+func run(ctx context.Context, cfg *config.Config, cmd command, args []string) error {
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracingExporter, cfg.OTLPEndpoint, "lawdocs-"+cmd.name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// flush buffered spans even though ctx is already cancelled on shutdown
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+	return cmd.run(ctx, cfg, args)
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: lawdocs [global flags] <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nGlobal flags:\n")
+	fs.PrintDefaults()
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/p2p"
+	"github.com/wilbyang/law-docs/internal/signaling"
+)
+
+type p2pFlags struct {
+	url  string
+	room string
+	ice  string
+	dir  string
+}
+
+func runP2P(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("p2p", flag.ExitOnError)
+	var f p2pFlags
+	fs.StringVar(&f.url, "url", "ws://localhost:8080/ws", "websocket hub to signal through")
+	fs.StringVar(&f.room, "room", "", "signaling room shared with the other peer (required)")
+	fs.StringVar(&f.ice, "ice", "", "comma-separated STUN or TURN server URLs, none on one network")
+	fs.StringVar(&f.dir, "dir", ".", "directory to save a received file in")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lawdocs p2p -room <room> [flags] send <file> | recv\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	switch {
+	case fs.Arg(0) == "send" && fs.NArg() == 2:
+		return p2pSend(ctx, f, fs.Arg(1))
+	case fs.Arg(0) == "recv" && fs.NArg() == 1:
+		return p2pRecv(ctx, f)
+	}
+	fs.Usage()
+	return fmt.Errorf("expected send <file> or recv")
+}
+
+// join connects to the hub with the token in LAWDOCS_TOKEN and joins the room. It returns the
+// client and the other peers already in the room.
+func (f *p2pFlags) join(ctx context.Context) (*signaling.Client, []signaling.Peer, error) {
+	if f.room == "" {
+		return nil, nil, fmt.Errorf("-room is required")
+	}
+	token := os.Getenv("LAWDOCS_TOKEN")
+	if token == "" {
+		return nil, nil, fmt.Errorf("set LAWDOCS_TOKEN to a tenant API token")
+	}
+	client, err := signaling.Dial(ctx, f.url, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	peers, err := client.Join(ctx, f.room)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	others := peers[:0]
+	for _, p := range peers {
+		if p.Session != client.Session() {
+			others = append(others, p)
+		}
+	}
+	return client, others, nil
+}
+
+// peer creates a Peer whose signals go to the session to.
+func (f *p2pFlags) peer(ctx context.Context, client *signaling.Client, to string) (*p2p.Peer, error) {
+	var servers []webrtc.ICEServer
+	if urls := splitList(f.ice); len(urls) > 0 {
+		servers = []webrtc.ICEServer{{URLs: urls}}
+	}
+	return p2p.NewPeer(p2p.Options{ICEServers: servers}, func(sig signaling.Signal) error {
+		sig.To = to
+		return client.Signal(ctx, f.room, sig)
+	})
+}
+
+// relay hands the signals of the session from to peer until the connection to the hub ends, and
+// cancels the transfer when that session leaves.
+func relay(client *signaling.Client, from string, peer *p2p.Peer, cancel context.CancelCauseFunc) {
+	log := logging.Component("p2p")
+	for e := range client.Events() {
+		switch {
+		case e.Event == signaling.Signalled && e.Peer.Session == from:
+			if err := peer.HandleSignal(*e.Signal); err != nil {
+				log.Warn("Failed to apply signal", "kind", e.Signal.Kind, "error", err)
+			}
+		case e.Event == signaling.Leave && e.Peer.Session == from:
+			cancel(errors.New("the other peer left the room"))
+		}
+	}
+	cancel(errors.New("connection to the hub ended"))
+}
+
+// p2pSend sends a file to the first other peer in the room.
+func p2pSend(ctx context.Context, f p2pFlags, path string) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	client, peers, err := f.join(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// send to the first peer in the room, waiting for one if there is none yet
+	var to string
+	if len(peers) > 0 {
+		to = peers[0].Session
+	} else {
+		fmt.Printf("waiting for a receiver to join room %s\n", f.room)
+	}
+	for to == "" {
+		select {
+		case e, ok := <-client.Events():
+			if !ok {
+				return fmt.Errorf("connection to the hub ended")
+			}
+			if e.Event == signaling.Join {
+				to = e.Peer.Session
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	peer, err := f.peer(ctx, client, to)
+	if err != nil {
+		return err
+	}
+	defer peer.Close()
+	go relay(client, to, peer, cancel)
+	dc, err := peer.Offer("file")
+	if err != nil {
+		return err
+	}
+	h, err := p2p.SendFile(ctx, dc, path)
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	fmt.Printf("sent %s (%d bytes, sha256 %s)\n", h.Name, h.Size, h.SHA256)
+	return nil
+}
+
+// p2pRecv receives a file from the first peer that sends an offer.
+func p2pRecv(ctx context.Context, f p2pFlags) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	client, _, err := f.join(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	fmt.Printf("waiting for an offer in room %s\n", f.room)
+
+	// answer the first offer, keeping candidates that overtook it for after it
+	var offer *signaling.Signal
+	var early []signaling.Signal
+	for offer == nil {
+		select {
+		case e, ok := <-client.Events():
+			if !ok {
+				return fmt.Errorf("connection to the hub ended")
+			}
+			switch {
+			case e.Event != signaling.Signalled:
+			case e.Signal.Kind == signaling.Offer:
+				offer = e.Signal
+			case e.Signal.Kind == signaling.Candidate:
+				early = append(early, *e.Signal)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	peer, err := f.peer(ctx, client, offer.From)
+	if err != nil {
+		return err
+	}
+	defer peer.Close()
+	transfers := make(chan *p2p.Transfer, 1)
+	var accepted atomic.Bool
+	peer.OnDataChannel(func(dc *webrtc.DataChannel) {
+		// one file per run
+		if !accepted.CompareAndSwap(false, true) {
+			dc.Close()
+			return
+		}
+		transfers <- p2p.Accept(dc, f.dir)
+	})
+	if err := peer.HandleSignal(*offer); err != nil {
+		return err
+	}
+	for _, sig := range early {
+		if sig.From == offer.From {
+			if err := peer.HandleSignal(sig); err != nil {
+				return err
+			}
+		}
+	}
+	go relay(client, offer.From, peer, cancel)
+
+	var transfer *p2p.Transfer
+	select {
+	case transfer = <-transfers:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+	file, err := transfer.Wait(ctx)
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	fmt.Printf("received %s (%d bytes, sha256 %s verified)\n", file.Path, file.Size, file.SHA256)
+	// closing right away could drop the verdict before the sender has it
+	select {
+	case <-transfer.Closed():
+	case <-time.After(5 * time.Second):
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/lifecycle"
+	"github.com/wilbyang/law-docs/internal/processor"
+	"github.com/wilbyang/law-docs/internal/services"
+	"github.com/wilbyang/law-docs/internal/streams"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+func runProcess(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("process", flag.ExitOnError)
+	metricsAddr := fs.String("metrics-addr", ":9091", "listen address for the /metrics endpoint, empty to disable")
+	pipeline := fs.String("pipeline-stream", "pipeline", "Redis stream for processing events read by lawdocs alert, empty to disable")
+	visibility := fs.Duration("visibility-timeout", time.Minute, "how long a message stays hidden from other workers while it is processed; must exceed the processing time")
+	clamdAddr := fs.String("clamd-addr", "", "ClamAV daemon address uploads are scanned with before processing, e.g. localhost:3310, empty to disable")
+	fs.Parse(args)
+
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := newNotifier(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	notifier.VisibilityTimeout = *visibility
+
+	// a nil *streams.Producer in the interface would not compare equal to nil
+	var events processor.Publisher
+	var redisClient *redis.Client
+	if *pipeline != "" {
+		redisClient = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		events = streams.NewProducer(redisClient, *pipeline, 0)
+	}
+
+	// a nil *services.Clamd in the interface would not compare equal to nil either
+	var scanner processor.Scanner
+	if *clamdAddr != "" {
+		uploader, err := newUploader(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		scanner = &services.Clamd{Addr: *clamdAddr, Files: uploader}
+	}
+
+	store := tenant.NewStore(pool)
+	p := processor.New(store, events, scanner)
+
+	m := lifecycle.New(cfg.ShutdownTimeout)
+	if redisClient != nil {
+		m.Add(lifecycle.Component{
+			Name: "redis",
+			Stop: func(context.Context) error { return redisClient.Close() },
+		})
+	}
+	m.Add(postgresComponent(pool))
+	m.Add(tenantIsolationComponent(store))
+	if *metricsAddr != "" {
+		m.Add(metricsServer(*metricsAddr))
+	}
+	m.Add(lifecycle.Component{
+		Name: "sqs-worker",
+		Run: func(ctx context.Context) error {
+			notifier.ReceiveMessage(ctx, p.HandleMessage)
+			return nil
+		},
+	})
+	return m.Run(ctx)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/events"
+	"github.com/wilbyang/law-docs/internal/lifecycle"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/pgnotify"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+func runRelay(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("relay", flag.ExitOnError)
+	channel := fs.String("channel", events.Channel, "Postgres NOTIFY channel to listen on")
+	fs.Parse(args)
+
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	log := logging.Component("relay")
+	// document changes are decoded and caught up on after reconnects; other channels are logged raw
+	var handler pgnotify.Handler = rawHandler{log: log}
+	if *channel == events.Channel {
+		handler = events.NewFeed(tenant.NewStore(pool), func(e events.DocumentEvent) error {
+			log.InfoContext(ctx, "Document changed", "type", e.Type, "tenant_id", e.TenantID, "document_id", e.DocumentID,
+				"status", e.Status, "changed", e.Changed, "replayed", e.Replayed)
+			return nil
+		})
+	}
+
+	m := lifecycle.New(cfg.ShutdownTimeout)
+	m.Add(postgresComponent(pool))
+	m.Add(lifecycle.Component{
+		Name: "listener",
+		Run: func(ctx context.Context) error {
+			return pgnotify.New(pool, pgnotify.Options{Channels: []string{*channel}}).Run(ctx, handler)
+		},
+	})
+	return m.Run(ctx)
+}
+
+// rawHandler logs the notifications of a channel it knows nothing about.
+type rawHandler struct {
+	log *slog.Logger
+}
+
+func (h rawHandler) HandleNotification(ctx context.Context, payload string) error {
+	h.log.InfoContext(ctx, "Received notification", "payload", payload)
+	return nil
+}
+
+func (h rawHandler) CatchUp(ctx context.Context, since time.Time) error {
+	h.log.WarnContext(ctx, "Notifications may have been missed while reconnecting", "since", since)
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wilbyang/law-docs/internal/api"
+	"github.com/wilbyang/law-docs/internal/backplane"
+	"github.com/wilbyang/law-docs/internal/config"
+	"github.com/wilbyang/law-docs/internal/events"
+	"github.com/wilbyang/law-docs/internal/health"
+	"github.com/wilbyang/law-docs/internal/lifecycle"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/pgnotify"
+	"github.com/wilbyang/law-docs/internal/presence"
+	"github.com/wilbyang/law-docs/internal/signaling"
+	"github.com/wilbyang/law-docs/internal/sse"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/webhooks"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+func runServe(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "HTTP listen address")
+	certFile := fs.String("tls-cert", "", "TLS certificate file, enables HTTPS together with -tls-key")
+	keyFile := fs.String("tls-key", "", "TLS private key file")
+	drainDelay := fs.Duration("drain-delay", 5*time.Second, "how long /readyz fails before the listener closes on shutdown")
+	cluster := fs.String("backplane", "none", "how websocket publishes reach the other replicas: none or redis")
+	wsOrigins := fs.String("ws-origins", "", "comma-separated cross-origin hosts allowed to open websockets, e.g. app.example.com")
+	hooks := fs.Bool("webhooks", true, "deliver document events to the tenants' webhooks")
+	trustedProxies := fs.String("trusted-proxies", cfg.TrustedProxies, "comma-separated addresses or CIDR ranges of reverse proxies whose X-Forwarded-For is trusted")
+	insecureAdmin := fs.Bool("insecure-admin", false, "serve /api/v1/admin without authentication when ADMIN_TOKEN is unset, for local development")
+	fs.Parse(args)
+
+	if cfg.AdminToken == "" {
+		if !*insecureAdmin {
+			return fmt.Errorf("ADMIN_TOKEN is not set: set it, or pass -insecure-admin to serve /api/v1/admin without authentication")
+		}
+		logging.Component("api").WarnContext(ctx, "ADMIN_TOKEN is not set, /api/v1/admin is served without authentication")
+	}
+
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := newNotifier(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	uploader, err := newUploader(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	checker := health.NewChecker(2*time.Second, 5*time.Second)
+	checker.Register("postgres", pool.Ping)
+	checker.Register("s3", uploader.Ping)
+	checker.Register("sqs", notifier.Ping)
+
+	node := backplane.NewNodeID()
+	var wsBackplane backplane.Backplane
+	var redisClient *redis.Client
+	switch *cluster {
+	case "none":
+	case "redis":
+		redisClient = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		checker.Register("redis", func(ctx context.Context) error { return redisClient.Ping(ctx).Err() })
+		wsBackplane = backplane.NewRedis(redisClient, "lawdocs:ws:", node)
+	default:
+		return fmt.Errorf("unknown backplane %q", *cluster)
+	}
+
+	store := tenant.NewStore(pool)
+	// every replica LISTENs for document changes itself, so the change feed needs no backplane
+	broker := sse.NewBroker(sse.Options{Node: node})
+	hub := ws.NewHub(ws.Options{Authorize: api.AuthorizeTopic, OriginPatterns: splitList(*wsOrigins), Backplane: wsBackplane})
+	tracker := presence.NewTracker(hub, time.Minute)
+	rooms := signaling.NewServer(hub, 20*time.Second)
+	var dispatcher *webhooks.Dispatcher
+	if *hooks {
+		dispatcher = webhooks.New(store, webhooks.Options{})
+	}
+	server := api.NewAPI(store, notifier, uploader, hub, tracker, broker, checker, cfg.AdminToken)
+	if err := server.TrustProxies(splitList(*trustedProxies)); err != nil {
+		return fmt.Errorf("invalid -trusted-proxies: %w", err)
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Shutdown waits for open requests, and event streams only end when their client leaves
+	srv.RegisterOnShutdown(broker.Close)
+
+	m := lifecycle.New(cfg.ShutdownTimeout)
+	m.Add(postgresComponent(pool))
+	m.Add(tenantIsolationComponent(store))
+	m.Add(lifecycle.Component{
+		Name: "document-metrics",
+		Run: func(ctx context.Context) error {
+			return metrics.WatchDocuments(ctx, store, 30*time.Second)
+		},
+	})
+	m.Add(lifecycle.Component{
+		Name: "document-events",
+		Run: func(ctx context.Context) error {
+			listener := pgnotify.New(pool, pgnotify.Options{Channels: []string{events.Channel}})
+			return listener.Run(ctx, events.NewFeed(store, documentEvents(broker, hub, dispatcher)))
+		},
+	})
+	if dispatcher != nil {
+		m.Add(lifecycle.Component{
+			Name: "webhooks",
+			Run:  dispatcher.Run,
+		})
+	}
+	m.Add(lifecycle.Component{
+		Name: "presence",
+		Run:  tracker.Run,
+	})
+	m.Add(lifecycle.Component{
+		Name: "signaling",
+		Run:  rooms.Run,
+	})
+	if redisClient != nil {
+		m.Add(lifecycle.Component{
+			Name: "websocket-backplane",
+			Run:  hub.Run,
+			Stop: func(context.Context) error { return redisClient.Close() },
+		})
+	}
+	m.Add(lifecycle.HTTPServer("http", srv, *certFile, *keyFile))
+	// Stopped before the HTTP server: fail readiness first so load balancers
+	// stop routing here while the listener is still open.
+	m.Add(lifecycle.Component{
+		Name: "readiness",
+		Stop: func(ctx context.Context) error {
+			checker.SetShuttingDown()
+			select {
+			case <-time.After(*drainDelay):
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	})
+	return m.Run(ctx)
+}
+
+// documentEvents publishes document events to the change feed and, as the retained status of
+// each document, to the websocket hub. Every replica receives the notifications, so neither is
+// forwarded to the other nodes. With a dispatcher the events are also queued for webhooks, which
+// it delivers once however many replicas queue them.
+func documentEvents(broker *sse.Broker, hub *ws.Hub, dispatcher *webhooks.Dispatcher) func(events.DocumentEvent) error {
+	publish := events.Publisher(broker)
+	return func(e events.DocumentEvent) error {
+		// a replayed update may hide a status change
+		if e.Type != events.DocumentUpdated || e.Replayed {
+			hub.PublishLocal(api.DocumentStatusTopic(e.TenantID, e.DocumentID), e, true)
+		}
+		err := publish(e)
+		if dispatcher != nil {
+			err = errors.Join(err, dispatcher.Enqueue(e))
+		}
+		return err
+	}
+}
+
+// splitList returns the non-empty comma-separated items of s.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
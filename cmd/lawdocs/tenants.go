@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/wilbyang/law-docs/internal/config"
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+func runTenantCreate(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("tenant-create", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lawdocs admin tenant-create <name>")
+	}
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	t, err := tenant.NewStore(pool).Queries().CreateTenant(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("tenant %d %s\n", t.ID, t.Name)
+	return nil
+}
+
+func runUserCreate(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("user-create", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		return fmt.Errorf("usage: lawdocs admin user-create <tenant-id> <name> <email>")
+	}
+	tenantID, err := parseID("tenant", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	// inserted as the tenant so the row picks up its tenant_id and passes the policy check
+	var user repository.User
+	err = tenant.NewStore(pool).Tx(tenant.WithID(ctx, tenantID), func(q *repository.Queries) error {
+		user, err = q.CreateUser(ctx, repository.CreateUserParams{Name: fs.Arg(1), Email: fs.Arg(2)})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user %d %s <%s> in tenant %d\n", user.ID, user.Name, user.Email, user.TenantID)
+	return nil
+}
+
+func runTokenCreate(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("token-create", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: lawdocs admin token-create <user-id> <label>")
+	}
+	userID, err := parseID("user", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	token, row, err := tenant.NewStore(pool).IssueToken(ctx, userID, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("token %d for user %d in tenant %d (shown once, store it now):\n%s\n", row.ID, row.UserID, row.TenantID, token)
+	return nil
+}
+
+func runTokenRevoke(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("token-revoke", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lawdocs admin token-revoke <token-id>")
+	}
+	id, err := parseID("token", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	pool, err := openPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	return tenant.NewStore(pool).Queries().RevokeAPIToken(ctx, id)
+}
+
+func parseID(kind, s string) (int32, error) {
+	id, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s id %q: %w", kind, s, err)
+	}
+	return int32(id), nil
+}
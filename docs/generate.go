@@ -0,0 +1,12 @@
+package docs
+
+import _ "embed"
+
+//go:generate go tool swag init -d .. -g cmd/lawdocs/main.go --parseInternal -o .
+//go:generate go run ../internal/tools/openapi3 -in swagger.json -out openapi.json
+//go:generate go tool oapi-codegen -config ../pkg/client/oapi-codegen.yaml openapi.json
+
+// OpenAPI is the OpenAPI 3 description of the HTTP API, served at /openapi.json.
+//
+//go:embed openapi.json
+var OpenAPI []byte
@@ -0,0 +1,76 @@
+// Package alerting raises alerts from pipeline events. Rules evaluate every event, an Engine
+// drops the alerts that fired recently or are silenced and publishes the rest to the alerts
+// stream, and sinks deliver them from there by email or webhook.
+package alerting
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pipeline event types, published by the processor on the pipeline stream or observed directly
+// by the pollers of the alert command.
+const (
+	ProcessingSucceeded = "processing.succeeded"
+	ProcessingFailed    = "processing.failed"
+	// UploadInfected is published by the processor when the malware scanner finds an uploaded
+	// file infected.
+	UploadInfected = "upload.infected"
+	// DLQDepth reports the number of messages in the dead-letter queue as Value.
+	DLQDepth = "dlq.depth"
+	// DocumentStuck reports a document that has been auditing since Since.
+	DocumentStuck = "document.stuck"
+)
+
+// AlertFired is the event type of alerts on the alerts stream.
+const AlertFired = "alert.fired"
+
+// Event is a pipeline event. Type and Time come from the stream envelope.
+type Event struct {
+	Type       string    `json:"-"`
+	Time       time.Time `json:"-"`
+	TenantID   int32     `json:"tenant_id,omitempty"`
+	DocumentID int32     `json:"document_id,omitempty"`
+	// Value is the measurement of gauge events such as DLQDepth.
+	Value float64 `json:"value,omitempty"`
+	// Since is when the condition reported by the event began.
+	Since time.Time `json:"since,omitzero"`
+	// Detail describes the event, e.g. the processing error, the malware found or the document's title.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Alert severities.
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is raised by a rule. Alerts with the same Rule and Key are one incident: they are
+// deduplicated and silenced together.
+type Alert struct {
+	Rule       string    `json:"rule"`
+	Key        string    `json:"key"`
+	Severity   string    `json:"severity"`
+	Summary    string    `json:"summary"`
+	TenantID   int32     `json:"tenant_id,omitempty"`
+	DocumentID int32     `json:"document_id,omitempty"`
+	FiredAt    time.Time `json:"fired_at"`
+}
+
+// Fingerprint identifies the incident of a.
+func (a Alert) Fingerprint() string {
+	return a.Rule + "/" + a.Key
+}
+
+// Subject is a one-line description of a for email subjects and chat messages.
+func (a Alert) Subject() string {
+	return fmt.Sprintf("[%s] %s", strings.ToUpper(a.Severity), a.Summary)
+}
+
+// Rule turns events into alerts. Evaluate is called with every event, one at a time and in the
+// order they arrive, and may keep state between calls.
+type Rule interface {
+	Name() string
+	Evaluate(e Event) []Alert
+}
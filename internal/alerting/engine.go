@@ -0,0 +1,99 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/streams"
+)
+
+// Publisher appends events to a stream, such as a streams.Producer.
+type Publisher interface {
+	Publish(ctx context.Context, eventType string, data any) (string, error)
+}
+
+// Engine evaluates rules and publishes the alerts they raise.
+type Engine struct {
+	state  State
+	alerts Publisher
+	dedup  time.Duration
+	log    *slog.Logger
+
+	// mu serializes rule evaluation
+	mu    sync.Mutex
+	rules []Rule
+}
+
+// NewEngine creates an Engine that publishes the alerts of rules to alerts as AlertFired events.
+// An incident is published at most once per dedup window.
+func NewEngine(state State, alerts Publisher, dedup time.Duration, rules ...Rule) *Engine {
+	return &Engine{state: state, alerts: alerts, dedup: dedup, rules: rules, log: logging.Component("alerting")}
+}
+
+// Handle is a streams.Handler that observes the events of the pipeline stream.
+func (e *Engine) Handle(ctx context.Context, env streams.Envelope) error {
+	var ev Event
+	if err := env.Decode(&ev); err != nil {
+		return err
+	}
+	ev.Type, ev.Time = env.Type, env.Time
+	return e.Observe(ctx, ev)
+}
+
+// Observe evaluates ev with every rule and publishes the alerts raised that are neither silenced
+// nor fired within the dedup window. ev.Time defaults to now.
+func (e *Engine) Observe(ctx context.Context, ev Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	e.mu.Lock()
+	var raised []Alert
+	for _, r := range e.rules {
+		raised = append(raised, r.Evaluate(ev)...)
+	}
+	e.mu.Unlock()
+
+	var errs []error
+	for _, a := range raised {
+		a.FiredAt = ev.Time
+		if err := e.raise(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Engine) raise(ctx context.Context, a Alert) error {
+	silenced, err := e.state.Silenced(ctx, a.Rule, a.Key)
+	if err != nil {
+		return err
+	}
+	if silenced {
+		e.log.DebugContext(ctx, "Alert silenced", "rule", a.Rule, "key", a.Key)
+		metrics.Alerts.WithLabelValues(a.Rule, "silenced").Inc()
+		return nil
+	}
+	first, err := e.state.Fire(ctx, a.Fingerprint(), e.dedup)
+	if err != nil {
+		return err
+	}
+	if !first {
+		metrics.Alerts.WithLabelValues(a.Rule, "deduplicated").Inc()
+		return nil
+	}
+	if _, err := e.alerts.Publish(ctx, AlertFired, a); err != nil {
+		// let the retry of the event fire it again
+		if err := e.state.Forget(ctx, a.Fingerprint()); err != nil {
+			e.log.ErrorContext(ctx, "Failed to forget unpublished alert", "rule", a.Rule, "key", a.Key, "error", err)
+		}
+		return err
+	}
+	e.log.InfoContext(ctx, "Alert fired", "rule", a.Rule, "key", a.Key, "severity", a.Severity, "summary", a.Summary)
+	metrics.Alerts.WithLabelValues(a.Rule, "fired").Inc()
+	return nil
+}
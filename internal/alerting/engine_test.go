@@ -0,0 +1,116 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// published collects the alerts an Engine publishes, failing while err is set.
+type published struct {
+	alerts []Alert
+	err    error
+}
+
+func (p *published) Publish(_ context.Context, eventType string, data any) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	if eventType != AlertFired {
+		return "", errors.New("unexpected event type " + eventType)
+	}
+	p.alerts = append(p.alerts, data.(Alert))
+	return "1-0", nil
+}
+
+func stuck(documentID int32) Event {
+	return Event{Type: DocumentStuck, TenantID: 1, DocumentID: documentID, Since: time.Now().Add(-100 * time.Hour)}
+}
+
+func TestEngineDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	alerts := &published{}
+	e := NewEngine(NewMemory(), alerts, time.Hour, StuckAudit{})
+
+	for _, ev := range []Event{stuck(42), stuck(42), stuck(43)} {
+		if err := e.Observe(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(alerts.alerts) != 2 {
+		t.Fatalf("published %d alerts, want one per document", len(alerts.alerts))
+	}
+	if got := alerts.alerts[0].Fingerprint(); got != "stuck-audit/1/42" {
+		t.Errorf("fingerprint %q, want stuck-audit/1/42", got)
+	}
+
+	// a window that has passed fires again
+	e = NewEngine(NewMemory(), alerts, time.Nanosecond, StuckAudit{})
+	alerts.alerts = nil
+	for range 2 {
+		time.Sleep(time.Millisecond)
+		if err := e.Observe(ctx, stuck(42)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(alerts.alerts) != 2 {
+		t.Errorf("published %d alerts, want 2 after the window passed", len(alerts.alerts))
+	}
+}
+
+func TestEngineRefiresUnpublishedAlert(t *testing.T) {
+	ctx := context.Background()
+	alerts := &published{err: errors.New("stream unavailable")}
+	e := NewEngine(NewMemory(), alerts, time.Hour, StuckAudit{})
+
+	if err := e.Observe(ctx, stuck(42)); err == nil {
+		t.Fatal("expected the publish error")
+	}
+	alerts.err = nil
+	if err := e.Observe(ctx, stuck(42)); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Errorf("published %d alerts, want the retried one", len(alerts.alerts))
+	}
+}
+
+func TestEngineSilences(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemory()
+	alerts := &published{}
+	e := NewEngine(state, alerts, time.Hour, StuckAudit{})
+
+	if err := state.Silence(ctx, "stuck-audit", "1/42", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range []Event{stuck(42), stuck(43)} {
+		if err := e.Observe(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].DocumentID != 43 {
+		t.Fatalf("published %+v, want only document 43's alert", alerts.alerts)
+	}
+
+	// silencing the rule silences every key; lifting it fires again
+	if err := state.Silence(ctx, "stuck-audit", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Observe(ctx, stuck(44)); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("published %d alerts while the rule is silenced", len(alerts.alerts))
+	}
+	if err := state.Silence(ctx, "stuck-audit", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Observe(ctx, stuck(44)); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts.alerts) != 2 {
+		t.Errorf("published %d alerts, want document 44's once the silence is lifted", len(alerts.alerts))
+	}
+}
@@ -0,0 +1,122 @@
+package alerting
+
+import (
+	"fmt"
+	"time"
+)
+
+// FailureRate alerts when the share of failed processing runs within Window reaches Threshold,
+// once at least MinEvents runs happened in it.
+type FailureRate struct {
+	Window    time.Duration
+	Threshold float64
+	MinEvents int
+
+	runs []run
+}
+
+type run struct {
+	at     time.Time
+	failed bool
+}
+
+func (r *FailureRate) Name() string { return "processing-failure-rate" }
+
+func (r *FailureRate) Evaluate(e Event) []Alert {
+	if e.Type != ProcessingSucceeded && e.Type != ProcessingFailed {
+		return nil
+	}
+	r.runs = append(r.runs, run{at: e.Time, failed: e.Type == ProcessingFailed})
+	start := 0
+	for start < len(r.runs) && e.Time.Sub(r.runs[start].at) > r.Window {
+		start++
+	}
+	r.runs = r.runs[start:]
+
+	failed := 0
+	for _, x := range r.runs {
+		if x.failed {
+			failed++
+		}
+	}
+	if len(r.runs) < r.MinEvents || float64(failed) < r.Threshold*float64(len(r.runs)) {
+		return nil
+	}
+	return []Alert{{
+		Rule:     r.Name(),
+		Key:      "all",
+		Severity: SeverityCritical,
+		Summary: fmt.Sprintf("%d of the last %d documents (%.0f%%) failed processing within %s",
+			failed, len(r.runs), 100*float64(failed)/float64(len(r.runs)), r.Window),
+	}}
+}
+
+// DLQGrowth alerts when the dead-letter queue grows to at least MinDepth messages.
+type DLQGrowth struct {
+	MinDepth float64
+
+	depth float64
+}
+
+func (r *DLQGrowth) Name() string { return "dlq-growth" }
+
+func (r *DLQGrowth) Evaluate(e Event) []Alert {
+	if e.Type != DLQDepth {
+		return nil
+	}
+	previous := r.depth
+	r.depth = e.Value
+	if e.Value <= previous || e.Value < r.MinDepth {
+		return nil
+	}
+	return []Alert{{
+		Rule:     r.Name(),
+		Key:      "dlq",
+		Severity: SeverityWarning,
+		Summary:  fmt.Sprintf("Dead-letter queue grew from %g to %g messages", previous, e.Value),
+	}}
+}
+
+// Infected alerts on every infected upload.
+type Infected struct{}
+
+func (Infected) Name() string { return "infected-upload" }
+
+func (r Infected) Evaluate(e Event) []Alert {
+	if e.Type != UploadInfected {
+		return nil
+	}
+	summary := fmt.Sprintf("Upload of document %d of tenant %d is infected", e.DocumentID, e.TenantID)
+	if e.Detail != "" {
+		summary += ": " + e.Detail
+	}
+	return []Alert{{
+		Rule:       r.Name(),
+		Key:        fmt.Sprintf("%d/%d", e.TenantID, e.DocumentID),
+		Severity:   SeverityCritical,
+		Summary:    summary,
+		TenantID:   e.TenantID,
+		DocumentID: e.DocumentID,
+	}}
+}
+
+// StuckAudit alerts on documents that have been auditing for too long, as reported by the
+// stuck-document poller.
+type StuckAudit struct{}
+
+func (StuckAudit) Name() string { return "stuck-audit" }
+
+func (r StuckAudit) Evaluate(e Event) []Alert {
+	if e.Type != DocumentStuck {
+		return nil
+	}
+	return []Alert{{
+		Rule:     r.Name(),
+		Key:      fmt.Sprintf("%d/%d", e.TenantID, e.DocumentID),
+		Severity: SeverityWarning,
+		Summary: fmt.Sprintf("Document %d of tenant %d (%q) has been auditing since %s",
+			e.DocumentID, e.TenantID, e.Detail, e.Since.UTC().Format(time.RFC3339)),
+		TenantID:   e.TenantID,
+		DocumentID: e.DocumentID,
+	}}
+}
@@ -0,0 +1,71 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureRate(t *testing.T) {
+	r := &FailureRate{Window: 10 * time.Minute, Threshold: 0.5, MinEvents: 4}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(minute int, failed bool) []Alert {
+		e := Event{Type: ProcessingSucceeded, Time: start.Add(time.Duration(minute) * time.Minute)}
+		if failed {
+			e.Type = ProcessingFailed
+		}
+		return r.Evaluate(e)
+	}
+
+	if got := run(0, true); got != nil {
+		t.Errorf("fired before MinEvents runs: %+v", got)
+	}
+	run(1, true)
+	run(2, false)
+	if got := run(3, false); len(got) != 1 || got[0].Key != "all" || got[0].Severity != SeverityCritical {
+		t.Errorf("got %+v, want an alert at 2 of 4 failed", got)
+	}
+	// the failures fall out of the window
+	if got := run(12, false); got != nil {
+		t.Errorf("fired for failures outside the window: %+v", got)
+	}
+	if got := r.Evaluate(Event{Type: DLQDepth, Value: 3}); got != nil {
+		t.Errorf("fired for another event type: %+v", got)
+	}
+}
+
+func TestDLQGrowth(t *testing.T) {
+	r := &DLQGrowth{MinDepth: 2}
+	for _, step := range []struct {
+		depth float64
+		fire  bool
+	}{
+		{1, false}, // below MinDepth
+		{3, true},
+		{3, false}, // no growth
+		{2, false},
+		{5, true},
+	} {
+		got := r.Evaluate(Event{Type: DLQDepth, Value: step.depth})
+		if (len(got) == 1) != step.fire {
+			t.Errorf("depth %g: got %+v, want fire=%v", step.depth, got, step.fire)
+		}
+	}
+}
+
+func TestInfected(t *testing.T) {
+	r := Infected{}
+	got := r.Evaluate(Event{Type: UploadInfected, TenantID: 1, DocumentID: 42, Detail: "Eicar-Test-Signature"})
+	if len(got) != 1 {
+		t.Fatalf("got %+v, want one alert", got)
+	}
+	a := got[0]
+	if a.Rule != "infected-upload" || a.Key != "1/42" || a.Severity != SeverityCritical || a.DocumentID != 42 {
+		t.Errorf("got %+v", a)
+	}
+	if a.Summary != "Upload of document 42 of tenant 1 is infected: Eicar-Test-Signature" {
+		t.Errorf("summary %q", a.Summary)
+	}
+	if got := r.Evaluate(Event{Type: ProcessingFailed, TenantID: 1, DocumentID: 42}); got != nil {
+		t.Errorf("fired for another event type: %+v", got)
+	}
+}
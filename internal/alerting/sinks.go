@@ -0,0 +1,132 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/streams"
+)
+
+// Sink delivers alerts to people.
+type Sink interface {
+	// Name identifies the sink in metrics and in its consumer group on the alerts stream.
+	Name() string
+	Send(ctx context.Context, a Alert) error
+}
+
+// Deliver returns a streams.Handler that sends the alerts of the alerts stream to sink. A failed
+// delivery is retried by the stream consumer.
+func Deliver(sink Sink) streams.Handler {
+	return func(ctx context.Context, e streams.Envelope) error {
+		if e.Type != AlertFired {
+			return nil
+		}
+		var a Alert
+		if err := e.Decode(&a); err != nil {
+			return err
+		}
+		err := sink.Send(ctx, a)
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		}
+		metrics.AlertDeliveries.WithLabelValues(sink.Name(), outcome).Inc()
+		return err
+	}
+}
+
+// SMTP sends alerts by email.
+type SMTP struct {
+	// Addr is the host:port of the mail server.
+	Addr string
+	From string
+	To   []string
+	// Auth authenticates to the server, if set. net/smtp only sends credentials over TLS or
+	// to localhost.
+	Auth smtp.Auth
+}
+
+func (s *SMTP) Name() string { return "email" }
+
+// Send implements Sink. net/smtp cannot be cancelled, so ctx is not used.
+func (s *SMTP) Send(_ context.Context, a Alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerText(a.Subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.FiredAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nRule: %s\r\nKey: %s\r\nSeverity: %s\r\nFired at: %s\r\n",
+		a.Summary, a.Rule, a.Key, a.Severity, a.FiredAt.UTC().Format(time.RFC3339))
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send alert email: %w", err)
+	}
+	return nil
+}
+
+// headerText makes v safe for a header line. Summaries include document titles, so a line break
+// could otherwise end the header and start another; non-ASCII text becomes an RFC 2047 encoded word.
+func headerText(v string) string {
+	v = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(v)
+	return mime.QEncoding.Encode("utf-8", v)
+}
+
+// Webhook posts alerts as JSON to a URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+// Send implements Sink.
+func (w *Webhook) Send(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.Client, w.URL, a)
+}
+
+// Slack posts alerts to a Slack incoming webhook, or any service accepting its
+// {"text": ...} payload.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+// Send implements Sink.
+func (s *Slack) Send(ctx context.Context, a Alert) error {
+	text := fmt.Sprintf("*%s*\nRule `%s`, key `%s`, fired %s", a.Subject(), a.Rule, a.Key, a.FiredAt.UTC().Format(time.RFC3339))
+	return postJSON(ctx, s.Client, s.URL, map[string]string{"text": text})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
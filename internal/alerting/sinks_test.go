@@ -0,0 +1,176 @@
+package alerting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	Rule:       "stuck-audit",
+	Key:        "1/42",
+	Severity:   SeverityWarning,
+	Summary:    "Document 42 of tenant 1 (\"Lease\") has been auditing since 2026-01-01T00:00:00Z",
+	TenantID:   1,
+	DocumentID: 42,
+	FiredAt:    time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+}
+
+// smtpServer accepts one message on a local listener and returns its address and a channel
+// receiving the message's data.
+func smtpServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	data := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 end with .")
+				var msg strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					msg.WriteString(line)
+				}
+				data <- msg.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data
+}
+
+func TestSMTP(t *testing.T) {
+	addr, data := smtpServer(t)
+	s := &SMTP{Addr: addr, From: "lawdocs@example.com", To: []string{"ops@example.com", "legal@example.com"}}
+	a := testAlert
+	// a title can carry line breaks and non-ASCII text into the summary
+	a.Summary = "Document 42 (\"Lease\r\nBcc: attacker@example.com\") für Müller is stuck"
+	if err := s.Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("summary injected a Bcc header: %q", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[WARNING] Document 42 (\"Lease Bcc: attacker@example.com\") für Müller is stuck"; subject != want {
+		t.Errorf("subject %q, want %q", subject, want)
+	}
+	if to := msg.Header.Get("To"); to != "ops@example.com, legal@example.com" {
+		t.Errorf("to %q", to)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if !strings.Contains(string(body), "Rule: stuck-audit\r\nKey: 1/42\r\n") {
+		t.Errorf("body misses the rule and key:\n%s", body)
+	}
+}
+
+func TestSMTPServerDown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	s := &SMTP{Addr: addr, From: "lawdocs@example.com", To: []string{"ops@example.com"}}
+	if err := s.Send(context.Background(), testAlert); err == nil {
+		t.Error("expected an error without a mail server")
+	}
+}
+
+// receiver records the JSON bodies posted to it and answers with status.
+func receiver(t *testing.T, status int) (*httptest.Server, <-chan map[string]any) {
+	t.Helper()
+	bodies := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies
+}
+
+func TestWebhook(t *testing.T) {
+	srv, bodies := receiver(t, http.StatusNoContent)
+	w := &Webhook{URL: srv.URL}
+	if err := w.Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+	body := <-bodies
+	if body["rule"] != "stuck-audit" || body["key"] != "1/42" || body["document_id"] != float64(42) ||
+		body["fired_at"] != "2026-01-04T00:00:00Z" {
+		t.Errorf("posted %v", body)
+	}
+
+	srv, _ = receiver(t, http.StatusBadGateway)
+	w = &Webhook{URL: srv.URL}
+	if err := w.Send(context.Background(), testAlert); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("got %v, want the receiver's 502", err)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	srv, bodies := receiver(t, http.StatusOK)
+	s := &Slack{URL: srv.URL}
+	if err := s.Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+	text, _ := (<-bodies)["text"].(string)
+	if !strings.HasPrefix(text, "*[WARNING] Document 42") || !strings.Contains(text, "Rule `stuck-audit`, key `1/42`") {
+		t.Errorf("posted text %q", text)
+	}
+}
@@ -0,0 +1,127 @@
+package alerting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// State remembers which incidents fired recently and which are silenced.
+type State interface {
+	// Fire records that the incident fingerprint fires and reports whether it had not fired
+	// within window already.
+	Fire(ctx context.Context, fingerprint string, window time.Duration) (bool, error)
+	// Forget undoes Fire, for an alert that could not be published.
+	Forget(ctx context.Context, fingerprint string) error
+	// Silenced reports whether alerts of rule with key are silenced.
+	Silenced(ctx context.Context, rule, key string) (bool, error)
+	// Silence silences the alerts of rule with key for d, or every alert of rule if key is
+	// empty. A d that is not positive lifts the silence.
+	Silence(ctx context.Context, rule, key string, d time.Duration) error
+}
+
+// anyKey stands for every key of a rule in silences.
+const anyKey = "*"
+
+func silenceKey(rule, key string) string {
+	if key == "" {
+		key = anyKey
+	}
+	return rule + "/" + key
+}
+
+// Memory is a State for a single process.
+type Memory struct {
+	mu       sync.Mutex
+	fired    map[string]time.Time
+	silences map[string]time.Time
+}
+
+// NewMemory creates an empty Memory state.
+func NewMemory() *Memory {
+	return &Memory{fired: map[string]time.Time{}, silences: map[string]time.Time{}}
+}
+
+// Fire implements State.
+func (m *Memory) Fire(_ context.Context, fingerprint string, window time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if until, ok := m.fired[fingerprint]; ok && now.Before(until) {
+		return false, nil
+	}
+	m.fired[fingerprint] = now.Add(window)
+	return true, nil
+}
+
+// Forget implements State.
+func (m *Memory) Forget(_ context.Context, fingerprint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.fired, fingerprint)
+	return nil
+}
+
+// Silenced implements State.
+func (m *Memory) Silenced(_ context.Context, rule, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, k := range []string{silenceKey(rule, key), silenceKey(rule, "")} {
+		if until, ok := m.silences[k]; ok && now.Before(until) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Silence implements State.
+func (m *Memory) Silence(_ context.Context, rule, key string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d <= 0 {
+		delete(m.silences, silenceKey(rule, key))
+		return nil
+	}
+	m.silences[silenceKey(rule, key)] = time.Now().Add(d)
+	return nil
+}
+
+// Redis is a State shared by every process using the same Redis and prefix. Fired incidents and
+// silences are keys that expire with their window.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis creates a Redis state under prefix, e.g. "lawdocs:alerts:".
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Fire implements State.
+func (r *Redis) Fire(ctx context.Context, fingerprint string, window time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, r.prefix+"fired:"+fingerprint, time.Now().UTC().Format(time.RFC3339), window).Result()
+}
+
+// Forget implements State.
+func (r *Redis) Forget(ctx context.Context, fingerprint string) error {
+	return r.client.Del(ctx, r.prefix+"fired:"+fingerprint).Err()
+}
+
+// Silenced implements State.
+func (r *Redis) Silenced(ctx context.Context, rule, key string) (bool, error) {
+	n, err := r.client.Exists(ctx, r.prefix+"silence:"+silenceKey(rule, key), r.prefix+"silence:"+silenceKey(rule, "")).Result()
+	return n > 0, err
+}
+
+// Silence implements State.
+func (r *Redis) Silence(ctx context.Context, rule, key string, d time.Duration) error {
+	k := r.prefix + "silence:" + silenceKey(rule, key)
+	if d <= 0 {
+		return r.client.Del(ctx, k).Err()
+	}
+	return r.client.Set(ctx, k, time.Now().Add(d).UTC().Format(time.RFC3339), d).Err()
+}
@@ -0,0 +1,86 @@
+package alerting
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/workflow"
+)
+
+// stuckLimit bounds the stuck documents reported per check.
+const stuckLimit = 100
+
+// WatchDLQ reports the approximate depth of the SQS dead-letter queue at queueURL to e every
+// interval until ctx is cancelled.
+func (e *Engine) WatchDLQ(ctx context.Context, client *sqs.Client, queueURL string, interval time.Duration) error {
+	return e.watch(ctx, interval, "dead-letter queue", func(ctx context.Context) error {
+		out, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl:       aws.String(queueURL),
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+		})
+		if err != nil {
+			return err
+		}
+		depth, err := strconv.ParseFloat(out.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)], 64)
+		if err != nil {
+			return err
+		}
+		return e.Observe(ctx, Event{Type: DLQDepth, Value: depth})
+	})
+}
+
+// WatchStuckAudits reports the documents of every tenant that have been auditing without an
+// update for longer than after to e every interval until ctx is cancelled.
+func (e *Engine) WatchStuckAudits(ctx context.Context, store *tenant.Store, after, interval time.Duration) error {
+	return e.watch(ctx, interval, "stuck documents", func(ctx context.Context) error {
+		var docs []repository.ListStaleDocumentsRow
+		err := store.SystemTx(ctx, func(q *repository.Queries) error {
+			var err error
+			docs, err = q.ListStaleDocuments(ctx, repository.ListStaleDocumentsParams{
+				Status:    pgtype.Text{String: workflow.StatusAuditing, Valid: true},
+				UpdatedAt: pgtype.Timestamp{Time: time.Now().Add(-after), Valid: true},
+				Limit:     stuckLimit,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, d := range docs {
+			err := e.Observe(ctx, Event{
+				Type:       DocumentStuck,
+				TenantID:   d.TenantID,
+				DocumentID: d.ID,
+				Since:      d.UpdatedAt.Time,
+				Detail:     d.Title,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (e *Engine) watch(ctx context.Context, interval time.Duration, what string, check func(context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := check(ctx); err != nil && ctx.Err() == nil {
+			e.log.ErrorContext(ctx, "Failed to check "+what, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
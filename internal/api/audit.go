@@ -0,0 +1,352 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wilbyang/law-docs/internal/audit"
+	entity "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// maxAuditPage bounds the events returned by one GET /api/v1/audit/events call.
+const maxAuditPage = 1000
+
+// AuditQuery holds the filters shared by the audit list and export endpoints.
+type AuditQuery struct {
+	DocumentID int32     `form:"document_id" binding:"omitempty,min=1"`
+	ActorID    int32     `form:"actor_id" binding:"omitempty,min=1"`
+	Action     string    `form:"action" binding:"omitempty,oneof=list view download create upload update transition tag move comment delete share export"`
+	Since      time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until      time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	AfterID    int64     `form:"after_id" binding:"omitempty,min=0"`
+	Limit      int32     `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// AuditExportQuery holds the query parameters of GET /api/v1/audit/export.
+type AuditExportQuery struct {
+	AuditQuery
+	Format string `form:"format" binding:"omitempty,oneof=csv json"`
+}
+
+// AuditEventResponse is one entry of the audit log. Hashes are hex encoded.
+type AuditEventResponse struct {
+	ID         int64     `json:"id" validate:"required" example:"17"`
+	OccurredAt time.Time `json:"occurred_at" validate:"required" format:"date-time"`
+	ActorID    *int32    `json:"actor_id,omitempty" example:"1"`
+	Action     string    `json:"action" validate:"required" enums:"list,view,download,create,upload,update,transition,tag,move,comment,delete,share,export" example:"view"`
+	DocumentID *int32    `json:"document_id,omitempty" example:"42"`
+	IP         string    `json:"ip,omitempty" example:"203.0.113.7"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	BeforeHash string    `json:"before_hash,omitempty"`
+	AfterHash  string    `json:"after_hash,omitempty"`
+	PrevHash   string    `json:"prev_hash,omitempty"`
+	Hash       string    `json:"hash" validate:"required"`
+}
+
+// AuditEventListResponse is returned by GET /api/v1/audit/events. NextAfterID is set when more
+// events may follow; pass it as after_id to fetch the next page.
+type AuditEventListResponse struct {
+	Events      []AuditEventResponse `json:"events" validate:"required"`
+	NextAfterID *int64               `json:"next_after_id,omitempty" example:"17"`
+}
+
+// AuditVerifyResponse reports whether the tenant's audit log hash chain is intact.
+type AuditVerifyResponse struct {
+	Valid    bool   `json:"valid" validate:"required"`
+	Events   int64  `json:"events" validate:"required" example:"120"`
+	Head     string `json:"head,omitempty"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (query AuditQuery) params(tenantID int32) entity.ListAuditEventsParams {
+	return entity.ListAuditEventsParams{
+		TenantID:   tenantID,
+		AfterID:    query.AfterID,
+		DocumentID: pgtype.Int4{Int32: query.DocumentID, Valid: query.DocumentID != 0},
+		ActorID:    pgtype.Int4{Int32: query.ActorID, Valid: query.ActorID != 0},
+		Action:     pgtype.Text{String: query.Action, Valid: query.Action != ""},
+		Since:      pgtype.Timestamptz{Time: query.Since, Valid: !query.Since.IsZero()},
+		Until:      pgtype.Timestamptz{Time: query.Until, Valid: !query.Until.IsZero()},
+		MaxRows:    maxAuditPage,
+	}
+}
+
+func auditEventResponse(e entity.AuditEvent) AuditEventResponse {
+	resp := AuditEventResponse{
+		ID:         e.ID,
+		OccurredAt: e.OccurredAt.Time,
+		Action:     e.Action,
+		IP:         e.Ip.String,
+		UserAgent:  e.UserAgent.String,
+		RequestID:  e.RequestID.String,
+		BeforeHash: hex.EncodeToString(e.BeforeHash),
+		AfterHash:  hex.EncodeToString(e.AfterHash),
+		PrevHash:   hex.EncodeToString(e.PrevHash),
+		Hash:       hex.EncodeToString(e.Hash),
+	}
+	if e.ActorID.Valid {
+		resp.ActorID = &e.ActorID.Int32
+	}
+	if e.DocumentID.Valid {
+		resp.DocumentID = &e.DocumentID.Int32
+	}
+	return resp
+}
+
+// @Summary List audit events
+// @ID listAuditEvents
+// @Description List the caller's tenant audit log in order, one page at a time
+// @Tags audit
+// @Security BearerAuth
+// @Param document_id query int false "Only events of this document" minimum(1)
+// @Param actor_id query int false "Only events by this user" minimum(1)
+// @Param action query string false "Only events with this action" Enums(list, view, download, create, upload, update, transition, tag, move, comment, delete, share, export)
+// @Param since query string false "Events at or after this time (RFC 3339)" format(date-time)
+// @Param until query string false "Events before this time (RFC 3339)" format(date-time)
+// @Param after_id query int false "Events after this ID, for paging" minimum(0)
+// @Param limit query int false "Page size, 1000 by default" minimum(1) maximum(1000)
+// @Produce json
+// @Success 200 {object} AuditEventListResponse
+// @Failure 400 {object} Problem "Invalid filters"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to list audit events"
+// @Router /api/v1/audit/events [get]
+func (api *API) listAuditEvents(c *gin.Context) {
+	var query AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	caller, _ := tenant.FromContext(ctx)
+	params := query.params(caller.TenantID)
+	if query.Limit != 0 {
+		params.MaxRows = query.Limit
+	}
+	var events []entity.AuditEvent
+	err := api.store.ReadTx(ctx, func(q *entity.Queries) error {
+		var err error
+		events, err = q.ListAuditEvents(ctx, params)
+		return err
+	})
+	if err != nil {
+		api.log.ErrorContext(ctx, "Failed to list audit events", "error", err)
+		problem(c, http.StatusInternalServerError, CodeInternal, "Failed to list audit events")
+		return
+	}
+	resp := AuditEventListResponse{Events: make([]AuditEventResponse, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, auditEventResponse(e))
+	}
+	if len(events) == int(params.MaxRows) {
+		next := events[len(events)-1].ID
+		resp.NextAfterID = &next
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Export audit events
+// @ID exportAuditEvents
+// @Description Download every matching audit event as CSV or a JSON array; the export itself is recorded first
+// @Tags audit
+// @Security BearerAuth
+// @Param format query string false "Export format, csv by default" Enums(csv, json)
+// @Param document_id query int false "Only events of this document" minimum(1)
+// @Param actor_id query int false "Only events by this user" minimum(1)
+// @Param action query string false "Only events with this action" Enums(list, view, download, create, upload, update, transition, tag, move, comment, delete, share, export)
+// @Param since query string false "Events at or after this time (RFC 3339)" format(date-time)
+// @Param until query string false "Events before this time (RFC 3339)" format(date-time)
+// @Produce text/csv
+// @Produce json
+// @Success 200 {array} AuditEventResponse
+// @Failure 400 {object} Problem "Invalid filters"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to export audit events"
+// @Router /api/v1/audit/export [get]
+func (api *API) exportAuditEvents(c *gin.Context) {
+	var query AuditExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionExport, DocumentID: query.DocumentID, After: query})
+	})
+	if err != nil {
+		api.log.ErrorContext(ctx, "Failed to export audit events", "error", err)
+		problem(c, http.StatusInternalServerError, CodeInternal, "Failed to export audit events")
+		return
+	}
+
+	format := query.Format
+	if format == "" {
+		format = "csv"
+	}
+	w := newAuditWriter(c, format)
+	caller, _ := tenant.FromContext(ctx)
+	params := query.AuditQuery.params(caller.TenantID)
+	params.AfterID = 0
+	for {
+		var page []entity.AuditEvent
+		err := api.store.ReadTx(ctx, func(q *entity.Queries) error {
+			var err error
+			page, err = q.ListAuditEvents(ctx, params)
+			return err
+		})
+		if err == nil {
+			err = w.write(page)
+		}
+		if err != nil {
+			// the status line is already sent; a truncated body is all the client can be told
+			api.log.ErrorContext(ctx, "Failed to export audit events", "error", err)
+			return
+		}
+		c.Writer.Flush()
+		if len(page) < int(params.MaxRows) {
+			break
+		}
+		params.AfterID = page[len(page)-1].ID
+	}
+	if err := w.close(); err != nil {
+		api.log.ErrorContext(ctx, "Failed to export audit events", "error", err)
+	}
+}
+
+// auditWriter streams audit events as CSV rows or elements of a JSON array.
+type auditWriter struct {
+	c     *gin.Context
+	csv   *csv.Writer
+	count int
+}
+
+func newAuditWriter(c *gin.Context, format string) *auditWriter {
+	name := "audit-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w := &auditWriter{c: c}
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w.csv = csv.NewWriter(c.Writer)
+		_ = w.csv.Write([]string{"id", "occurred_at", "actor_id", "action", "document_id", "ip", "user_agent", "request_id", "before_hash", "after_hash", "prev_hash", "hash"})
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Status(http.StatusOK)
+	return w
+}
+
+func (w *auditWriter) write(events []entity.AuditEvent) error {
+	for _, e := range events {
+		resp := auditEventResponse(e)
+		if w.csv != nil {
+			_ = w.csv.Write(csvCells(
+				strconv.FormatInt(resp.ID, 10),
+				resp.OccurredAt.UTC().Format(time.RFC3339Nano),
+				optionalID(resp.ActorID),
+				resp.Action,
+				optionalID(resp.DocumentID),
+				resp.IP,
+				resp.UserAgent,
+				resp.RequestID,
+				resp.BeforeHash,
+				resp.AfterHash,
+				resp.PrevHash,
+				resp.Hash,
+			))
+			continue
+		}
+		b, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if w.count == 0 {
+			sep = "["
+		}
+		if _, err := w.c.Writer.WriteString(sep + string(b)); err != nil {
+			return err
+		}
+		w.count++
+	}
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// csvCells returns cells with a quote prepended to those a spreadsheet would read as a formula,
+// since the user agent and request ID are chosen by the client.
+func csvCells(cells ...string) []string {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	return cells
+}
+
+func (w *auditWriter) close() error {
+	if w.csv != nil {
+		return nil
+	}
+	end := "]"
+	if w.count == 0 {
+		end = "[]"
+	}
+	_, err := w.c.Writer.WriteString(end)
+	return err
+}
+
+func optionalID(id *int32) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*id))
+}
+
+// @Summary Verify the audit log
+// @ID verifyAuditLog
+// @Description Recompute the hash chain of the caller's tenant audit log and report the first broken event
+// @Tags audit
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} AuditVerifyResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to verify audit log"
+// @Router /api/v1/audit/verify [get]
+func (api *API) verifyAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	var verifier *audit.Verifier
+	err := api.store.ReadTx(ctx, func(q *entity.Queries) error {
+		var err error
+		verifier, err = audit.VerifyAll(ctx, q)
+		return err
+	})
+	var chain *audit.ChainError
+	if err != nil && !errors.As(err, &chain) {
+		api.log.ErrorContext(ctx, "Failed to verify audit log", "error", err)
+		problem(c, http.StatusInternalServerError, CodeInternal, "Failed to verify audit log")
+		return
+	}
+	resp := AuditVerifyResponse{Valid: chain == nil, Events: verifier.Count, Head: hex.EncodeToString(verifier.Head())}
+	if chain != nil {
+		api.log.WarnContext(ctx, "Audit chain broken", "event_id", chain.EventID, "reason", chain.Reason)
+		resp.BrokenAt = &chain.EventID
+		resp.Reason = chain.Reason
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	entity "github.com/wilbyang/law-docs/internal/db"
+)
+
+func TestAuditCSVEscapesFormulas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	w := newAuditWriter(c, "csv")
+	err := w.write([]entity.AuditEvent{{
+		ID:         1,
+		OccurredAt: pgtype.Timestamptz{Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		Action:     "view",
+		DocumentID: pgtype.Int4{Int32: 42, Valid: true},
+		Ip:         pgtype.Text{String: "203.0.113.7", Valid: true},
+		UserAgent:  pgtype.Text{String: `=HYPERLINK("http://evil.example","x")`, Valid: true},
+		RequestID:  pgtype.Text{String: "@SUM(1+1)", Valid: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want the header and one event", len(rows))
+	}
+	row := rows[1]
+	if row[0] != "1" || row[4] != "42" || row[5] != "203.0.113.7" {
+		t.Errorf("plain cells changed: %q", row)
+	}
+	if row[6] != `'=HYPERLINK("http://evil.example","x")` || row[7] != "'@SUM(1+1)" {
+		t.Errorf("got user agent %q and request ID %q, want them quoted", row[6], row[7])
+	}
+}
+
+func TestCSVCells(t *testing.T) {
+	for in, want := range map[string]string{
+		"":         "",
+		"curl/8.5": "curl/8.5",
+		"=1+1":     "'=1+1",
+		"+1":       "'+1",
+		"-1":       "'-1",
+		"@A1":      "'@A1",
+		"\tcmd":    "'\tcmd",
+		"\r=1":     "'\r=1",
+		"a=1":      "a=1",
+	} {
+		if got := csvCells(in)[0]; got != want {
+			t.Errorf("csvCells(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wilbyang/law-docs/internal/audit"
+	entity "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/models"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// Comment events sent over the websocket hub.
+const (
+	CommentCreated  = "comment.created"
+	CommentResolved = "comment.resolved"
+	CommentReopened = "comment.reopened"
+)
+
+// AnchorRequest pins a new thread to a character range of the content (type text, rune offsets)
+// or to a rectangle on a page of the uploaded file (type pdf, coordinates from 0 to 1).
+type AnchorRequest struct {
+	Type   string  `json:"type" binding:"required,oneof=text pdf" example:"text"`
+	Start  int     `json:"start" binding:"min=0" example:"120"`
+	End    int     `json:"end" binding:"min=0" example:"184"`
+	Page   int     `json:"page" binding:"min=0" example:"3"`
+	X      float64 `json:"x" binding:"min=0,max=1"`
+	Y      float64 `json:"y" binding:"min=0,max=1"`
+	Width  float64 `json:"width" binding:"min=0,max=1"`
+	Height float64 `json:"height" binding:"min=0,max=1"`
+}
+
+// CreateCommentRequest is the body of POST /api/v1/docs/{id}/comments. Without ParentID it starts
+// a thread, which may be anchored; with ParentID it replies to that comment's thread.
+type CreateCommentRequest struct {
+	Body     string         `json:"body" binding:"required,max=10000" example:"Clause 4 conflicts with the lease term."`
+	ParentID int32          `json:"parent_id" binding:"omitempty,min=1"`
+	Anchor   *AnchorRequest `json:"anchor"`
+	Mentions []int32        `json:"mentions" binding:"max=50,dive,min=1" example:"2"`
+}
+
+// ListCommentsQuery holds the query parameters of GET /api/v1/docs/{id}/comments.
+type ListCommentsQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=open resolved"`
+}
+
+// ListMentionsQuery holds the query parameters of GET /api/v1/mentions.
+type ListMentionsQuery struct {
+	Limit int32 `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// CommentResponse is one comment. Anchor and the resolution fields are only set on the first
+// comment of a thread.
+type CommentResponse struct {
+	ID         int32          `json:"id" validate:"required" example:"11"`
+	DocumentID int32          `json:"document_id" validate:"required" example:"42"`
+	ParentID   *int32         `json:"parent_id,omitempty"`
+	AuthorID   int32          `json:"author_id" validate:"required" example:"1"`
+	Body       string         `json:"body" validate:"required"`
+	Anchor     *models.Anchor `json:"anchor,omitempty"`
+	Mentions   []int32        `json:"mentions,omitempty" example:"2"`
+	CreatedAt  time.Time      `json:"created_at" validate:"required" format:"date-time"`
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty" format:"date-time"`
+	ResolvedBy *int32         `json:"resolved_by,omitempty"`
+}
+
+// CommentThreadResponse is a thread: its first comment and the replies in order.
+type CommentThreadResponse struct {
+	Comment CommentResponse   `json:"comment" validate:"required"`
+	Replies []CommentResponse `json:"replies" validate:"required"`
+}
+
+// CommentThreadListResponse is returned by GET /api/v1/docs/{id}/comments.
+type CommentThreadListResponse struct {
+	Threads []CommentThreadResponse `json:"threads" validate:"required"`
+}
+
+// CommentListResponse is returned by GET /api/v1/mentions.
+type CommentListResponse struct {
+	Comments []CommentResponse `json:"comments" validate:"required"`
+}
+
+// CommentEvent is the payload published to the comment topics of the websocket hub. Events only
+// carry IDs; clients fetch the comment through the API, which applies its own permissions.
+type CommentEvent struct {
+	Type       string `json:"type"`
+	DocumentID int32  `json:"document_id"`
+	ThreadID   int32  `json:"thread_id"`
+	CommentID  int32  `json:"comment_id"`
+}
+
+// commentTopic is the hub topic with every comment event of a document.
+func commentTopic(tenantID, documentID int32) string {
+	return fmt.Sprintf("tenants.%d.docs.%d.comments", tenantID, documentID)
+}
+
+// mentionTopic is the hub topic with the comments that mention a user.
+func mentionTopic(tenantID, userID int32) string {
+	return fmt.Sprintf("tenants.%d.users.%d.mentions", tenantID, userID)
+}
+
+func commentResponse(c entity.Comment, mentions map[int32][]int32) CommentResponse {
+	resp := CommentResponse{
+		ID:         c.ID,
+		DocumentID: c.DocumentID,
+		AuthorID:   c.AuthorID,
+		Body:       c.Body,
+		Anchor:     c.Anchor,
+		Mentions:   mentions[c.ID],
+		CreatedAt:  c.CreatedAt.Time,
+	}
+	if c.ParentID.Valid {
+		resp.ParentID = &c.ParentID.Int32
+	}
+	if c.ResolvedAt.Valid {
+		resp.ResolvedAt = &c.ResolvedAt.Time
+	}
+	if c.ResolvedBy.Valid {
+		resp.ResolvedBy = &c.ResolvedBy.Int32
+	}
+	return resp
+}
+
+// commentMentions loads the mentioned user IDs of comments, keyed by comment ID.
+func commentMentions(ctx context.Context, q *entity.Queries, comments ...entity.Comment) (map[int32][]int32, error) {
+	ids := make([]int32, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	rows, err := q.ListCommentMentions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mentions := make(map[int32][]int32, len(rows))
+	for _, row := range rows {
+		mentions[row.CommentID] = append(mentions[row.CommentID], row.UserID)
+	}
+	return mentions, nil
+}
+
+// anchor validates a thread anchor against the document it points into.
+func anchor(req *AnchorRequest, document entity.Document) (*models.Anchor, error) {
+	if req == nil {
+		return nil, nil
+	}
+	switch req.Type {
+	case "text":
+		runes := []rune(document.Content)
+		if req.End <= req.Start || req.End > len(runes) {
+			return nil, fieldError{Field: "anchor.end", Message: fmt.Sprintf("must be after start and at most %d", len(runes))}
+		}
+		return &models.Anchor{Type: req.Type, Start: req.Start, End: req.End, Quote: string(runes[req.Start:req.End])}, nil
+	default:
+		if !document.FilePath.Valid {
+			return nil, fieldError{Field: "anchor.type", Message: "must be text for documents without an uploaded file"}
+		}
+		if req.Page < 1 {
+			return nil, fieldError{Field: "anchor.page", Message: "must be at least 1"}
+		}
+		if req.Width == 0 || req.Height == 0 || req.X+req.Width > 1 || req.Y+req.Height > 1 {
+			return nil, fieldError{Field: "anchor.width", Message: "must describe a non-empty rectangle within the page"}
+		}
+		return &models.Anchor{Type: req.Type, Page: req.Page, X: req.X, Y: req.Y, Width: req.Width, Height: req.Height}, nil
+	}
+}
+
+// @Summary List comment threads
+// @ID listComments
+// @Description Get the comment threads of a document, oldest first, optionally only open or resolved ones
+// @Tags comments
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Param status query string false "Thread status" Enums(open, resolved)
+// @Produce json
+// @Success 200 {object} CommentThreadListResponse
+// @Failure 400 {object} Problem "Invalid ID or query"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 500 {object} Problem "Failed to list comments"
+// @Router /api/v1/docs/{id}/comments [get]
+func (api *API) listComments(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var query ListCommentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	var comments []entity.Comment
+	var mentions map[int32][]int32
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetDocumentById(ctx, uri.ID); err != nil {
+			return err
+		}
+		var err error
+		comments, err = q.ListComments(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		mentions, err = commentMentions(ctx, q, comments...)
+		return err
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "list comments")
+		return
+	}
+
+	resp := CommentThreadListResponse{Threads: []CommentThreadResponse{}}
+	threads := map[int32]int{}
+	for _, comment := range comments {
+		if !comment.ParentID.Valid {
+			if query.Status == "open" && comment.ResolvedAt.Valid || query.Status == "resolved" && !comment.ResolvedAt.Valid {
+				continue
+			}
+			threads[comment.ID] = len(resp.Threads)
+			resp.Threads = append(resp.Threads, CommentThreadResponse{
+				Comment: commentResponse(comment, mentions),
+				Replies: []CommentResponse{},
+			})
+			continue
+		}
+		// replies follow their thread's first comment because IDs only grow
+		if i, ok := threads[comment.ParentID.Int32]; ok {
+			resp.Threads[i].Replies = append(resp.Threads[i].Replies, commentResponse(comment, mentions))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Comment on a document
+// @ID createComment
+// @Description Start a comment thread, optionally anchored to a text range or PDF region, or reply to one. Mentioned users and viewers of the document are notified over the websocket hub.
+// @Tags comments
+// @Security BearerAuth
+// @Accept json
+// @Param id path int true "Document ID" minimum(1)
+// @Param comment body CreateCommentRequest true "Comment"
+// @Produce json
+// @Success 201 {object} CommentResponse
+// @Failure 400 {object} Problem "Invalid input, anchor, parent or mention"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 500 {object} Problem "Failed to create comment"
+// @Router /api/v1/docs/{id}/comments [post]
+func (api *API) createComment(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	caller, _ := tenant.FromContext(ctx)
+	mentioned := uniqueIDs(req.Mentions)
+	var comment entity.Comment
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		document, err := q.GetDocumentById(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		params := entity.CreateCommentParams{DocumentID: document.ID, AuthorID: caller.UserID, Body: req.Body}
+		if req.ParentID != 0 {
+			if req.Anchor != nil {
+				return fieldError{Field: "anchor", Message: "is only allowed on the first comment of a thread"}
+			}
+			parent, err := q.GetComment(ctx, req.ParentID)
+			if errors.Is(err, pgx.ErrNoRows) || (err == nil && parent.DocumentID != document.ID) {
+				return fieldError{Field: "parent_id", Message: "is not a comment on this document"}
+			}
+			if err != nil {
+				return err
+			}
+			// replies to a reply join the same thread
+			params.ParentID = pgtype.Int4{Int32: parent.ID, Valid: true}
+			if parent.ParentID.Valid {
+				params.ParentID = parent.ParentID
+			}
+		}
+		if params.Anchor, err = anchor(req.Anchor, document); err != nil {
+			return err
+		}
+		if err := checkUsers(ctx, q, mentioned); err != nil {
+			return err
+		}
+		comment, err = q.CreateComment(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(mentioned) > 0 {
+			err := q.CreateCommentMentions(ctx, entity.CreateCommentMentionsParams{CommentID: comment.ID, UserIds: mentioned})
+			if err != nil {
+				return err
+			}
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionComment, DocumentID: document.ID, After: commentResponse(comment, nil)})
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "create comment")
+		return
+	}
+	api.publishComment(caller.TenantID, CommentCreated, comment, mentioned)
+	c.JSON(http.StatusCreated, commentResponse(comment, map[int32][]int32{comment.ID: mentioned}))
+}
+
+// checkUsers returns a fieldError for mentions that are not users of the tenant.
+func checkUsers(ctx context.Context, q *entity.Queries, ids []int32) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	found, err := q.ListUserIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, ok := slices.BinarySearch(found, id); !ok {
+			return fieldError{Field: "mentions", Message: fmt.Sprintf("user %d does not exist", id)}
+		}
+	}
+	return nil
+}
+
+// @Summary Resolve a comment thread
+// @ID resolveComment
+// @Description Mark the thread of a comment as resolved
+// @Tags comments
+// @Security BearerAuth
+// @Param id path int true "Comment ID" minimum(1)
+// @Produce json
+// @Success 200 {object} CommentResponse "The first comment of the thread"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Comment not found"
+// @Failure 500 {object} Problem "Failed to resolve comment"
+// @Router /api/v1/comments/{id}/resolve [post]
+func (api *API) resolveComment(c *gin.Context) {
+	api.setCommentResolved(c, true)
+}
+
+// @Summary Reopen a comment thread
+// @ID reopenComment
+// @Description Mark the resolved thread of a comment as open again
+// @Tags comments
+// @Security BearerAuth
+// @Param id path int true "Comment ID" minimum(1)
+// @Produce json
+// @Success 200 {object} CommentResponse "The first comment of the thread"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Comment not found"
+// @Failure 500 {object} Problem "Failed to reopen comment"
+// @Router /api/v1/comments/{id}/reopen [post]
+func (api *API) reopenComment(c *gin.Context) {
+	api.setCommentResolved(c, false)
+}
+
+func (api *API) setCommentResolved(c *gin.Context, resolved bool) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	caller, _ := tenant.FromContext(ctx)
+	action, event := "reopen comment", CommentReopened
+	params := entity.SetCommentResolvedParams{}
+	if resolved {
+		action, event = "resolve comment", CommentResolved
+		params.ResolvedAt = pgtype.Timestamp{Time: time.Now(), Valid: true}
+		params.ResolvedBy = pgtype.Int4{Int32: caller.UserID, Valid: true}
+	}
+	var thread entity.Comment
+	var mentions map[int32][]int32
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		comment, err := q.GetComment(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		params.ID = comment.ID
+		if comment.ParentID.Valid {
+			params.ID = comment.ParentID.Int32
+		}
+		before, err := q.GetComment(ctx, params.ID)
+		if err != nil {
+			return err
+		}
+		thread, err = q.SetCommentResolved(ctx, params)
+		if err != nil {
+			return err
+		}
+		mentions, err = commentMentions(ctx, q, thread)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, audit.Event{
+			Action:     audit.ActionComment,
+			DocumentID: thread.DocumentID,
+			Before:     commentResponse(before, mentions),
+			After:      commentResponse(thread, mentions),
+		})
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Comment", uri.ID, action)
+		return
+	}
+	api.publishComment(caller.TenantID, event, thread, nil)
+	c.JSON(http.StatusOK, commentResponse(thread, mentions))
+}
+
+// @Summary List comments mentioning the caller
+// @ID listMentions
+// @Description Get the comments that mention the caller, newest first
+// @Tags comments
+// @Security BearerAuth
+// @Param limit query int false "Maximum number of comments, 50 by default" minimum(1) maximum(200)
+// @Produce json
+// @Success 200 {object} CommentListResponse
+// @Failure 400 {object} Problem "Invalid query"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to list mentions"
+// @Router /api/v1/mentions [get]
+func (api *API) listMentions(c *gin.Context) {
+	var query ListMentionsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+	ctx := c.Request.Context()
+	caller, _ := tenant.FromContext(ctx)
+	var comments []entity.Comment
+	var mentions map[int32][]int32
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		comments, err = q.ListMentionedComments(ctx, entity.ListMentionedCommentsParams{UserID: caller.UserID, MaxRows: query.Limit})
+		if err != nil {
+			return err
+		}
+		mentions, err = commentMentions(ctx, q, comments...)
+		return err
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "list mentions")
+		return
+	}
+	resp := CommentListResponse{Comments: make([]CommentResponse, 0, len(comments))}
+	for _, comment := range comments {
+		resp.Comments = append(resp.Comments, commentResponse(comment, mentions))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// publishComment notifies viewers of the document and the mentioned users of a committed change.
+func (api *API) publishComment(tenantID int32, eventType string, comment entity.Comment, mentioned []int32) {
+	event := CommentEvent{Type: eventType, DocumentID: comment.DocumentID, ThreadID: comment.ID, CommentID: comment.ID}
+	if comment.ParentID.Valid {
+		event.ThreadID = comment.ParentID.Int32
+	}
+	api.hub.Publish(commentTopic(tenantID, comment.DocumentID), event)
+	for _, userID := range mentioned {
+		api.hub.Publish(mentionTopic(tenantID, userID), event)
+	}
+}
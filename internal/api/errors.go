@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// Stable error codes returned in Problem.Code. Clients may switch on these;
+// never change the meaning of an existing code.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeInvalidTransition  = "invalid_status_transition"
+	CodeConflict           = "conflict"
+	CodeDocumentLocked     = "document_locked"
+	CodeStorageFailed      = "storage_failed"
+	CodeNotificationFailed = "notification_failed"
+	CodeInternal           = "internal_error"
+)
+
+const problemContentType = "application/problem+json"
+
+func init() {
+	// report fields by their JSON, form or URI names rather than Go field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form", "uri"} {
+				if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type      string       `json:"type" validate:"required" example:"/problems/validation_failed"`
+	Title     string       `json:"title" validate:"required" example:"Bad Request"`
+	Status    int          `json:"status" validate:"required" example:"400"`
+	Detail    string       `json:"detail,omitempty" example:"Request body failed validation"`
+	Instance  string       `json:"instance,omitempty" example:"/api/v1/docs"`
+	Code      string       `json:"code" validate:"required" example:"validation_failed"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes one invalid input field.
+type FieldError struct {
+	Field   string `json:"field" validate:"required" example:"title"`
+	Message string `json:"message" validate:"required" example:"is required"`
+}
+
+// problem aborts the request with an RFC 7807 response.
+func problem(c *gin.Context, status int, code, detail string, fieldErrors ...FieldError) {
+	body := Problem{
+		Type:      "/problems/" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestID(c.Request.Context()),
+		Errors:    fieldErrors,
+	}
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// bindProblem reports a request binding failure, listing individual fields for validation errors.
+func bindProblem(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		problem(c, http.StatusBadRequest, CodeInvalidRequest, "Request could not be parsed: "+err.Error())
+		return
+	}
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{Field: fe.Field(), Message: validationMessage(fe)})
+	}
+	problem(c, http.StatusBadRequest, CodeValidationFailed, "Request failed validation", fields...)
+}
+
+// notFound and methodNotAllowed answer unmatched routes with problems instead of gin's plain text.
+func notFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeNotFound, "No route matches "+c.Request.Method+" "+c.Request.URL.Path)
+}
+
+func methodNotAllowed(c *gin.Context) {
+	problem(c, http.StatusMethodNotAllowed, CodeMethodNotAllowed, c.Request.Method+" is not allowed on "+c.Request.URL.Path)
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return "must be at most " + fe.Param() + lengthUnit(fe.Kind(), fe.Param())
+	case "min":
+		return "must be at least " + fe.Param() + lengthUnit(fe.Kind(), fe.Param())
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		return "failed the " + fe.Tag() + " check"
+	}
+}
+
+// lengthUnit names what min and max count for a field of kind: characters of strings, items of
+// lists and maps, and nothing for numbers, whose value they bound.
+func lengthUnit(kind reflect.Kind, bound string) string {
+	var unit string
+	switch kind {
+	case reflect.String:
+		unit = " character"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " item"
+	default:
+		return ""
+	}
+	if bound != "1" {
+		unit += "s"
+	}
+	return unit
+}
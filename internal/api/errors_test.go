@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestValidationMessage(t *testing.T) {
+	v := validator.New()
+	v.SetTagName("binding")
+	req := struct {
+		Title string            `binding:"max=3"`
+		Tags  []string          `binding:"max=2,dive,min=2"`
+		Meta  map[string]string `binding:"min=1"`
+		Page  int               `binding:"max=5"`
+	}{Title: "Lease", Tags: []string{"a", "bb", "cc"}, Page: 6}
+
+	var errs validator.ValidationErrors
+	if !errors.As(v.Struct(req), &errs) {
+		t.Fatal("expected validation errors")
+	}
+	got := map[string]string{}
+	for _, fe := range errs {
+		got[fe.Field()] = validationMessage(fe)
+	}
+	for field, want := range map[string]string{
+		"Title": "must be at most 3 characters",
+		"Tags":  "must be at most 2 items",
+		"Meta":  "must be at least 1 item",
+		"Page":  "must be at most 5",
+	} {
+		if got[field] != want {
+			t.Errorf("%s: got %q, want %q", field, got[field], want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/wilbyang/law-docs/internal/events"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// EventsQuery holds the query parameters of GET /api/v1/events.
+type EventsQuery struct {
+	Types       []string `form:"type" binding:"max=3,dive,oneof=document.created document.updated document.status_changed"`
+	LastEventID string   `form:"last_event_id"`
+}
+
+// @Summary Stream document changes
+// @ID streamEvents
+// @Description Server-sent events for changes to the tenant's documents: document.created, document.updated and document.status_changed. Each event has an id and its data is a DocumentEvent. A client that reconnects with the Last-Event-ID header, or the last_event_id parameter, receives the events it missed. If they are no longer available a reset event is sent first and the client should reload. Idle streams receive a comment every 15 seconds.
+// @Tags events
+// @Security BearerAuth
+// @Param type query []string false "Only these event types" collectionFormat(multi) Enums(document.created, document.updated, document.status_changed)
+// @Param last_event_id query string false "Resume after this event ID, for clients that cannot send Last-Event-ID"
+// @Param Last-Event-ID header string false "Resume after this event ID"
+// @Produce text/event-stream
+// @Success 200 {object} events.DocumentEvent "Event stream"
+// @Failure 400 {object} Problem "Invalid query"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Router /api/v1/events [get]
+func (api *API) streamEvents(c *gin.Context) {
+	var query EventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	caller, _ := tenant.FromContext(ctx)
+	topics := []string{events.Topic(caller.TenantID, "*")}
+	if len(query.Types) > 0 {
+		topics = topics[:0]
+		for _, t := range query.Types {
+			topics = append(topics, events.Topic(caller.TenantID, t))
+		}
+	}
+	if err := api.events.Serve(c.Writer, c.Request, topics); err != nil {
+		api.log.DebugContext(ctx, "Event stream ended", "error", err)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wilbyang/law-docs/internal/buildinfo"
+	"github.com/wilbyang/law-docs/internal/health"
+)
+
+// HealthResponse is returned by the liveness and readiness probes.
+type HealthResponse struct {
+	Status string `json:"status" validate:"required" example:"ok"`
+}
+
+// StatusResponse describes every dependency and the running build.
+type StatusResponse struct {
+	Status       string          `json:"status" validate:"required" example:"ok"`
+	ShuttingDown bool            `json:"shutting_down" validate:"required"`
+	Build        buildinfo.Info  `json:"build" validate:"required"`
+	Dependencies []health.Result `json:"dependencies" validate:"required"`
+}
+
+// @Summary Liveness probe
+// @ID healthz
+// @Description Reports that the process is running; it does not check dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Router /healthz [get]
+func (api *API) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// @Summary Readiness probe
+// @ID readyz
+// @Description Reports whether Postgres, S3 and SQS are reachable and the server is not shutting down
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /readyz [get]
+func (api *API) readyz(c *gin.Context) {
+	if ready, _ := api.health.Ready(c.Request.Context()); !ready {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: "unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// @Summary Dependency status
+// @ID getStatus
+// @Description Detailed status of every dependency with latency, plus build information
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 503 {object} StatusResponse
+// @Router /api/v1/admin/status [get]
+func (api *API) adminStatus(c *gin.Context) {
+	ready, results := api.health.Ready(c.Request.Context())
+	resp := StatusResponse{
+		Status:       "ok",
+		ShuttingDown: api.health.ShuttingDown(),
+		Build:        buildinfo.Get(),
+		Dependencies: results,
+	}
+	code := http.StatusOK
+	if !ready {
+		resp.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, resp)
+}
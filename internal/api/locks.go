@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	entity "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// defaultLockTTL is how long an edit lock lasts unless renewed.
+const defaultLockTTL = 2 * time.Minute
+
+// Lock events sent over the websocket hub.
+const (
+	DocumentLocked   = "document.locked"
+	DocumentUnlocked = "document.unlocked"
+)
+
+// LockQuery holds the query parameters of PUT /api/v1/docs/{id}/lock.
+type LockQuery struct {
+	TTL int `form:"ttl" binding:"omitempty,min=10,max=900" example:"120"`
+}
+
+// LockResponse is an advisory edit lock on a document.
+type LockResponse struct {
+	DocumentID int32     `json:"document_id" validate:"required" example:"42"`
+	UserID     int32     `json:"user_id" validate:"required" example:"7"`
+	AcquiredAt time.Time `json:"acquired_at" validate:"required" format:"date-time"`
+	ExpiresAt  time.Time `json:"expires_at" validate:"required" format:"date-time"`
+}
+
+// PresenceSessionResponse is one connection that has a document open over the websocket hub.
+type PresenceSessionResponse struct {
+	Session   string          `json:"session" validate:"required" example:"6f1c0e9a2b7d4c3e8f5a1b2c"`
+	UserID    int32           `json:"user_id" validate:"required" example:"7"`
+	State     string          `json:"state" validate:"required" enums:"viewing,editing"`
+	Cursor    json.RawMessage `json:"cursor,omitempty" swaggertype:"object"`
+	Selection json.RawMessage `json:"selection,omitempty" swaggertype:"object"`
+	SeenAt    time.Time       `json:"seen_at" validate:"required" format:"date-time"`
+}
+
+// PresenceResponse is returned by GET /api/v1/docs/{id}/presence. Lock is omitted when the
+// document is not locked.
+type PresenceResponse struct {
+	Sessions []PresenceSessionResponse `json:"sessions" validate:"required"`
+	Lock     *LockResponse             `json:"lock,omitempty"`
+}
+
+// LockEvent is published to the lock topic of a document when it is locked or unlocked.
+// Renewals and expiry are not announced; clients compare ExpiresAt with the clock.
+type LockEvent struct {
+	Type       string     `json:"type"`
+	DocumentID int32      `json:"document_id"`
+	UserID     int32      `json:"user_id"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// lockTopic is the hub topic with the lock events of a document.
+func lockTopic(tenantID, documentID int32) string {
+	return fmt.Sprintf("tenants.%d.docs.%d.lock", tenantID, documentID)
+}
+
+// lockedError reports a document locked by another user.
+type lockedError struct {
+	documentID, userID int32
+	expiresAt          time.Time
+}
+
+func (e lockedError) Error() string {
+	return fmt.Sprintf("Document %d is being edited by user %d until %s", e.documentID, e.userID, e.expiresAt.Format(time.RFC3339))
+}
+
+// @Summary Lock a document for editing
+// @ID lockDocument
+// @Description Take or renew the advisory edit lock of a document. Updates by other users are refused while it is held, unless they force them. Holders should renew before expires_at.
+// @Tags documents
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Param ttl query int false "Seconds until the lock expires" minimum(10) maximum(900) default(120)
+// @Produce json
+// @Success 200 {object} LockResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 409 {object} Problem "Locked by another user"
+// @Failure 500 {object} Problem "Failed to lock document"
+// @Router /api/v1/docs/{id}/lock [put]
+func (api *API) lockDocument(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var query LockQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ttl := defaultLockTTL
+	if query.TTL > 0 {
+		ttl = time.Duration(query.TTL) * time.Second
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	caller, _ := tenant.FromContext(ctx)
+	now := lockNow()
+	var lock entity.DocumentLock
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetDocumentById(ctx, uri.ID); err != nil {
+			return err
+		}
+		var err error
+		lock, err = q.AcquireDocumentLock(ctx, entity.AcquireDocumentLockParams{
+			DocumentID: uri.ID,
+			UserID:     caller.UserID,
+			AcquiredAt: pgtype.Timestamp{Time: now, Valid: true},
+			ExpiresAt:  pgtype.Timestamp{Time: now.Add(ttl), Valid: true},
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// held by someone else and not expired
+			return checkLock(ctx, q, uri.ID, caller.UserID)
+		}
+		return err
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "lock document")
+		return
+	}
+	resp := lockResponse(lock)
+	if resp.AcquiredAt.Equal(now) {
+		api.hub.Publish(lockTopic(caller.TenantID, uri.ID), LockEvent{
+			Type:       DocumentLocked,
+			DocumentID: uri.ID,
+			UserID:     caller.UserID,
+			ExpiresAt:  &resp.ExpiresAt,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Unlock a document
+// @ID unlockDocument
+// @Description Release the caller's edit lock of a document. Releasing a lock the caller does not hold does nothing.
+// @Tags documents
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Success 204 "Lock released"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 500 {object} Problem "Failed to unlock document"
+// @Router /api/v1/docs/{id}/lock [delete]
+func (api *API) unlockDocument(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	caller, _ := tenant.FromContext(ctx)
+	var released int64
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetDocumentById(ctx, uri.ID); err != nil {
+			return err
+		}
+		var err error
+		released, err = q.ReleaseDocumentLock(ctx, entity.ReleaseDocumentLockParams{DocumentID: uri.ID, UserID: caller.UserID})
+		return err
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "unlock document")
+		return
+	}
+	if released > 0 {
+		api.hub.Publish(lockTopic(caller.TenantID, uri.ID), LockEvent{
+			Type:       DocumentUnlocked,
+			DocumentID: uri.ID,
+			UserID:     caller.UserID,
+		})
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Show who has a document open
+// @ID getDocumentPresence
+// @Description Get the websocket sessions present on a document and its edit lock, if any
+// @Tags documents
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Produce json
+// @Success 200 {object} PresenceResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 500 {object} Problem "Failed to get presence"
+// @Router /api/v1/docs/{id}/presence [get]
+func (api *API) getDocumentPresence(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	caller, _ := tenant.FromContext(ctx)
+	var resp PresenceResponse
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetDocumentById(ctx, uri.ID); err != nil {
+			return err
+		}
+		lock, err := q.GetDocumentLock(ctx, uri.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if lock.ExpiresAt.Time.After(lockNow()) {
+			l := lockResponse(lock)
+			resp.Lock = &l
+		}
+		return nil
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "get presence")
+		return
+	}
+	entries := api.presence.List(caller.TenantID, uri.ID)
+	resp.Sessions = make([]PresenceSessionResponse, 0, len(entries))
+	for _, e := range entries {
+		resp.Sessions = append(resp.Sessions, PresenceSessionResponse{
+			Session:   e.Session,
+			UserID:    e.UserID,
+			State:     e.State,
+			Cursor:    e.Cursor,
+			Selection: e.Selection,
+			SeenAt:    e.SeenAt,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// checkLock returns a lockedError when another user holds an unexpired lock on the document.
+func checkLock(ctx context.Context, q *entity.Queries, documentID, userID int32) error {
+	lock, err := q.GetDocumentLock(ctx, documentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lock.UserID != userID && lock.ExpiresAt.Time.After(lockNow()) {
+		return lockedError{documentID: documentID, userID: lock.UserID, expiresAt: lock.ExpiresAt.Time}
+	}
+	return nil
+}
+
+// lockNow returns the current time as lock timestamps store it: UTC, in microseconds.
+func lockNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func lockResponse(l entity.DocumentLock) LockResponse {
+	return LockResponse{
+		DocumentID: l.DocumentID,
+		UserID:     l.UserID,
+		AcquiredAt: l.AcquiredAt.Time,
+		ExpiresAt:  l.ExpiresAt.Time,
+	}
+}
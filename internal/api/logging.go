@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// LogLevelsResponse lists the default log level and per-component overrides.
+type LogLevelsResponse struct {
+	Default    string            `json:"default" validate:"required" example:"INFO"`
+	Components map[string]string `json:"components" validate:"required"`
+}
+
+// SetLogLevelRequest changes the level of one component, or the default when Component is empty.
+type SetLogLevelRequest struct {
+	Component string `json:"component" binding:"max=64" example:"processor"`
+	Level     string `json:"level" binding:"required,oneof=debug info warn error" example:"debug"`
+}
+
+func logLevels() LogLevelsResponse {
+	def, overrides := logging.Levels()
+	resp := LogLevelsResponse{Default: def.String(), Components: map[string]string{}}
+	for name, level := range overrides {
+		resp.Components[name] = level.String()
+	}
+	return resp
+}
+
+// @Summary Get log levels
+// @ID getLogLevels
+// @Description Get the default log level and per-component overrides
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} LogLevelsResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Router /api/v1/admin/log-levels [get]
+func (api *API) getLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, logLevels())
+}
+
+// @Summary Set a log level
+// @ID setLogLevel
+// @Description Change the log level of a component at runtime, or the default level when component is empty
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param level body SetLogLevelRequest true "Component and level (debug, info, warn, error)"
+// @Produce json
+// @Success 200 {object} LogLevelsResponse
+// @Failure 400 {object} Problem "Invalid level"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Router /api/v1/admin/log-levels [put]
+func (api *API) setLogLevel(c *gin.Context) {
+	var req SetLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	level := logging.ParseLevel(req.Level)
+	logging.SetLevel(req.Component, level)
+	api.log.InfoContext(c.Request.Context(), "Log level changed", "target", req.Component, "level", level)
+	c.JSON(http.StatusOK, logLevels())
+}
+
+// @Summary Reset a log level
+// @ID resetLogLevel
+// @Description Remove a component's override so it follows the default level again
+// @Tags admin
+// @Security BearerAuth
+// @Param component path string true "Component name"
+// @Produce json
+// @Success 200 {object} LogLevelsResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Router /api/v1/admin/log-levels/{component} [delete]
+func (api *API) resetLogLevel(c *gin.Context) {
+	logging.ResetLevel(c.Param("component"))
+	c.JSON(http.StatusOK, logLevels())
+}
@@ -0,0 +1,610 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wilbyang/law-docs/internal/audit"
+	entity "github.com/wilbyang/law-docs/internal/db"
+)
+
+// MatterRequest is the body of POST /api/v1/matters and PUT /api/v1/matters/{id}.
+type MatterRequest struct {
+	Name        string `json:"name" binding:"required,max=200" example:"Smith v. Jones"`
+	Reference   string `json:"reference" binding:"max=100" example:"2026-CV-0142"`
+	Description string `json:"description" binding:"max=5000"`
+}
+
+// MatterResponse is a matter (case) that groups documents.
+type MatterResponse struct {
+	ID          int32     `json:"id" validate:"required" example:"3"`
+	Name        string    `json:"name" validate:"required" example:"Smith v. Jones"`
+	Reference   string    `json:"reference,omitempty" example:"2026-CV-0142"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at" validate:"required" format:"date-time"`
+	UpdatedAt   time.Time `json:"updated_at" validate:"required" format:"date-time"`
+}
+
+// MatterListResponse is returned by GET /api/v1/matters.
+type MatterListResponse struct {
+	Matters []MatterResponse `json:"matters" validate:"required"`
+}
+
+// FolderRequest is the body of POST /api/v1/matters/{id}/folders and PUT /api/v1/folders/{id}.
+// Omit ParentID for a top-level folder.
+type FolderRequest struct {
+	Name     string `json:"name" binding:"required,max=200" example:"Discovery"`
+	ParentID int32  `json:"parent_id" binding:"omitempty,min=1" example:"7"`
+}
+
+// FolderResponse is a folder within a matter.
+type FolderResponse struct {
+	ID        int32     `json:"id" validate:"required" example:"8"`
+	MatterID  int32     `json:"matter_id" validate:"required" example:"3"`
+	ParentID  *int32    `json:"parent_id,omitempty" example:"7"`
+	Name      string    `json:"name" validate:"required" example:"Discovery"`
+	CreatedAt time.Time `json:"created_at" validate:"required" format:"date-time"`
+}
+
+// FolderListResponse is returned by GET /api/v1/matters/{id}/folders. Parents come before their
+// children, so clients can build the tree in one pass.
+type FolderListResponse struct {
+	Folders []FolderResponse `json:"folders" validate:"required"`
+}
+
+// MoveDocumentsRequest is the body of POST /api/v1/docs/move. Omit both MatterID and FolderID
+// to take the documents out of their matter.
+type MoveDocumentsRequest struct {
+	DocumentIDs []int32 `json:"document_ids" binding:"required,min=1,max=500,dive,min=1" example:"42,43"`
+	MatterID    int32   `json:"matter_id" binding:"omitempty,min=1" example:"3"`
+	FolderID    int32   `json:"folder_id" binding:"omitempty,min=1" example:"8"`
+}
+
+func matterResponse(m entity.Matter) MatterResponse {
+	return MatterResponse{
+		ID:          m.ID,
+		Name:        m.Name,
+		Reference:   m.Reference.String,
+		Description: m.Description.String,
+		CreatedAt:   m.CreatedAt.Time,
+		UpdatedAt:   m.UpdatedAt.Time,
+	}
+}
+
+func folderResponse(f entity.Folder) FolderResponse {
+	resp := FolderResponse{ID: f.ID, MatterID: f.MatterID, Name: f.Name, CreatedAt: f.CreatedAt.Time}
+	if f.ParentID.Valid {
+		resp.ParentID = &f.ParentID.Int32
+	}
+	return resp
+}
+
+// placement resolves where a document goes. A folder implies its matter; a matter given
+// alongside it must be the same one.
+func placement(ctx context.Context, q *entity.Queries, matterID, folderID int32) (pgtype.Int4, pgtype.Int4, error) {
+	var matter, folder pgtype.Int4
+	switch {
+	case folderID != 0:
+		f, err := q.GetFolder(ctx, folderID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return matter, folder, fieldError{Field: "folder_id", Message: "does not exist"}
+		}
+		if err != nil {
+			return matter, folder, err
+		}
+		if matterID != 0 && f.MatterID != matterID {
+			return matter, folder, fieldError{Field: "folder_id", Message: fmt.Sprintf("is not in matter %d", matterID)}
+		}
+		matter = pgtype.Int4{Int32: f.MatterID, Valid: true}
+		folder = pgtype.Int4{Int32: f.ID, Valid: true}
+	case matterID != 0:
+		_, err := q.GetMatter(ctx, matterID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return matter, folder, fieldError{Field: "matter_id", Message: "does not exist"}
+		}
+		if err != nil {
+			return matter, folder, err
+		}
+		matter = pgtype.Int4{Int32: matterID, Valid: true}
+	}
+	return matter, folder, nil
+}
+
+// organizeProblem answers a failed matter, folder or tag query; kind names the resource and id the
+// one requested. Other errors are reported like document errors.
+func (api *API) organizeProblem(c *gin.Context, ctx context.Context, err error, kind string, id int32, action string) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		problem(c, http.StatusNotFound, CodeNotFound, fmt.Sprintf("%s %d does not exist", kind, id))
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		problem(c, http.StatusConflict, CodeConflict, fmt.Sprintf("A %s with this name already exists", strings.ToLower(kind)))
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		problem(c, http.StatusConflict, CodeConflict, fmt.Sprintf("%s %d still contains documents; move them first", kind, id))
+	default:
+		api.documentProblem(c, ctx, err, action)
+	}
+}
+
+// @Summary List matters
+// @ID listMatters
+// @Description Get the tenant's matters (cases) by name
+// @Tags matters
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} MatterListResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to list matters"
+// @Router /api/v1/matters [get]
+func (api *API) listMatters(c *gin.Context) {
+	ctx := c.Request.Context()
+	var matters []entity.Matter
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		matters, err = q.ListMatters(ctx)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Matter", 0, "list matters")
+		return
+	}
+	resp := MatterListResponse{Matters: make([]MatterResponse, 0, len(matters))}
+	for _, m := range matters {
+		resp.Matters = append(resp.Matters, matterResponse(m))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Create a matter
+// @ID createMatter
+// @Description Create a matter (case) to group documents
+// @Tags matters
+// @Security BearerAuth
+// @Accept json
+// @Param matter body MatterRequest true "Matter details"
+// @Produce json
+// @Success 201 {object} MatterResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 409 {object} Problem "Name already in use"
+// @Failure 500 {object} Problem "Failed to create matter"
+// @Router /api/v1/matters [post]
+func (api *API) createMatter(c *gin.Context) {
+	var req MatterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var matter entity.Matter
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		matter, err = q.CreateMatter(ctx, entity.CreateMatterParams{
+			Name:        req.Name,
+			Reference:   pgtype.Text{String: req.Reference, Valid: req.Reference != ""},
+			Description: pgtype.Text{String: req.Description, Valid: req.Description != ""},
+		})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Matter", 0, "create matter")
+		return
+	}
+	c.JSON(http.StatusCreated, matterResponse(matter))
+}
+
+// @Summary Get a matter
+// @ID getMatter
+// @Tags matters
+// @Security BearerAuth
+// @Param id path int true "Matter ID" minimum(1)
+// @Produce json
+// @Success 200 {object} MatterResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Matter not found"
+// @Failure 500 {object} Problem "Failed to get matter"
+// @Router /api/v1/matters/{id} [get]
+func (api *API) getMatter(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var matter entity.Matter
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		matter, err = q.GetMatter(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Matter", uri.ID, "get matter")
+		return
+	}
+	c.JSON(http.StatusOK, matterResponse(matter))
+}
+
+// @Summary Update a matter
+// @ID updateMatter
+// @Description Replace a matter's name, reference and description
+// @Tags matters
+// @Security BearerAuth
+// @Accept json
+// @Param id path int true "Matter ID" minimum(1)
+// @Param matter body MatterRequest true "Matter details"
+// @Produce json
+// @Success 200 {object} MatterResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Matter not found"
+// @Failure 409 {object} Problem "Name already in use"
+// @Failure 500 {object} Problem "Failed to update matter"
+// @Router /api/v1/matters/{id} [put]
+func (api *API) updateMatter(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req MatterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var matter entity.Matter
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		matter, err = q.UpdateMatter(ctx, entity.UpdateMatterParams{
+			ID:          uri.ID,
+			Name:        req.Name,
+			Reference:   pgtype.Text{String: req.Reference, Valid: req.Reference != ""},
+			Description: pgtype.Text{String: req.Description, Valid: req.Description != ""},
+			UpdatedAt:   pgtype.Timestamp{Time: time.Now(), Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Matter", uri.ID, "update matter")
+		return
+	}
+	c.JSON(http.StatusOK, matterResponse(matter))
+}
+
+// @Summary Delete a matter
+// @ID deleteMatter
+// @Description Delete a matter and its folders. Matters that still hold documents cannot be deleted.
+// @Tags matters
+// @Security BearerAuth
+// @Param id path int true "Matter ID" minimum(1)
+// @Success 204 "Matter deleted"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Matter not found"
+// @Failure 409 {object} Problem "Matter still contains documents"
+// @Failure 500 {object} Problem "Failed to delete matter"
+// @Router /api/v1/matters/{id} [delete]
+func (api *API) deleteMatter(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		_, err := q.DeleteMatter(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Matter", uri.ID, "delete matter")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List folders of a matter
+// @ID listFolders
+// @Description Get every folder of a matter, parents before children
+// @Tags matters
+// @Security BearerAuth
+// @Param id path int true "Matter ID" minimum(1)
+// @Produce json
+// @Success 200 {object} FolderListResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Matter not found"
+// @Failure 500 {object} Problem "Failed to list folders"
+// @Router /api/v1/matters/{id}/folders [get]
+func (api *API) listFolders(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var folders []entity.Folder
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetMatter(ctx, uri.ID); err != nil {
+			return err
+		}
+		var err error
+		folders, err = q.ListFolders(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Matter", uri.ID, "list folders")
+		return
+	}
+	c.JSON(http.StatusOK, FolderListResponse{Folders: sortFolders(folders)})
+}
+
+// sortFolders orders folders depth first so that every parent precedes its children.
+func sortFolders(folders []entity.Folder) []FolderResponse {
+	children := make(map[int32][]entity.Folder, len(folders))
+	for _, f := range folders {
+		children[f.ParentID.Int32] = append(children[f.ParentID.Int32], f)
+	}
+	resp := make([]FolderResponse, 0, len(folders))
+	var walk func(parent int32)
+	walk = func(parent int32) {
+		for _, f := range children[parent] {
+			resp = append(resp, folderResponse(f))
+			walk(f.ID)
+		}
+	}
+	walk(0)
+	return resp
+}
+
+// @Summary Create a folder
+// @ID createFolder
+// @Description Create a folder in a matter, at the top level or inside another folder of the same matter
+// @Tags matters
+// @Security BearerAuth
+// @Accept json
+// @Param id path int true "Matter ID" minimum(1)
+// @Param folder body FolderRequest true "Folder details"
+// @Produce json
+// @Success 201 {object} FolderResponse
+// @Failure 400 {object} Problem "Invalid input or parent"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Matter not found"
+// @Failure 409 {object} Problem "Name already in use in the parent"
+// @Failure 500 {object} Problem "Failed to create folder"
+// @Router /api/v1/matters/{id}/folders [post]
+func (api *API) createFolder(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var folder entity.Folder
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetMatter(ctx, uri.ID); errors.Is(err, pgx.ErrNoRows) {
+			return notFoundError{kind: "Matter", ids: []int32{uri.ID}}
+		} else if err != nil {
+			return err
+		}
+		parent, err := folderParent(ctx, q, uri.ID, 0, req.ParentID)
+		if err != nil {
+			return err
+		}
+		folder, err = q.CreateFolder(ctx, entity.CreateFolderParams{MatterID: uri.ID, ParentID: parent, Name: req.Name})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Folder", 0, "create folder")
+		return
+	}
+	c.JSON(http.StatusCreated, folderResponse(folder))
+}
+
+// folderParent checks that parentID may hold folder id (0 for a new folder) in the matter.
+func folderParent(ctx context.Context, q *entity.Queries, matterID, id, parentID int32) (pgtype.Int4, error) {
+	if parentID == 0 {
+		return pgtype.Int4{}, nil
+	}
+	parent, err := q.GetFolder(ctx, parentID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && parent.MatterID != matterID) {
+		return pgtype.Int4{}, fieldError{Field: "parent_id", Message: "is not a folder of this matter"}
+	}
+	if err != nil {
+		return pgtype.Int4{}, err
+	}
+	if id != 0 {
+		cycle, err := q.IsFolderAncestor(ctx, entity.IsFolderAncestorParams{FolderID: parentID, AncestorID: id})
+		if err != nil {
+			return pgtype.Int4{}, err
+		}
+		if cycle {
+			return pgtype.Int4{}, fieldError{Field: "parent_id", Message: "must not be the folder itself or one of its subfolders"}
+		}
+	}
+	return pgtype.Int4{Int32: parentID, Valid: true}, nil
+}
+
+// @Summary Rename or move a folder
+// @ID updateFolder
+// @Description Replace a folder's name and parent; subfolders and documents move with it
+// @Tags matters
+// @Security BearerAuth
+// @Accept json
+// @Param id path int true "Folder ID" minimum(1)
+// @Param folder body FolderRequest true "Folder details"
+// @Produce json
+// @Success 200 {object} FolderResponse
+// @Failure 400 {object} Problem "Invalid input or parent"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Folder not found"
+// @Failure 409 {object} Problem "Name already in use in the parent"
+// @Failure 500 {object} Problem "Failed to update folder"
+// @Router /api/v1/folders/{id} [put]
+func (api *API) updateFolder(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var folder entity.Folder
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		current, err := q.GetFolder(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		parent, err := folderParent(ctx, q, current.MatterID, current.ID, req.ParentID)
+		if err != nil {
+			return err
+		}
+		folder, err = q.UpdateFolder(ctx, entity.UpdateFolderParams{ID: current.ID, ParentID: parent, Name: req.Name})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Folder", uri.ID, "update folder")
+		return
+	}
+	c.JSON(http.StatusOK, folderResponse(folder))
+}
+
+// @Summary Delete a folder
+// @ID deleteFolder
+// @Description Delete a folder and its subfolders. Folders that still hold documents cannot be deleted.
+// @Tags matters
+// @Security BearerAuth
+// @Param id path int true "Folder ID" minimum(1)
+// @Success 204 "Folder deleted"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Folder not found"
+// @Failure 409 {object} Problem "Folder still contains documents"
+// @Failure 500 {object} Problem "Failed to delete folder"
+// @Router /api/v1/folders/{id} [delete]
+func (api *API) deleteFolder(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		_, err := q.DeleteFolder(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Folder", uri.ID, "delete folder")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Move documents
+// @ID moveDocuments
+// @Description Move documents into a matter or folder in one transaction. Omit matter_id and folder_id to take them out of their matter.
+// @Tags documents
+// @Security BearerAuth
+// @Accept json
+// @Param move body MoveDocumentsRequest true "Documents and destination"
+// @Produce json
+// @Success 200 {object} DocumentListResponse
+// @Failure 400 {object} Problem "Invalid input or destination"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Some documents not found"
+// @Failure 500 {object} Problem "Failed to move documents"
+// @Router /api/v1/docs/move [post]
+func (api *API) moveDocuments(c *gin.Context) {
+	var req MoveDocumentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	ids := uniqueIDs(req.DocumentIDs)
+	var documents []entity.Document
+	var tags map[int32][]string
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		before, err := loadDocuments(ctx, q, ids)
+		if err != nil {
+			return err
+		}
+		matter, folder, err := placement(ctx, q, req.MatterID, req.FolderID)
+		if err != nil {
+			return err
+		}
+		documents, err = q.MoveDocuments(ctx, entity.MoveDocumentsParams{
+			MatterID:  matter,
+			FolderID:  folder,
+			UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			Ids:       ids,
+		})
+		if err != nil {
+			return err
+		}
+		slices.SortFunc(documents, func(a, b entity.Document) int { return int(a.ID - b.ID) })
+		for i, d := range documents {
+			err := audit.Record(ctx, q, audit.Event{
+				Action:     audit.ActionMove,
+				DocumentID: d.ID,
+				Before:     documentResponse(before[i]),
+				After:      documentResponse(d),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		tags, err = documentTags(ctx, q, documents...)
+		return err
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "move documents")
+		return
+	}
+	c.JSON(http.StatusOK, documentListResponse(documents, tags))
+}
+
+// loadDocuments returns the documents with the given sorted ids, or a notFoundError listing
+// the ids that are not visible documents.
+func loadDocuments(ctx context.Context, q *entity.Queries, ids []int32) ([]entity.Document, error) {
+	documents, err := q.ListDocumentsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == len(ids) {
+		return documents, nil
+	}
+	var missing []int32
+	for _, id := range ids {
+		if !slices.ContainsFunc(documents, func(d entity.Document) bool { return d.ID == id }) {
+			missing = append(missing, id)
+		}
+	}
+	return nil, notFoundError{kind: "Document", ids: missing}
+}
+
+// uniqueIDs returns ids sorted and without duplicates.
+func uniqueIDs(ids []int32) []int32 {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wilbyang/law-docs/internal/audit"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/tracing"
+)
+
+// metricsMiddleware records request count and latency labelled by the matched route,
+// so path parameters such as document IDs do not blow up label cardinality.
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+	metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+	metrics.HTTPDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
+
+// tracingMiddleware continues the caller's trace, if any, and wraps the request in a server span.
+// Handlers must use c.Request.Context() for downstream calls to stay in the trace.
+func tracingMiddleware(c *gin.Context) {
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	ctx := tracing.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(c.Request.URL.Path),
+		))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware propagates the caller's X-Request-ID, or generates one,
+// and stores it in the request context for correlated logging.
+func requestIDMiddleware(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if id == "" || len(id) > 128 {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	c.Header(requestIDHeader, id)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
+
+// accessLogMiddleware replaces gin's default logger with one structured record per request.
+func accessLogMiddleware(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		log.LogAttrs(c.Request.Context(), level, "Request handled",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", c.Writer.Status()),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// recoveryMiddleware logs panics through slog instead of gin's default writer.
+func recoveryMiddleware(log *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		log.ErrorContext(c.Request.Context(), "Panic while handling request", "panic", err)
+		problem(c, http.StatusInternalServerError, CodeInternal, "")
+	})
+}
+
+// authMiddleware requires "Authorization: Bearer <token>". An empty token disables the check.
+func authMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		got, ok := bearerToken(c)
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			unauthorized(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// tenantMiddleware resolves the bearer token to a tenant and user and stores them in the request context,
+// which scopes every query the handler makes to that tenant.
+func tenantMiddleware(store *tenant.Store, log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token, ok := bearerToken(c)
+		if !ok {
+			unauthorized(c)
+			return
+		}
+		caller, err := store.Authenticate(ctx, token)
+		if errors.Is(err, tenant.ErrInvalidToken) {
+			unauthorized(c)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(ctx, "Failed to authenticate request", "error", err)
+			problem(c, http.StatusInternalServerError, CodeInternal, "Failed to authenticate request")
+			return
+		}
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Int("tenant.id", int(caller.TenantID)),
+			attribute.Int("user.id", int(caller.UserID)),
+		)
+		c.Request = c.Request.WithContext(tenant.WithPrincipal(ctx, caller))
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of the Authorization header. Browsers cannot set headers on
+// websocket upgrades, so those may pass it as the access_token query parameter instead.
+func bearerToken(c *gin.Context) (string, bool) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok && c.IsWebsocket() {
+		token, ok = c.Query("access_token"), true
+	}
+	return token, ok && token != ""
+}
+
+func unauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="lawdocs"`)
+	problem(c, http.StatusUnauthorized, CodeUnauthorized, "A valid bearer token is required")
+}
+
+// auditMiddleware makes the client address and user agent available to audit events of the request.
+func auditMiddleware(c *gin.Context) {
+	ctx := audit.WithRequest(c.Request.Context(), audit.Request{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wilbyang/law-docs/docs"
+)
+
+// undocumentedRoutes are served but deliberately left out of the OpenAPI document.
+var undocumentedRoutes = map[string]bool{
+	"GET /metrics":      true,
+	"GET /ws":           true,
+	"GET /openapi.json": true,
+	"GET /swagger/*any": true,
+}
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// openAPISpec serves the embedded OpenAPI 3 document.
+func (api *API) openAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", docs.OpenAPI)
+}
+
+// CheckContract compares the routes registered on the router with the embedded OpenAPI document
+// and returns an error describing every route that is served but undocumented, documented but not
+// served, or documented under /api/v1 without the bearer security requirement. It does not look at
+// request or response bodies; TestResponsesMatchContract validates those it can without a database.
+func (api *API) CheckContract() error {
+	spec, err := openapi3.NewLoader().LoadFromData(docs.OpenAPI)
+	if err != nil {
+		return fmt.Errorf("load OpenAPI document: %w", err)
+	}
+	if err := spec.Validate(openapi3.NewLoader().Context); err != nil {
+		return fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	documented := map[string]*openapi3.Operation{}
+	for path, item := range spec.Paths.Map() {
+		for method, op := range item.Operations() {
+			documented[method+" "+path] = op
+		}
+	}
+
+	var problems []string
+	served := map[string]bool{}
+	for _, route := range api.router.Routes() {
+		key := route.Method + " " + route.Path
+		if undocumentedRoutes[key] {
+			continue
+		}
+		key = route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		served[key] = true
+		op, ok := documented[key]
+		if !ok {
+			problems = append(problems, key+" is served but not documented")
+			continue
+		}
+		if strings.HasPrefix(route.Path, "/api/v1/") && (op.Security == nil || len(*op.Security) == 0) {
+			problems = append(problems, key+" is missing the BearerAuth security requirement")
+		}
+	}
+	for key := range documented {
+		if !served[key] {
+			problems = append(problems, key+" is documented but not served")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.Sort(problems)
+	return fmt.Errorf("API and OpenAPI document disagree:\n  %s", strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wilbyang/law-docs/docs"
+	"github.com/wilbyang/law-docs/internal/health"
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// TestContract fails when a route is served but undocumented, documented but not served, or
+// missing its security requirement; run go generate in docs after changing a handler.
+func TestContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewAPI(nil, nil, nil, nil, nil, nil, nil, "")
+	if err := server.CheckContract(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var specParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// TestResponsesMatchContract validates recorded responses against the OpenAPI document: their
+// status must be documented for the operation and their body must match its schema. Only
+// responses that need no database are covered here: the unauthorized answer of every tenant
+// route, and the health and admin routes. What tenant routes return once authenticated is not
+// validated.
+func TestResponsesMatchContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec, err := openapi3.NewLoader().LoadFromData(docs.OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var postgresDown atomic.Bool
+	checker := health.NewChecker(time.Second, 0)
+	checker.Register("postgres", func(context.Context) error {
+		if postgresDown.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	const adminToken = "admin-token"
+	server := NewAPI(nil, nil, nil, nil, nil, nil, checker, adminToken)
+
+	// validate serves a request for the operation documented under method and path, with its
+	// path parameters set to 1, and checks the response against the document
+	validate := func(method, path, token, body string, want int) {
+		t.Helper()
+		item := spec.Paths.Find(path)
+		if item == nil || item.GetOperation(method) == nil {
+			t.Errorf("%s %s is not documented", method, path)
+			return
+		}
+		params := map[string]string{}
+		for _, m := range specParam.FindAllStringSubmatch(path, -1) {
+			params[m[1]] = "1"
+		}
+		url := specParam.ReplaceAllString(path, "1")
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s %s: got status %d, want %d", method, url, w.Code, want)
+			return
+		}
+		route := &routers.Route{Spec: spec, Path: path, PathItem: item, Method: method, Operation: item.GetOperation(method)}
+		err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: &openapi3filter.RequestValidationInput{Request: req, PathParams: params, Route: route},
+			Status:                 w.Code,
+			Header:                 w.Header(),
+			Body:                   io.NopCloser(w.Body),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		})
+		if err != nil {
+			t.Errorf("%s %s answered %d against the document: %v", method, url, w.Code, err)
+		}
+	}
+
+	for path, item := range spec.Paths.Map() {
+		if !strings.HasPrefix(path, "/api/v1/") {
+			continue
+		}
+		for method := range item.Operations() {
+			validate(method, path, "", "", http.StatusUnauthorized)
+		}
+	}
+
+	validate(http.MethodGet, "/healthz", "", "", http.StatusOK)
+	validate(http.MethodGet, "/readyz", "", "", http.StatusOK)
+	validate(http.MethodGet, "/api/v1/admin/status", adminToken, "", http.StatusOK)
+	postgresDown.Store(true)
+	validate(http.MethodGet, "/readyz", "", "", http.StatusServiceUnavailable)
+	validate(http.MethodGet, "/api/v1/admin/status", adminToken, "", http.StatusServiceUnavailable)
+
+	t.Cleanup(func() { logging.ResetLevel("contract-test") })
+	validate(http.MethodGet, "/api/v1/admin/log-levels", adminToken, "", http.StatusOK)
+	validate(http.MethodPut, "/api/v1/admin/log-levels", adminToken, `{"component":"contract-test","level":"debug"}`, http.StatusOK)
+	validate(http.MethodPut, "/api/v1/admin/log-levels", adminToken, `{"level":"verbose"}`, http.StatusBadRequest)
+	validate(http.MethodPut, "/api/v1/admin/log-levels", adminToken, `{`, http.StatusBadRequest)
+	validate(http.MethodDelete, "/api/v1/admin/log-levels/{component}", adminToken, "", http.StatusOK)
+}
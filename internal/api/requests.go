@@ -0,0 +1,56 @@
+package api
+
+import (
+	"github.com/wilbyang/law-docs/internal/models"
+)
+
+// CreateDocumentRequest is the body of POST /api/v1/docs.
+type CreateDocumentRequest struct {
+	Title   string       `json:"title" binding:"required,max=500" example:"Tenancy agreement"`
+	Content string       `json:"content" binding:"max=1000000"`
+	Meta    *models.Meta `json:"meta"`
+	// Status may only be draft; later states are reached through the workflow.
+	Status string `json:"status" binding:"omitempty,oneof=draft" example:"draft"`
+	// MatterID may be omitted when FolderID is set; the folder's matter is used.
+	MatterID int32 `json:"matter_id" binding:"omitempty,min=1" example:"3"`
+	FolderID int32 `json:"folder_id" binding:"omitempty,min=1" example:"7"`
+}
+
+// UpdateDocumentRequest is the body of PUT /api/v1/docs/{id}. Omitted fields are left unchanged;
+// a status change must be a single allowed workflow transition.
+type UpdateDocumentRequest struct {
+	Title   *string      `json:"title" binding:"omitempty,min=1,max=500"`
+	Content *string      `json:"content" binding:"omitempty,max=1000000"`
+	Meta    *models.Meta `json:"meta"`
+	Status  *string      `json:"status" binding:"omitempty,oneof=draft pre-processed auditing audited" example:"auditing"`
+}
+
+// UpdateDocumentQuery holds the query parameters of PUT /api/v1/docs/{id}.
+type UpdateDocumentQuery struct {
+	Force bool `form:"force"`
+}
+
+// ListDocumentsQuery holds the query parameters of GET /api/v1/docs. All filters must match;
+// a document matches Tags when it carries every listed tag.
+type ListDocumentsQuery struct {
+	AuthorID int32    `form:"author_id" binding:"omitempty,min=1"`
+	MatterID int32    `form:"matter_id" binding:"omitempty,min=1"`
+	FolderID int32    `form:"folder_id" binding:"omitempty,min=1"`
+	Tags     []string `form:"tag" binding:"max=20,dive,min=1,max=100"`
+	Query    string   `form:"q" binding:"max=200"`
+}
+
+// DocumentURI binds the {id} path parameter.
+type DocumentURI struct {
+	ID int32 `uri:"id" binding:"min=1"`
+}
+
+// ResourceURI binds the {id} path parameter of matters, folders and tags.
+type ResourceURI struct {
+	ID int32 `uri:"id" binding:"min=1"`
+}
+
+// UploadForm is the multipart body of POST /api/v1/upload.
+type UploadForm struct {
+	Priority string `form:"priority" binding:"omitempty,oneof=low normal high"`
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	entity "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/models"
+)
+
+// Response DTOs use validate:"required" only to mark fields that are always present in the
+// OpenAPI document; gin validates request bindings through the binding tag.
+
+// DocumentResponse is the public representation of a law document.
+type DocumentResponse struct {
+	ID        int32        `json:"id" validate:"required" example:"42"`
+	Title     string       `json:"title" validate:"required" example:"Tenancy agreement"`
+	Content   string       `json:"content" validate:"required"`
+	DocSize   int32        `json:"doc_size" validate:"required" example:"1024"`
+	CreatedAt time.Time    `json:"created_at" validate:"required" format:"date-time"`
+	UpdatedAt time.Time    `json:"updated_at" validate:"required" format:"date-time"`
+	Meta      *models.Meta `json:"meta,omitempty"`
+	Status    string       `json:"status" validate:"required" enums:"draft,pre-processed,auditing,audited" example:"draft"`
+	AuthorID  *int32       `json:"author_id,omitempty" example:"1"`
+	FilePath  *string      `json:"file_path,omitempty" example:"s3://law-docs/tenants/1/6B2MZ4XQTHPJ3DG7NWF5KRCLVA/contract.pdf"`
+	MatterID  *int32       `json:"matter_id,omitempty" example:"3"`
+	FolderID  *int32       `json:"folder_id,omitempty" example:"7"`
+	Tags      []string     `json:"tags,omitempty" example:"discovery,privileged"`
+}
+
+// DocumentListResponse is returned by GET /api/v1/docs.
+type DocumentListResponse struct {
+	Documents []DocumentResponse `json:"documents" validate:"required"`
+}
+
+// DownloadResponse points to a short-lived download URL for a document's file.
+type DownloadResponse struct {
+	URL       string    `json:"url" validate:"required"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required" format:"date-time"`
+}
+
+func documentResponse(d entity.Document) DocumentResponse {
+	resp := DocumentResponse{
+		ID:        d.ID,
+		Title:     d.Title,
+		Content:   d.Content,
+		DocSize:   d.DocSize,
+		CreatedAt: d.CreatedAt.Time,
+		UpdatedAt: d.UpdatedAt.Time,
+		Status:    d.Status.String,
+	}
+	if d.Meta != (models.Meta{}) {
+		meta := d.Meta
+		resp.Meta = &meta
+	}
+	if d.AuthorID.Valid {
+		resp.AuthorID = &d.AuthorID.Int32
+	}
+	if d.FilePath.Valid {
+		resp.FilePath = &d.FilePath.String
+	}
+	if d.MatterID.Valid {
+		resp.MatterID = &d.MatterID.Int32
+	}
+	if d.FolderID.Valid {
+		resp.FolderID = &d.FolderID.Int32
+	}
+	return resp
+}
+
+// documentListResponse converts documents, adding the tag names from tags, which is keyed by document ID.
+func documentListResponse(documents []entity.Document, tags map[int32][]string) DocumentListResponse {
+	resp := DocumentListResponse{Documents: make([]DocumentResponse, 0, len(documents))}
+	for _, d := range documents {
+		doc := documentResponse(d)
+		doc.Tags = tags[d.ID]
+		resp.Documents = append(resp.Documents, doc)
+	}
+	return resp
+}
+
+// documentTags loads the tag names of documents, keyed by document ID.
+func documentTags(ctx context.Context, q *entity.Queries, documents ...entity.Document) (map[int32][]string, error) {
+	ids := make([]int32, 0, len(documents))
+	for _, d := range documents {
+		ids = append(ids, d.ID)
+	}
+	rows, err := q.ListDocumentTags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[int32][]string, len(documents))
+	for _, row := range rows {
+		tags[row.DocumentID] = append(tags[row.DocumentID], row.Name)
+	}
+	return tags, nil
+}
@@ -3,234 +3,677 @@ package api
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/wilbyang/law-docs/docs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/wilbyang/law-docs/internal/audit"
 	entity "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/health"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
 	"github.com/wilbyang/law-docs/internal/models"
+	"github.com/wilbyang/law-docs/internal/presence"
 	"github.com/wilbyang/law-docs/internal/services"
+	"github.com/wilbyang/law-docs/internal/sse"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/workflow"
+	"github.com/wilbyang/law-docs/internal/ws"
 )
 
-var (
-	counter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "file_upload_total",
-			Help: "Total number of file uploads",
-		},
-		[]string{"doctype", "priority"},
-	)
-)
-
-func init() {
-	prometheus.MustRegister(counter)
-}
-
 type API struct {
 	router   *gin.Engine
-	repo     *entity.Queries
+	store    *tenant.Store
 	notifier *services.Notifier
 	uploader *services.S3Uploader
+	hub      *ws.Hub
+	presence *presence.Tracker
+	events   *sse.Broker
+	health   *health.Checker
+	log      *slog.Logger
 }
 
-func NewAPI(queries *entity.Queries) *API {
-	ctx := context.TODO()
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-
-		switch service {
-		case s3.ServiceID:
-			return aws.Endpoint{
-				URL:           "http://s3.localhost.localstack.cloud:4566",
-				SigningRegion: "us-east-1",
-			}, nil
-
-		case sqs.ServiceID:
-			return aws.Endpoint{
-				URL:               "http://sqs.eu-west-1.localhost.localstack.cloud:4566",
-				SigningRegion:     "us-east-1",
-				HostnameImmutable: true,
-			}, nil
-
-		default:
-			return aws.Endpoint{
-				URL:           "http://localhost:4566",
-				SigningRegion: "us-east-1",
-			}, nil
-		}
-
-	})
-
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion("us-east-1"),
-		config.WithEndpointResolverWithOptions(customResolver),
-		config.WithClientLogMode(aws.LogRequestWithBody|aws.LogResponseWithBody),
-		config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
-			return aws.Credentials{
-				AccessKeyID:     "test",
-				SecretAccessKey: "test",
-			}, nil
-		})),
-	)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-	notifier, err := services.NewNotifier(ctx, cfg)
-	if err != nil {
-		slog.Error("Failed to create notifier", "error", err)
-	}
-	uploader, err := services.NewS3Uploader(ctx, cfg, "test")
-	if err != nil {
-		slog.Error("Failed to create uploader", "error", err)
-	}
+// NewAPI wires the routes. Document routes authenticate with per-tenant API tokens; adminToken protects
+// /api/v1/admin and may be left empty to disable admin auth in development, which lawdocs serve
+// only allows with -insecure-admin.
+func NewAPI(store *tenant.Store, notifier *services.Notifier, uploader *services.S3Uploader, hub *ws.Hub, tracker *presence.Tracker, broker *sse.Broker, checker *health.Checker, adminToken string) *API {
 	api := &API{
-		repo:     queries,
-		router:   gin.Default(),
+		store:    store,
+		router:   gin.New(),
 		notifier: notifier,
 		uploader: uploader,
+		hub:      hub,
+		presence: tracker,
+		events:   broker,
+		health:   checker,
+		log:      logging.Component("api"),
 	}
 
-	api.setupRoutes()
+	// the client IP is recorded in the audit log, so forwarded headers are ignored until
+	// TrustProxies names the proxies that set them
+	api.router.SetTrustedProxies(nil)
+	api.setupRoutes(adminToken)
 	return api
 }
-func (api *API) Start(addr string) error {
-	return api.router.Run(addr)
+
+// TrustProxies makes the client IP of requests from proxies, given as addresses or CIDR ranges,
+// the one they forwarded in X-Forwarded-For or X-Real-IP.
+func (api *API) TrustProxies(proxies []string) error {
+	return api.router.SetTrustedProxies(proxies)
+}
+
+// Handler returns the HTTP handler serving every API route.
+func (api *API) Handler() http.Handler {
+	return api.router
 }
 
-func (api *API) setupRoutes() {
+func (api *API) setupRoutes(adminToken string) {
+	api.router.HandleMethodNotAllowed = true
+	api.router.NoRoute(notFound)
+	api.router.NoMethod(methodNotAllowed)
+	api.router.Use(
+		requestIDMiddleware,
+		tracingMiddleware,
+		metricsMiddleware,
+		accessLogMiddleware(logging.Component("http")),
+		recoveryMiddleware(api.log),
+	)
 	api.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	api.router.GET("/healthz", api.healthz)
+	api.router.GET("/readyz", api.readyz)
+	api.router.GET("/openapi.json", api.openAPISpec)
 	v1 := api.router.Group("/api/v1")
+	admin := v1.Group("/admin", authMiddleware(adminToken))
 	{
-		// Device management
-		v1.GET("/docs", api.listDocuments)
-		v1.POST("/docs", api.addDocument)
-		v1.PUT("/docs/:id", api.updateDocument)
-		v1.DELETE("/docs/:id", api.deleteDocument)
-		v1.POST("/upload", api.uploadFile)
+		admin.GET("/status", api.adminStatus)
+		admin.GET("/log-levels", api.getLogLevels)
+		admin.PUT("/log-levels", api.setLogLevel)
+		admin.DELETE("/log-levels/:component", api.resetLogLevel)
+	}
+	docs := v1.Group("", tenantMiddleware(api.store, api.log), auditMiddleware)
+	{
+		docs.GET("/docs", api.listDocuments)
+		docs.POST("/docs", api.addDocument)
+		docs.GET("/docs/:id", api.getDocument)
+		docs.GET("/docs/:id/download", api.downloadDocument)
+		docs.PUT("/docs/:id", api.updateDocument)
+		docs.DELETE("/docs/:id", api.deleteDocument)
+		docs.PUT("/docs/:id/lock", api.lockDocument)
+		docs.DELETE("/docs/:id/lock", api.unlockDocument)
+		docs.GET("/docs/:id/presence", api.getDocumentPresence)
+		docs.GET("/docs/:id/comments", api.listComments)
+		docs.POST("/docs/:id/comments", api.createComment)
+		docs.POST("/comments/:id/resolve", api.resolveComment)
+		docs.POST("/comments/:id/reopen", api.reopenComment)
+		docs.GET("/mentions", api.listMentions)
+		docs.GET("/events", api.streamEvents)
+		docs.POST("/docs/tags", api.tagDocuments)
+		docs.POST("/docs/move", api.moveDocuments)
+		docs.POST("/upload", api.uploadFile)
+
+		docs.GET("/matters", api.listMatters)
+		docs.POST("/matters", api.createMatter)
+		docs.GET("/matters/:id", api.getMatter)
+		docs.PUT("/matters/:id", api.updateMatter)
+		docs.DELETE("/matters/:id", api.deleteMatter)
+		docs.GET("/matters/:id/folders", api.listFolders)
+		docs.POST("/matters/:id/folders", api.createFolder)
+		docs.PUT("/folders/:id", api.updateFolder)
+		docs.DELETE("/folders/:id", api.deleteFolder)
+
+		docs.GET("/tags", api.listTags)
+		docs.POST("/tags", api.createTag)
+		docs.PUT("/tags/:id", api.updateTag)
+		docs.DELETE("/tags/:id", api.deleteTag)
+
+		docs.GET("/webhooks", api.listWebhooks)
+		docs.POST("/webhooks", api.createWebhook)
+		docs.GET("/webhooks/:id", api.getWebhook)
+		docs.PUT("/webhooks/:id", api.updateWebhook)
+		docs.DELETE("/webhooks/:id", api.deleteWebhook)
+		docs.GET("/webhooks/:id/deliveries", api.listWebhookDeliveries)
+		docs.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", api.redeliverWebhookDelivery)
+
+		docs.GET("/audit/events", api.listAuditEvents)
+		docs.GET("/audit/export", api.exportAuditEvents)
+		docs.GET("/audit/verify", api.verifyAuditLog)
 	}
+	api.router.GET("/ws", tenantMiddleware(api.store, api.log), gin.WrapH(api.hub))
 	api.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 }
 
-// @Summary List all law documents
-// @Description Get a list of all law documents
+// @Summary List law documents
+// @ID listDocuments
+// @Description Get the tenant's law documents, optionally filtered by author, matter, folder, tags and title
 // @Tags documents
+// @Security BearerAuth
+// @Param author_id query int false "Author ID" minimum(1)
+// @Param matter_id query int false "Matter ID" minimum(1)
+// @Param folder_id query int false "Folder ID; only documents directly in this folder" minimum(1)
+// @Param tag query []string false "Tag name; repeat to require several tags" collectionFormat(multi)
+// @Param q query string false "Case-insensitive text to find in the title" maxlength(200)
 // @Produce json
-// @Success 200 {object} map[string]interface{} "List of documents"
+// @Success 200 {object} DocumentListResponse
+// @Failure 400 {object} Problem "Invalid query"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to list documents"
 // @Router /api/v1/docs [get]
 func (api *API) listDocuments(c *gin.Context) {
-	documents, err := api.repo.GetDocuments(c, pgtype.Int4{Int32: 1, Valid: true})
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Failed to list documents",
-		})
+	var query ListDocumentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
 		return
 	}
-	c.JSON(200, gin.H{
-		"status":    "ok",
-		"documents": documents,
+	ctx := c.Request.Context()
+	params := entity.SearchDocumentsParams{
+		AuthorID: pgtype.Int4{Int32: query.AuthorID, Valid: query.AuthorID != 0},
+		MatterID: pgtype.Int4{Int32: query.MatterID, Valid: query.MatterID != 0},
+		FolderID: pgtype.Int4{Int32: query.FolderID, Valid: query.FolderID != 0},
+		Title:    pgtype.Text{String: likeEscaper.Replace(query.Query), Valid: query.Query != ""},
+		Tags:     uniqueStrings(query.Tags),
+	}
+	var documents []entity.Document
+	var tags map[int32][]string
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		documents, err = q.SearchDocuments(ctx, params)
+		if err != nil {
+			return err
+		}
+		tags, err = documentTags(ctx, q, documents...)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionList})
 	})
-
+	if err != nil {
+		api.documentProblem(c, ctx, err, "list documents")
+		return
+	}
+	c.JSON(http.StatusOK, documentListResponse(documents, tags))
 }
 
+// likeEscaper escapes the ILIKE wildcards in a search text.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // @Summary Add a new law document
-// @Description Create a new law document with the provided details
+// @ID createDocument
+// @Description Create a new law document authored by the caller
 // @Tags documents
 // @Accept json
-// @Param document body repository.CreateDocumentParams true "Document details"
+// @Security BearerAuth
+// @Param document body CreateDocumentRequest true "Document details"
 // @Produce json
-// @Success 201 {object} map[string]interface{} "Document created"
-// @Failure 400 {object} map[string]interface{} "Invalid input"
-// @Failure 500 {object} map[string]interface{} "Failed to create document"
+// @Success 201 {object} DocumentResponse
+// @Failure 400 {object} Problem "Invalid input, or unknown matter or folder"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to create document"
 // @Router /api/v1/docs [post]
 func (api *API) addDocument(c *gin.Context) {
-	api.repo.CreateDocument(c, entity.CreateDocumentParams{
-		Title:     "test",
-		Content:   "test",
-		DocSize:   1,
+	var req CreateDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	if req.Status == "" {
+		req.Status = workflow.StatusDraft
+	}
+	ctx := c.Request.Context()
+	caller, _ := tenant.FromContext(ctx)
+	params := entity.CreateDocumentParams{
+		Title:     req.Title,
+		Content:   req.Content,
+		DocSize:   int32(len(req.Content)),
 		CreatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
 		UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
-		Meta:      models.Meta{Key: "test", Value: "test"},
-	})
-	c.JSON(200, gin.H{
-		"status":  "ok",
-		"message": "Document created successfully",
+		Status:    pgtype.Text{String: req.Status, Valid: true},
+		AuthorID:  pgtype.Int4{Int32: caller.UserID, Valid: true},
+	}
+	if req.Meta != nil {
+		params.Meta = *req.Meta
+	}
+	var document entity.Document
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		params.MatterID, params.FolderID, err = placement(ctx, q, req.MatterID, req.FolderID)
+		if err != nil {
+			return err
+		}
+		document, err = q.CreateDocument(ctx, params)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionCreate, DocumentID: document.ID, After: documentResponse(document)})
 	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "create document")
+		return
+	}
+	c.JSON(http.StatusCreated, documentResponse(document))
 }
+
+// @Summary Upload a law document
+// @ID uploadDocument
+// @Description Store a file in the tenant's S3 prefix, create a draft document for it and queue it for processing
+// @Tags documents
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Param file formData file true "Document file"
+// @Param priority formData string false "Processing priority" Enums(low, normal, high)
+// @Produce json
+// @Success 201 {object} DocumentResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 502 {object} Problem "Storage or queue unavailable"
+// @Failure 500 {object} Problem "Failed to create document"
+// @Router /api/v1/upload [post]
 func (api *API) uploadFile(c *gin.Context) {
-	// Source
+	var form UploadForm
+	if err := c.ShouldBind(&form); err != nil {
+		bindProblem(c, err)
+		return
+	}
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Failed to get file",
-		})
+		problem(c, http.StatusBadRequest, CodeValidationFailed, "Request failed validation",
+			FieldError{Field: "file", Message: "is required"})
 		return
 	}
-	filePath, err := api.uploader.UploadFile(fileHeader)
+	ctx := c.Request.Context()
+	caller, _ := tenant.FromContext(ctx)
+	filePath, err := api.uploader.UploadFile(ctx, tenant.StoragePrefix(caller.TenantID), fileHeader)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Failed to upload file",
-		})
+		api.log.ErrorContext(ctx, "Failed to upload file", "error", err)
+		problem(c, http.StatusBadGateway, CodeStorageFailed, "Failed to store file")
 		return
 	}
-	counter.With(prometheus.Labels{"doctype": "pdf", "priority": "high"}).Inc()
+	doctype := docType(fileHeader.Filename)
+	metrics.FileUploads.WithLabelValues(doctype, priority(form.Priority)).Inc()
+	metrics.UploadSize.WithLabelValues(doctype).Observe(float64(fileHeader.Size))
 
-	newdoc, err := api.repo.CreateDocument(c, entity.CreateDocumentParams{
-		Title:     "",
-		Content:   "",
-		DocSize:   int32(fileHeader.Size),
-		CreatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
-		UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
-		Status:    pgtype.Text{String: "draft", Valid: true},
-		AuthorID:  pgtype.Int4{Int32: 1, Valid: true},
-		FilePath:  pgtype.Text{String: filePath, Valid: true},
+	var newdoc entity.Document
+	err = api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		newdoc, err = q.CreateDocument(ctx, entity.CreateDocumentParams{
+			Title:     fileHeader.Filename,
+			Content:   "",
+			DocSize:   int32(fileHeader.Size),
+			CreatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			Status:    pgtype.Text{String: workflow.StatusDraft, Valid: true},
+			AuthorID:  pgtype.Int4{Int32: caller.UserID, Valid: true},
+			FilePath:  pgtype.Text{String: filePath, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionUpload, DocumentID: newdoc.ID, After: documentResponse(newdoc)})
 	})
 	if err != nil {
-		slog.Error("Failed to create document", "error", err, "filePath", filePath)
+		api.log.ErrorContext(ctx, "Failed to create document", "error", err, "filePath", filePath)
+		problem(c, http.StatusInternalServerError, CodeInternal, "Failed to create document")
 		return
 	}
 
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("document.id", int(newdoc.ID)))
+	ctx = logging.WithDocumentID(ctx, newdoc.ID)
+
 	notification := models.Notification{
-		DocID: newdoc.ID,
+		DocID:    newdoc.ID,
+		TenantID: newdoc.TenantID,
 	}
 	sending, _ := json.Marshal(notification)
 
-	err = api.notifier.SendMessage(context.TODO(), string(sending))
+	err = api.notifier.SendMessage(ctx, string(sending))
 	if err != nil {
-		slog.Error("Failed to send message", "error", err)
+		api.log.ErrorContext(ctx, "Failed to send message", "error", err)
+		problem(c, http.StatusBadGateway, CodeNotificationFailed,
+			fmt.Sprintf("Document %d was stored but could not be queued for processing", newdoc.ID))
+		return
+	}
+	c.JSON(http.StatusCreated, documentResponse(newdoc))
+}
+
+// @Summary Get a law document
+// @ID getDocument
+// @Description Get one law document; the access is recorded in the audit log
+// @Tags documents
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Produce json
+// @Success 200 {object} DocumentResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 500 {object} Problem "Failed to get document"
+// @Router /api/v1/docs/{id} [get]
+func (api *API) getDocument(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
 		return
 	}
-	c.JSON(200, gin.H{
-		"status":  "ok",
-		"message": "File uploaded successfully",
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	var document entity.Document
+	var tags map[int32][]string
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		document, err = q.GetDocumentById(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		tags, err = documentTags(ctx, q, document)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionView, DocumentID: document.ID, After: documentResponse(document)})
 	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "get document")
+		return
+	}
+	resp := documentResponse(document)
+	resp.Tags = tags[document.ID]
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Download a law document
+// @ID downloadDocument
+// @Description Get a short-lived URL for the uploaded file; the download is recorded in the audit log
+// @Tags documents
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Produce json
+// @Success 200 {object} DownloadResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found or has no file"
+// @Failure 502 {object} Problem "Storage unavailable"
+// @Failure 500 {object} Problem "Failed to download document"
+// @Router /api/v1/docs/{id}/download [get]
+func (api *API) downloadDocument(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	var resp DownloadResponse
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		document, err := q.GetDocumentById(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		if !document.FilePath.Valid {
+			return pgx.ErrNoRows
+		}
+		resp.ExpiresAt = time.Now().Add(downloadTTL)
+		resp.URL, err = api.uploader.PresignGet(ctx, tenant.StoragePrefix(document.TenantID), document.FilePath.String, downloadTTL)
+		if err != nil {
+			return storageError{err}
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionDownload, DocumentID: document.ID, After: documentResponse(document)})
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "download document")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Summary Manage metadata of a law document
+// @ID updateDocument
 // @Description Update the metadata of a law document with the provided details
 // @Tags documents
 // @Accept json
-// @Param id path int true "Document ID"
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Param document body UpdateDocumentRequest true "Fields to change"
+// @Param force query bool false "Update even while another user holds the edit lock"
 // @Produce json
-// @Success 200 {object} map[string]interface{} "Document updated"
-// @Failure 400 {object} map[string]interface{} "Invalid input"
-// @Failure 500 {object} map[string]interface{} "Failed to update document"
-// @Router /api/v1/docs/:id [put]
+// @Success 200 {object} DocumentResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 409 {object} Problem "Status transition not allowed, or locked by another user"
+// @Failure 500 {object} Problem "Failed to update document"
+// @Router /api/v1/docs/{id} [put]
 func (api *API) updateDocument(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var query UpdateDocumentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req UpdateDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	caller, _ := tenant.FromContext(ctx)
+	var updated entity.Document
+	var tags map[int32][]string
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		document, err := q.GetDocumentById(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		if !query.Force {
+			if err := checkLock(ctx, q, document.ID, caller.UserID); err != nil {
+				return err
+			}
+		}
+		params := entity.UpdateDocumentParams{
+			ID:        document.ID,
+			Title:     document.Title,
+			Content:   document.Content,
+			DocSize:   document.DocSize,
+			UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			Meta:      document.Meta,
+			Status:    document.Status,
+			FilePath:  document.FilePath,
+		}
+		if req.Title != nil {
+			params.Title = *req.Title
+		}
+		if req.Content != nil {
+			params.Content = *req.Content
+			params.DocSize = int32(len(*req.Content))
+		}
+		if req.Meta != nil {
+			params.Meta = *req.Meta
+		}
+		action := audit.ActionUpdate
+		if req.Status != nil && *req.Status != document.Status.String {
+			if _, ok := workflow.EventFor(document.Status.String, *req.Status); !ok {
+				return transitionError{from: document.Status.String, to: *req.Status}
+			}
+			params.Status = pgtype.Text{String: *req.Status, Valid: true}
+			action = audit.ActionTransition
+		}
+		updated, err = q.UpdateDocument(ctx, params)
+		if err != nil {
+			return err
+		}
+		tags, err = documentTags(ctx, q, updated)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, audit.Event{
+			Action:     action,
+			DocumentID: document.ID,
+			Before:     documentResponse(document),
+			After:      documentResponse(updated),
+		})
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "update document")
+		return
+	}
+	resp := documentResponse(updated)
+	resp.Tags = tags[updated.ID]
+	c.JSON(http.StatusOK, resp)
 }
+
+// @Summary Delete a law document
+// @ID deleteDocument
+// @Description Delete a law document and its uploaded file
+// @Tags documents
+// @Security BearerAuth
+// @Param id path int true "Document ID" minimum(1)
+// @Produce json
+// @Success 204 "Document deleted"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Document not found"
+// @Failure 500 {object} Problem "Failed to delete document"
+// @Router /api/v1/docs/{id} [delete]
 func (api *API) deleteDocument(c *gin.Context) {
+	var uri DocumentURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := logging.WithDocumentID(c.Request.Context(), uri.ID)
+	var document entity.Document
+	// files uploaded before keys were unique may be shared with another document
+	var shared int64
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		document, err = q.DeleteDocument(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		if document.FilePath.Valid {
+			if shared, err = q.CountDocumentsWithFile(ctx, document.FilePath); err != nil {
+				return err
+			}
+		}
+		return audit.Record(ctx, q, audit.Event{Action: audit.ActionDelete, DocumentID: document.ID, Before: documentResponse(document)})
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "delete document")
+		return
+	}
+	if document.FilePath.Valid && shared == 0 {
+		// the row is gone either way; an orphaned object is only worth a warning
+		err := api.uploader.DeleteFile(ctx, tenant.StoragePrefix(document.TenantID), document.FilePath.String)
+		if err != nil {
+			api.log.WarnContext(ctx, "Failed to delete uploaded file", "error", err, "filePath", document.FilePath.String)
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// downloadTTL bounds how long a download URL stays valid.
+const downloadTTL = 5 * time.Minute
+
+// storageError marks a failure of S3 rather than the database.
+type storageError struct {
+	err error
+}
+
+func (e storageError) Error() string { return e.err.Error() }
+func (e storageError) Unwrap() error { return e.err }
+
+// fieldError rejects an input field that only the database can check, such as a reference to another row.
+type fieldError FieldError
+
+func (e fieldError) Error() string { return e.Field + " " + e.Message }
+
+// notFoundError reports rows other than the requested document that do not exist.
+type notFoundError struct {
+	kind string
+	ids  []int32
+}
+
+func (e notFoundError) Error() string {
+	ids := make([]string, len(e.ids))
+	for i, id := range e.ids {
+		ids[i] = strconv.Itoa(int(id))
+	}
+	if len(ids) == 1 {
+		return fmt.Sprintf("%s %s does not exist", e.kind, ids[0])
+	}
+	return fmt.Sprintf("%ss %s do not exist", e.kind, strings.Join(ids, ", "))
+}
+
+// transitionError rejects a status change that is not a single workflow step.
+type transitionError struct {
+	from, to string
+}
+
+func (e transitionError) Error() string {
+	return fmt.Sprintf("Cannot move document from %q to %q", e.from, e.to)
+}
+
+// documentProblem answers a failed document query. Rows hidden by row-level security
+// are indistinguishable from missing ones, so other tenants' documents are reported as not found.
+func (api *API) documentProblem(c *gin.Context, ctx context.Context, err error, action string) {
+	var transition transitionError
+	var storage storageError
+	var field fieldError
+	var missing notFoundError
+	var locked lockedError
+	switch {
+	case errors.As(err, &field):
+		problem(c, http.StatusBadRequest, CodeValidationFailed, "Request failed validation", FieldError(field))
+	case errors.As(err, &missing):
+		problem(c, http.StatusNotFound, CodeNotFound, missing.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		id, _ := logging.DocumentID(ctx)
+		problem(c, http.StatusNotFound, CodeNotFound, fmt.Sprintf("Document %d does not exist", id))
+	case errors.As(err, &transition):
+		problem(c, http.StatusConflict, CodeInvalidTransition, transition.Error())
+	case errors.As(err, &locked):
+		problem(c, http.StatusConflict, CodeDocumentLocked, locked.Error())
+	case errors.As(err, &storage):
+		api.log.ErrorContext(ctx, "Failed to "+action, "error", err)
+		problem(c, http.StatusBadGateway, CodeStorageFailed, "Failed to "+action)
+	default:
+		api.log.ErrorContext(ctx, "Failed to "+action, "error", err)
+		problem(c, http.StatusInternalServerError, CodeInternal, "Failed to "+action)
+	}
+}
+
+var knownDocTypes = map[string]bool{"pdf": true, "doc": true, "docx": true, "odt": true, "rtf": true, "txt": true, "html": true, "md": true}
+
+// docType returns the metric label for a file name, bounded to a fixed set of extensions.
+func docType(filename string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if knownDocTypes[ext] {
+		return ext
+	}
+	return "other"
+}
+
+// priority normalises the optional upload priority form field.
+func priority(p string) string {
+	switch p {
+	case "low", "high":
+		return p
+	default:
+		return "normal"
+	}
 }
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewAPI(nil, nil, nil, nil, nil, nil, nil, "")
+	server.router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+	ip := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = "10.1.2.3:41234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	if got := ip(); got != "10.1.2.3" {
+		t.Errorf("without trusted proxies the client IP is %s, want the peer address", got)
+	}
+	if err := server.TrustProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ip(); got != "203.0.113.7" {
+		t.Errorf("behind a trusted proxy the client IP is %s, want the forwarded one", got)
+	}
+	if err := server.TrustProxies([]string{"not-an-address"}); err == nil {
+		t.Error("accepted an invalid proxy")
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wilbyang/law-docs/internal/audit"
+	entity "github.com/wilbyang/law-docs/internal/db"
+)
+
+// TagRequest is the body of POST /api/v1/tags and PUT /api/v1/tags/{id}.
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"privileged"`
+}
+
+// TagResponse is a tag that can be attached to documents of any matter.
+type TagResponse struct {
+	ID   int32  `json:"id" validate:"required" example:"5"`
+	Name string `json:"name" validate:"required" example:"privileged"`
+}
+
+// TagListResponse is returned by GET /api/v1/tags.
+type TagListResponse struct {
+	Tags []TagResponse `json:"tags" validate:"required"`
+}
+
+// TagDocumentsRequest is the body of POST /api/v1/docs/tags. Tags in Add are attached and
+// tags in Remove detached; at least one of them must be given.
+type TagDocumentsRequest struct {
+	DocumentIDs []int32 `json:"document_ids" binding:"required,min=1,max=500,dive,min=1" example:"42,43"`
+	Add         []int32 `json:"add" binding:"max=100,dive,min=1" example:"5"`
+	Remove      []int32 `json:"remove" binding:"max=100,dive,min=1"`
+}
+
+// @Summary List tags
+// @ID listTags
+// @Description Get the tenant's tags by name
+// @Tags tags
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} TagListResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to list tags"
+// @Router /api/v1/tags [get]
+func (api *API) listTags(c *gin.Context) {
+	ctx := c.Request.Context()
+	var tags []entity.Tag
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		tags, err = q.ListTags(ctx)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Tag", 0, "list tags")
+		return
+	}
+	resp := TagListResponse{Tags: make([]TagResponse, 0, len(tags))}
+	for _, t := range tags {
+		resp.Tags = append(resp.Tags, TagResponse{ID: t.ID, Name: t.Name})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Create a tag
+// @ID createTag
+// @Tags tags
+// @Security BearerAuth
+// @Accept json
+// @Param tag body TagRequest true "Tag name"
+// @Produce json
+// @Success 201 {object} TagResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 409 {object} Problem "Name already in use"
+// @Failure 500 {object} Problem "Failed to create tag"
+// @Router /api/v1/tags [post]
+func (api *API) createTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var tag entity.Tag
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		tag, err = q.CreateTag(ctx, req.Name)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Tag", 0, "create tag")
+		return
+	}
+	c.JSON(http.StatusCreated, TagResponse{ID: tag.ID, Name: tag.Name})
+}
+
+// @Summary Rename a tag
+// @ID updateTag
+// @Tags tags
+// @Security BearerAuth
+// @Accept json
+// @Param id path int true "Tag ID" minimum(1)
+// @Param tag body TagRequest true "Tag name"
+// @Produce json
+// @Success 200 {object} TagResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Tag not found"
+// @Failure 409 {object} Problem "Name already in use"
+// @Failure 500 {object} Problem "Failed to update tag"
+// @Router /api/v1/tags/{id} [put]
+func (api *API) updateTag(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var tag entity.Tag
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		tag, err = q.UpdateTag(ctx, entity.UpdateTagParams{ID: uri.ID, Name: req.Name})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Tag", uri.ID, "update tag")
+		return
+	}
+	c.JSON(http.StatusOK, TagResponse{ID: tag.ID, Name: tag.Name})
+}
+
+// @Summary Delete a tag
+// @ID deleteTag
+// @Description Delete a tag and detach it from every document
+// @Tags tags
+// @Security BearerAuth
+// @Param id path int true "Tag ID" minimum(1)
+// @Success 204 "Tag deleted"
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Tag not found"
+// @Failure 500 {object} Problem "Failed to delete tag"
+// @Router /api/v1/tags/{id} [delete]
+func (api *API) deleteTag(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		_, err := q.DeleteTag(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Tag", uri.ID, "delete tag")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Tag documents
+// @ID tagDocuments
+// @Description Attach and detach tags on many documents in one transaction
+// @Tags documents
+// @Security BearerAuth
+// @Accept json
+// @Param tags body TagDocumentsRequest true "Documents and tag changes"
+// @Produce json
+// @Success 200 {object} DocumentListResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Some documents or tags not found"
+// @Failure 500 {object} Problem "Failed to tag documents"
+// @Router /api/v1/docs/tags [post]
+func (api *API) tagDocuments(c *gin.Context) {
+	var req TagDocumentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		problem(c, http.StatusBadRequest, CodeValidationFailed, "Request failed validation",
+			FieldError{Field: "add", Message: "is required when remove is empty"})
+		return
+	}
+	ctx := c.Request.Context()
+	ids := uniqueIDs(req.DocumentIDs)
+	add, remove := uniqueIDs(req.Add), uniqueIDs(req.Remove)
+	var documents []entity.Document
+	var tags map[int32][]string
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		documents, err = loadDocuments(ctx, q, ids)
+		if err != nil {
+			return err
+		}
+		if err := checkTags(ctx, q, append(slices.Clone(add), remove...)); err != nil {
+			return err
+		}
+		before, err := documentTags(ctx, q, documents...)
+		if err != nil {
+			return err
+		}
+		if len(remove) > 0 {
+			_, err := q.UntagDocuments(ctx, entity.UntagDocumentsParams{DocumentIds: ids, TagIds: remove})
+			if err != nil {
+				return err
+			}
+		}
+		if len(add) > 0 {
+			_, err := q.TagDocuments(ctx, entity.TagDocumentsParams{DocumentIds: ids, TagIds: add})
+			if err != nil {
+				return err
+			}
+		}
+		tags, err = documentTags(ctx, q, documents...)
+		if err != nil {
+			return err
+		}
+		for _, d := range documents {
+			err := audit.Record(ctx, q, audit.Event{
+				Action:     audit.ActionTag,
+				DocumentID: d.ID,
+				Before:     before[d.ID],
+				After:      tags[d.ID],
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		api.documentProblem(c, ctx, err, "tag documents")
+		return
+	}
+	c.JSON(http.StatusOK, documentListResponse(documents, tags))
+}
+
+// checkTags returns a notFoundError listing the ids that are not visible tags.
+func checkTags(ctx context.Context, q *entity.Queries, ids []int32) error {
+	ids = uniqueIDs(ids)
+	found, err := q.ListTagsByID(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(found) == len(ids) {
+		return nil
+	}
+	var missing []int32
+	for _, id := range ids {
+		if !slices.ContainsFunc(found, func(t entity.Tag) bool { return t.ID == id }) {
+			missing = append(missing, id)
+		}
+	}
+	return notFoundError{kind: "Tag", ids: missing}
+}
+
+// uniqueStrings returns s without duplicates, keeping the first occurrence of each.
+func uniqueStrings(s []string) []string {
+	out := make([]string, 0, len(s))
+	for _, v := range s {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,449 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	entity "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/webhooks"
+)
+
+// CreateWebhookRequest is the body of POST /api/v1/webhooks. Without Secret the server generates one.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2000" example:"https://cms.example.com/hooks/lawdocs"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=document.created document.updated document.status_changed" example:"document.created,document.status_changed"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=200"`
+}
+
+// UpdateWebhookRequest is the body of PUT /api/v1/webhooks/{id}. Omitted fields are left
+// unchanged. Enabling a webhook that was disabled after failures resets its failure count.
+type UpdateWebhookRequest struct {
+	URL     *string  `json:"url" binding:"omitempty,url,max=2000"`
+	Events  []string `json:"events" binding:"omitempty,min=1,dive,oneof=document.created document.updated document.status_changed"`
+	Secret  *string  `json:"secret" binding:"omitempty,min=16,max=200"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// WebhookDeliveryURI binds the path parameters of a webhook's delivery.
+type WebhookDeliveryURI struct {
+	ID         int32 `uri:"id" binding:"min=1"`
+	DeliveryID int32 `uri:"delivery_id" binding:"min=1"`
+}
+
+// ListWebhookDeliveriesQuery holds the query parameters of GET /api/v1/webhooks/{id}/deliveries.
+type ListWebhookDeliveriesQuery struct {
+	// Before pages back from the last delivery of the previous page.
+	Before int32 `form:"before" binding:"omitempty,min=1"`
+	Limit  int32 `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// WebhookResponse is a webhook subscription. The secret is only returned when it is set.
+type WebhookResponse struct {
+	ID      int32    `json:"id" validate:"required" example:"4"`
+	URL     string   `json:"url" validate:"required" example:"https://cms.example.com/hooks/lawdocs"`
+	Events  []string `json:"events" validate:"required" example:"document.created,document.status_changed"`
+	Secret  string   `json:"secret,omitempty" example:"whsec_KQ7TZ6X2RVMJ4YDW3HNP5GBCFL"`
+	Enabled bool     `json:"enabled" validate:"required"`
+	// ConsecutiveFailures counts the deliveries that failed every attempt since the last success.
+	ConsecutiveFailures int32      `json:"consecutive_failures" validate:"required"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" format:"date-time"`
+	CreatedAt           time.Time  `json:"created_at" validate:"required" format:"date-time"`
+	UpdatedAt           time.Time  `json:"updated_at" validate:"required" format:"date-time"`
+}
+
+// WebhookListResponse is returned by GET /api/v1/webhooks.
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks" validate:"required"`
+}
+
+// WebhookDeliveryResponse is one delivery of an event to a webhook, with the outcome of its last attempt.
+type WebhookDeliveryResponse struct {
+	ID        int32           `json:"id" validate:"required" example:"318"`
+	WebhookID int32           `json:"webhook_id" validate:"required" example:"4"`
+	EventType string          `json:"event_type" validate:"required" example:"document.status_changed"`
+	Payload   json.RawMessage `json:"payload" validate:"required" swaggertype:"object"`
+	Status    string          `json:"status" validate:"required" enums:"pending,succeeded,failed"`
+	Attempts  int32           `json:"attempts" validate:"required" example:"1"`
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty" format:"date-time"`
+	ResponseStatus *int32     `json:"response_status,omitempty" example:"200"`
+	LastError      string     `json:"last_error,omitempty" example:"unexpected status 503 Service Unavailable"`
+	CreatedAt      time.Time  `json:"created_at" validate:"required" format:"date-time"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" format:"date-time"`
+}
+
+// WebhookDeliveryListResponse is returned by GET /api/v1/webhooks/{id}/deliveries.
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries" validate:"required"`
+}
+
+// errWebhookDisabled rejects redelivering to a disabled webhook, which would never be attempted.
+var errWebhookDisabled = errors.New("webhook is disabled")
+
+func webhookResponse(w entity.Webhook) WebhookResponse {
+	resp := WebhookResponse{
+		ID:                  w.ID,
+		URL:                 w.Url,
+		Events:              w.Events,
+		Enabled:             w.Enabled,
+		ConsecutiveFailures: w.ConsecutiveFailures,
+		CreatedAt:           w.CreatedAt.Time,
+		UpdatedAt:           w.UpdatedAt.Time,
+	}
+	if w.DisabledAt.Valid {
+		resp.DisabledAt = &w.DisabledAt.Time
+	}
+	return resp
+}
+
+func webhookDeliveryResponse(d entity.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:        d.ID,
+		WebhookID: d.WebhookID,
+		EventType: d.EventType,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError.String,
+		CreatedAt: d.CreatedAt.Time,
+	}
+	if d.Status == webhooks.StatusPending {
+		resp.NextAttemptAt = &d.NextAttemptAt.Time
+	}
+	if d.ResponseStatus.Valid {
+		resp.ResponseStatus = &d.ResponseStatus.Int32
+	}
+	if d.DeliveredAt.Valid {
+		resp.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return resp
+}
+
+// webhookURL returns a fieldError unless raw is an http or https URL on a public host. The url
+// binding accepts any scheme and host.
+func webhookURL(ctx context.Context, raw string) error {
+	if err := webhooks.CheckURL(ctx, raw); err != nil {
+		return fieldError{Field: "url", Message: err.Error()}
+	}
+	return nil
+}
+
+// @Summary List webhooks
+// @ID listWebhooks
+// @Description Get the tenant's webhook subscriptions, without their secrets
+// @Tags webhooks
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} WebhookListResponse
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to list webhooks"
+// @Router /api/v1/webhooks [get]
+func (api *API) listWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	var hooks []entity.Webhook
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		hooks, err = q.ListWebhooks(ctx)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", 0, "list webhooks")
+		return
+	}
+	resp := WebhookListResponse{Webhooks: make([]WebhookResponse, 0, len(hooks))}
+	for _, w := range hooks {
+		resp.Webhooks = append(resp.Webhooks, webhookResponse(w))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Create a webhook
+// @ID createWebhook
+// @Description Subscribe a URL to document events. Each delivery is a POST of the event as JSON, signed in the X-Lawdocs-Signature header with the secret, which is only returned here and when it is changed.
+// @Tags webhooks
+// @Security BearerAuth
+// @Accept json
+// @Param webhook body CreateWebhookRequest true "Webhook"
+// @Produce json
+// @Success 201 {object} WebhookResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 500 {object} Problem "Failed to create webhook"
+// @Router /api/v1/webhooks [post]
+func (api *API) createWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	if req.Secret == "" {
+		req.Secret = webhooks.NewSecret()
+	}
+	if err := webhookURL(ctx, req.URL); err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", 0, "create webhook")
+		return
+	}
+	var hook entity.Webhook
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		hook, err = q.CreateWebhook(ctx, entity.CreateWebhookParams{Url: req.URL, Events: uniqueStrings(req.Events), Secret: req.Secret})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", 0, "create webhook")
+		return
+	}
+	resp := webhookResponse(hook)
+	resp.Secret = hook.Secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+// @Summary Get a webhook
+// @ID getWebhook
+// @Tags webhooks
+// @Security BearerAuth
+// @Param id path int true "Webhook ID" minimum(1)
+// @Produce json
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Webhook not found"
+// @Failure 500 {object} Problem "Failed to get webhook"
+// @Router /api/v1/webhooks/{id} [get]
+func (api *API) getWebhook(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var hook entity.Webhook
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		var err error
+		hook, err = q.GetWebhook(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", uri.ID, "get webhook")
+		return
+	}
+	c.JSON(http.StatusOK, webhookResponse(hook))
+}
+
+// @Summary Update a webhook
+// @ID updateWebhook
+// @Description Change a webhook's URL, events or secret, or enable or disable it
+// @Tags webhooks
+// @Security BearerAuth
+// @Accept json
+// @Param id path int true "Webhook ID" minimum(1)
+// @Param webhook body UpdateWebhookRequest true "Fields to change"
+// @Produce json
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} Problem "Invalid input"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Webhook not found"
+// @Failure 500 {object} Problem "Failed to update webhook"
+// @Router /api/v1/webhooks/{id} [put]
+func (api *API) updateWebhook(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	if req.URL != nil {
+		if err := webhookURL(ctx, *req.URL); err != nil {
+			api.organizeProblem(c, ctx, err, "Webhook", uri.ID, "update webhook")
+			return
+		}
+	}
+	var hook entity.Webhook
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		current, err := q.GetWebhook(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		params := entity.UpdateWebhookParams{
+			ID:        current.ID,
+			Url:       current.Url,
+			Events:    current.Events,
+			Secret:    current.Secret,
+			Enabled:   current.Enabled,
+			UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+		}
+		if req.URL != nil {
+			params.Url = *req.URL
+		}
+		if req.Events != nil {
+			params.Events = uniqueStrings(req.Events)
+		}
+		if req.Secret != nil {
+			params.Secret = *req.Secret
+		}
+		if req.Enabled != nil {
+			params.Enabled = *req.Enabled
+		}
+		hook, err = q.UpdateWebhook(ctx, params)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", uri.ID, "update webhook")
+		return
+	}
+	resp := webhookResponse(hook)
+	if req.Secret != nil {
+		resp.Secret = hook.Secret
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Delete a webhook
+// @ID deleteWebhook
+// @Description Delete a webhook and its delivery log
+// @Tags webhooks
+// @Security BearerAuth
+// @Param id path int true "Webhook ID" minimum(1)
+// @Success 204
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Webhook not found"
+// @Failure 500 {object} Problem "Failed to delete webhook"
+// @Router /api/v1/webhooks/{id} [delete]
+func (api *API) deleteWebhook(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		_, err := q.DeleteWebhook(ctx, uri.ID)
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", uri.ID, "delete webhook")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List webhook deliveries
+// @ID listWebhookDeliveries
+// @Description Get the delivery log of a webhook, newest first
+// @Tags webhooks
+// @Security BearerAuth
+// @Param id path int true "Webhook ID" minimum(1)
+// @Param before query int false "Only deliveries older than this delivery ID" minimum(1)
+// @Param limit query int false "Maximum number of deliveries, 50 by default" minimum(1) maximum(200)
+// @Produce json
+// @Success 200 {object} WebhookDeliveryListResponse
+// @Failure 400 {object} Problem "Invalid ID or query"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Webhook not found"
+// @Failure 500 {object} Problem "Failed to list webhook deliveries"
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (api *API) listWebhookDeliveries(c *gin.Context) {
+	var uri ResourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	var query ListWebhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+	ctx := c.Request.Context()
+	var deliveries []entity.WebhookDelivery
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		if _, err := q.GetWebhook(ctx, uri.ID); err != nil {
+			return err
+		}
+		var err error
+		deliveries, err = q.ListWebhookDeliveries(ctx, entity.ListWebhookDeliveriesParams{
+			WebhookID: uri.ID,
+			Before:    pgtype.Int4{Int32: query.Before, Valid: query.Before != 0},
+			MaxRows:   query.Limit,
+		})
+		return err
+	})
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", uri.ID, "list webhook deliveries")
+		return
+	}
+	resp := WebhookDeliveryListResponse{Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, webhookDeliveryResponse(d))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Redeliver a webhook delivery
+// @ID redeliverWebhookDelivery
+// @Description Queue a delivery to be sent again right away, with a fresh set of attempts, whatever its status
+// @Tags webhooks
+// @Security BearerAuth
+// @Param id path int true "Webhook ID" minimum(1)
+// @Param delivery_id path int true "Delivery ID" minimum(1)
+// @Produce json
+// @Success 202 {object} WebhookDeliveryResponse
+// @Failure 400 {object} Problem "Invalid ID"
+// @Failure 401 {object} Problem "Missing or invalid token"
+// @Failure 404 {object} Problem "Webhook or delivery not found"
+// @Failure 409 {object} Problem "Webhook is disabled"
+// @Failure 500 {object} Problem "Failed to redeliver"
+// @Router /api/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (api *API) redeliverWebhookDelivery(c *gin.Context) {
+	var uri WebhookDeliveryURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	var delivery entity.WebhookDelivery
+	err := api.store.Tx(ctx, func(q *entity.Queries) error {
+		hook, err := q.GetWebhook(ctx, uri.ID)
+		if err != nil {
+			return err
+		}
+		if !hook.Enabled {
+			return errWebhookDisabled
+		}
+		delivery, err = q.RedeliverWebhookDelivery(ctx, entity.RedeliverWebhookDeliveryParams{
+			ID:            uri.DeliveryID,
+			WebhookID:     hook.ID,
+			NextAttemptAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notFoundError{kind: "Delivery", ids: []int32{uri.DeliveryID}}
+		}
+		return err
+	})
+	if errors.Is(err, errWebhookDisabled) {
+		problem(c, http.StatusConflict, CodeConflict, fmt.Sprintf("Webhook %d is disabled; enable it first", uri.ID))
+		return
+	}
+	if err != nil {
+		api.organizeProblem(c, ctx, err, "Webhook", uri.ID, "redeliver")
+		return
+	}
+	c.JSON(http.StatusAccepted, webhookDeliveryResponse(delivery))
+}
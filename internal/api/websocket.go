@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+// Websocket topics live under "tenants.<tenant>.<namespace>". The docs and users namespaces are
+// written only by the server; users.<user> is visible to that user alone. Clients may publish to
+// each other in the channels namespace.
+const (
+	topicDocs     = "docs"
+	topicUsers    = "users"
+	topicChannels = "channels"
+)
+
+// AuthorizeTopic is the ws.Hub authorization policy. Patterns and topics must name the caller's
+// tenant and a namespace literally, so wildcards never reach across tenants or into another
+// user's topics.
+func AuthorizeTopic(p tenant.Principal, a ws.Action, topic string) bool {
+	segments := strings.Split(topic, ".")
+	if len(segments) < 4 || segments[0] != "tenants" || segments[1] != strconv.Itoa(int(p.TenantID)) {
+		return false
+	}
+	switch namespace := segments[2]; {
+	case a == ws.Publish:
+		return namespace == topicChannels
+	case namespace == topicUsers:
+		return segments[3] == strconv.Itoa(int(p.UserID))
+	default:
+		return namespace == topicDocs || namespace == topicChannels
+	}
+}
+
+// DocumentStatusTopic is the hub topic with the created and status_changed events of a document.
+// Its last event is retained, so a new subscriber learns the current status right away.
+func DocumentStatusTopic(tenantID, documentID int32) string {
+	return fmt.Sprintf("tenants.%d.docs.%d.status", tenantID, documentID)
+}
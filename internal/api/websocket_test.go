@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+func TestAuthorizeTopic(t *testing.T) {
+	p := tenant.Principal{TenantID: 7, UserID: 3}
+	for _, tc := range []struct {
+		action  ws.Action
+		topic   string
+		allowed bool
+	}{
+		{ws.Subscribe, "tenants.7.docs.42.status", true},
+		{ws.Subscribe, "tenants.7.docs.>", true},
+		{ws.Subscribe, "tenants.7.channels.general", true},
+		{ws.Subscribe, "tenants.7.users.3.>", true},
+		{ws.Publish, "tenants.7.channels.general", true},
+		// other tenants, also through wildcards
+		{ws.Subscribe, "tenants.8.docs.42.status", false},
+		{ws.Subscribe, "tenants.*.docs.>", false},
+		{ws.Subscribe, "tenants.>", false},
+		{ws.Subscribe, ">", false},
+		{ws.Publish, "tenants.8.channels.general", false},
+		// other users
+		{ws.Subscribe, "tenants.7.users.4.>", false},
+		{ws.Subscribe, "tenants.7.users.*.notifications", false},
+		{ws.Subscribe, "tenants.7.users.>", false},
+		// namespaces must be literal and known
+		{ws.Subscribe, "tenants.7.*.42", false},
+		{ws.Subscribe, "tenants.7.>", false},
+		{ws.Subscribe, "tenants.7.billing.1", false},
+		{ws.Subscribe, "tenants.7.docs", false},
+		// only channels take client publishes
+		{ws.Publish, "tenants.7.docs.42.status", false},
+		{ws.Publish, "tenants.7.users.3.notifications", false},
+	} {
+		if got := AuthorizeTopic(p, tc.action, tc.topic); got != tc.allowed {
+			t.Errorf("action %d on %q: got %v, want %v", tc.action, tc.topic, got, tc.allowed)
+		}
+	}
+}
+
+func TestHubRefusesForeignTopics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	hub := ws.NewHub(ws.Options{Authorize: AuthorizeTopic})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tenant.WithPrincipal(r.Context(), tenant.Principal{TenantID: 7, UserID: 3})
+		hub.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	var welcome ws.Message
+	if err := wsjson.Read(ctx, conn, &welcome); err != nil || welcome.Type != ws.TypeWelcome {
+		t.Fatalf("got %+v (%v), want a welcome", welcome, err)
+	}
+
+	for _, req := range []ws.Message{
+		{Type: ws.TypeSubscribe, Topic: "tenants.8.docs.>", ID: "1"},
+		{Type: ws.TypeSubscribe, Topic: "tenants.*.docs.42.status", ID: "2"},
+		{Type: ws.TypePublish, Topic: "tenants.8.channels.general", ID: "3", Payload: "hi"},
+	} {
+		if err := wsjson.Write(ctx, conn, req); err != nil {
+			t.Fatal(err)
+		}
+		var reply ws.Message
+		if err := wsjson.Read(ctx, conn, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Type != ws.TypeError || reply.ID != req.ID {
+			t.Errorf("%s %s: got %+v, want an error", req.Type, req.Topic, reply)
+		}
+	}
+
+	// nothing of the other tenant reaches the client
+	if err := wsjson.Write(ctx, conn, ws.Message{Type: ws.TypeSubscribe, Topic: "tenants.7.channels.marker"}); err != nil {
+		t.Fatal(err)
+	}
+	var confirmation ws.Message
+	if err := wsjson.Read(ctx, conn, &confirmation); err != nil || confirmation.Type != ws.TypeConfirmation {
+		t.Fatalf("got %+v (%v), want a confirmation", confirmation, err)
+	}
+	hub.Publish("tenants.8.docs.42.status", "foreign")
+	hub.Publish("tenants.7.channels.marker", "done")
+	var msg ws.Message
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != ws.TypePublish || msg.Topic != "tenants.7.channels.marker" {
+		t.Errorf("got %+v, want only the marker", msg)
+	}
+}
@@ -0,0 +1,177 @@
+// Package audit records who accessed or changed which document.
+//
+// Events are appended to the audit_events table inside the same transaction as the access or
+// change they describe. Each event's hash covers its fields and the hash of the tenant's previous
+// event, so Verify detects any event that was altered, removed or inserted after the fact.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// Actions recorded in audit_events.action.
+const (
+	ActionList       = "list"
+	ActionView       = "view"
+	ActionDownload   = "download"
+	ActionCreate     = "create"
+	ActionUpload     = "upload"
+	ActionUpdate     = "update"
+	ActionTransition = "transition"
+	ActionTag        = "tag"
+	ActionMove       = "move"
+	ActionComment    = "comment"
+	ActionDelete     = "delete"
+	ActionShare      = "share"
+	ActionExport     = "export"
+)
+
+// Event describes one access or change. Before and After are the document states around a change;
+// only their hashes are stored.
+type Event struct {
+	Action     string
+	DocumentID int32
+	Before     any
+	After      any
+}
+
+type requestKey struct{}
+
+// Request carries the client details recorded with every event of an HTTP request.
+type Request struct {
+	IP        string
+	UserAgent string
+}
+
+// WithRequest returns a context whose events record r.
+func WithRequest(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// Record appends e to the audit log of the tenant in ctx using q, which must belong to a
+// tenant-scoped transaction (tenant.Store.Tx) so the event commits or rolls back with the change.
+func Record(ctx context.Context, q *repository.Queries, e Event) error {
+	caller, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrNoTenant
+	}
+	// serialise writers per tenant so two events never share a predecessor; readers of the log
+	// never take the lock
+	if err := q.LockAuditChain(ctx, caller.TenantID); err != nil {
+		return err
+	}
+	var prevHash []byte
+	last, err := q.GetLastAuditEvent(ctx, caller.TenantID)
+	switch {
+	case err == nil:
+		prevHash = last.Hash
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	req, _ := ctx.Value(requestKey{}).(Request)
+	params := repository.CreateAuditEventParams{
+		TenantID: caller.TenantID,
+		// Postgres keeps microseconds; truncate so the hash matches what is read back
+		OccurredAt: pgtype.Timestamptz{Time: time.Now().UTC().Truncate(time.Microsecond), Valid: true},
+		ActorID:    pgtype.Int4{Int32: caller.UserID, Valid: caller.UserID != 0},
+		Action:     e.Action,
+		DocumentID: pgtype.Int4{Int32: e.DocumentID, Valid: e.DocumentID != 0},
+		Ip:         pgtype.Text{String: req.IP, Valid: req.IP != ""},
+		UserAgent:  pgtype.Text{String: req.UserAgent, Valid: req.UserAgent != ""},
+		BeforeHash: StateHash(e.Before),
+		AfterHash:  StateHash(e.After),
+		PrevHash:   prevHash,
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		params.RequestID = pgtype.Text{String: id, Valid: true}
+	}
+	params.Hash = Hash(repository.AuditEvent{
+		TenantID:   params.TenantID,
+		OccurredAt: params.OccurredAt,
+		ActorID:    params.ActorID,
+		Action:     params.Action,
+		DocumentID: params.DocumentID,
+		Ip:         params.Ip,
+		UserAgent:  params.UserAgent,
+		RequestID:  params.RequestID,
+		BeforeHash: params.BeforeHash,
+		AfterHash:  params.AfterHash,
+		PrevHash:   params.PrevHash,
+	})
+	_, err = q.CreateAuditEvent(ctx, params)
+	return err
+}
+
+// StateHash returns the SHA-256 of v's JSON encoding, or nil for a nil state.
+func StateHash(v any) []byte {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+// chained lists the fields covered by an event's hash, in a fixed order.
+type chained struct {
+	TenantID   int32   `json:"tenant_id"`
+	OccurredAt string  `json:"occurred_at"`
+	ActorID    *int32  `json:"actor_id"`
+	Action     string  `json:"action"`
+	DocumentID *int32  `json:"document_id"`
+	IP         *string `json:"ip"`
+	UserAgent  *string `json:"user_agent"`
+	RequestID  *string `json:"request_id"`
+	BeforeHash string  `json:"before_hash"`
+	AfterHash  string  `json:"after_hash"`
+	PrevHash   string  `json:"prev_hash"`
+}
+
+// Hash computes the chain hash of an event from its fields and PrevHash; the stored Hash is ignored.
+func Hash(e repository.AuditEvent) []byte {
+	b, _ := json.Marshal(chained{
+		TenantID:   e.TenantID,
+		OccurredAt: e.OccurredAt.Time.UTC().Format(time.RFC3339Nano),
+		ActorID:    int4(e.ActorID),
+		Action:     e.Action,
+		DocumentID: int4(e.DocumentID),
+		IP:         text(e.Ip),
+		UserAgent:  text(e.UserAgent),
+		RequestID:  text(e.RequestID),
+		BeforeHash: hex.EncodeToString(e.BeforeHash),
+		AfterHash:  hex.EncodeToString(e.AfterHash),
+		PrevHash:   hex.EncodeToString(e.PrevHash),
+	})
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+func int4(v pgtype.Int4) *int32 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int32
+}
+
+func text(v pgtype.Text) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// ChainError reports the first event at which the hash chain no longer holds.
+type ChainError struct {
+	EventID int64
+	Reason  string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at event %d: %s", e.EventID, e.Reason)
+}
+
+// Verifier checks a tenant's events in order, one page at a time.
+type Verifier struct {
+	prev    []byte
+	started bool
+	// Count is the number of events verified so far.
+	Count int64
+}
+
+// Add verifies the next events of the chain, returning a *ChainError at the first mismatch.
+func (v *Verifier) Add(events []repository.AuditEvent) error {
+	for _, e := range events {
+		if v.started && !bytes.Equal(e.PrevHash, v.prev) {
+			return &ChainError{EventID: e.ID, Reason: "previous hash does not match the preceding event"}
+		}
+		if !v.started && len(e.PrevHash) != 0 {
+			return &ChainError{EventID: e.ID, Reason: "first event references a missing predecessor"}
+		}
+		if !bytes.Equal(Hash(e), e.Hash) {
+			return &ChainError{EventID: e.ID, Reason: "event contents do not match its hash"}
+		}
+		v.prev, v.started = e.Hash, true
+		v.Count++
+	}
+	return nil
+}
+
+// Head returns the hash of the last verified event.
+func (v *Verifier) Head() []byte {
+	return v.prev
+}
+
+// VerifyAll walks the whole audit log of the tenant in ctx. It only reads, so q may belong to a
+// read-only transaction (tenant.Store.ReadTx).
+func VerifyAll(ctx context.Context, q *repository.Queries) (*Verifier, error) {
+	v := &Verifier{}
+	caller, ok := tenant.FromContext(ctx)
+	if !ok {
+		return v, tenant.ErrNoTenant
+	}
+	var after int64
+	for {
+		page, err := q.ListAuditEvents(ctx, repository.ListAuditEventsParams{TenantID: caller.TenantID, AfterID: after, MaxRows: 1000})
+		if err != nil {
+			return v, err
+		}
+		if err := v.Add(page); err != nil {
+			return v, err
+		}
+		if len(page) < 1000 {
+			return v, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
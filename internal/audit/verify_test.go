@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+)
+
+// chain returns n linked events of tenant 1, hashed the way Record stores them.
+func chain(n int) []repository.AuditEvent {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := make([]repository.AuditEvent, n)
+	var prev []byte
+	for i := range events {
+		e := repository.AuditEvent{
+			ID:         int64(i + 1),
+			TenantID:   1,
+			OccurredAt: pgtype.Timestamptz{Time: start.Add(time.Duration(i) * time.Minute), Valid: true},
+			ActorID:    pgtype.Int4{Int32: 7, Valid: true},
+			Action:     ActionView,
+			DocumentID: pgtype.Int4{Int32: 42, Valid: true},
+			Ip:         pgtype.Text{String: "203.0.113.7", Valid: true},
+			AfterHash:  StateHash(map[string]int{"version": i}),
+			PrevHash:   prev,
+		}
+		e.Hash = Hash(e)
+		prev = e.Hash
+		events[i] = e
+	}
+	return events
+}
+
+func TestVerify(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tamper func([]repository.AuditEvent) []repository.AuditEvent
+		// broken is the ID of the first event that fails, 0 for an intact chain
+		broken int64
+	}{
+		{"intact", func(e []repository.AuditEvent) []repository.AuditEvent { return e }, 0},
+		{"modified", func(e []repository.AuditEvent) []repository.AuditEvent {
+			e[2].Ip = pgtype.Text{String: "198.51.100.1", Valid: true}
+			return e
+		}, 3},
+		{"modified state", func(e []repository.AuditEvent) []repository.AuditEvent {
+			e[1].AfterHash = StateHash(map[string]int{"version": 99})
+			return e
+		}, 2},
+		{"rehashed", func(e []repository.AuditEvent) []repository.AuditEvent {
+			// a rewritten event with a fresh hash breaks the link of its successor
+			e[1].Action = ActionDelete
+			e[1].Hash = Hash(e[1])
+			return e
+		}, 3},
+		{"reordered", func(e []repository.AuditEvent) []repository.AuditEvent {
+			e[1], e[2] = e[2], e[1]
+			return e
+		}, 3},
+		{"removed", func(e []repository.AuditEvent) []repository.AuditEvent {
+			return append(e[:1], e[2:]...)
+		}, 3},
+		{"first removed", func(e []repository.AuditEvent) []repository.AuditEvent {
+			return e[1:]
+		}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			events := tc.tamper(chain(5))
+			v := &Verifier{}
+			err := v.Add(events)
+			if tc.broken == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v.Count != 5 {
+					t.Errorf("verified %d events, want 5", v.Count)
+				}
+				return
+			}
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("got %v, want a chain error", err)
+			}
+			if chainErr.EventID != tc.broken {
+				t.Errorf("chain broken at event %d, want %d: %s", chainErr.EventID, tc.broken, chainErr.Reason)
+			}
+		})
+	}
+}
+
+func TestVerifyPages(t *testing.T) {
+	events := chain(5)
+	v := &Verifier{}
+	if err := v.Add(events[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Add(events[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if v.Count != 5 || string(v.Head()) != string(events[4].Hash) {
+		t.Errorf("verified %d events up to %x, want 5 up to the last", v.Count, v.Head())
+	}
+
+	// a page that skips an event does not link to the previous page
+	v = &Verifier{}
+	v.Add(events[:2])
+	if err := v.Add(events[3:]); err == nil {
+		t.Error("accepted a page that does not continue the chain")
+	}
+}
+
+func TestHashCoversEveryField(t *testing.T) {
+	base := chain(2)[1]
+	for name, change := range map[string]func(*repository.AuditEvent){
+		"tenant":      func(e *repository.AuditEvent) { e.TenantID = 2 },
+		"time":        func(e *repository.AuditEvent) { e.OccurredAt.Time = e.OccurredAt.Time.Add(time.Microsecond) },
+		"actor":       func(e *repository.AuditEvent) { e.ActorID = pgtype.Int4{} },
+		"action":      func(e *repository.AuditEvent) { e.Action = ActionDownload },
+		"document":    func(e *repository.AuditEvent) { e.DocumentID.Int32 = 43 },
+		"ip":          func(e *repository.AuditEvent) { e.Ip = pgtype.Text{} },
+		"user agent":  func(e *repository.AuditEvent) { e.UserAgent = pgtype.Text{String: "curl", Valid: true} },
+		"request":     func(e *repository.AuditEvent) { e.RequestID = pgtype.Text{String: "r1", Valid: true} },
+		"before":      func(e *repository.AuditEvent) { e.BeforeHash = StateHash("before") },
+		"after":       func(e *repository.AuditEvent) { e.AfterHash = nil },
+		"predecessor": func(e *repository.AuditEvent) { e.PrevHash = nil },
+	} {
+		e := base
+		change(&e)
+		if string(Hash(e)) == string(base.Hash) {
+			t.Errorf("changing the %s keeps the hash", name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Package backplane forwards messages between the nodes of a cluster so that an in-process hub
+// can fan out what other replicas publish. Every node has an ID and never receives its own
+// messages back, so a hub delivers locally and forwards without seeing the message twice.
+package backplane
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Backplane connects one node to the cluster.
+type Backplane interface {
+	// Publish sends payload, which must be JSON, under topic to every other node.
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Run calls handle with the messages published by other nodes until ctx is cancelled.
+	// Calls to handle are sequential.
+	Run(ctx context.Context, handle func(topic string, payload []byte)) error
+}
+
+// envelope is a message on the wire.
+type envelope struct {
+	Node    string          `json:"node"`
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NewNodeID returns a random node ID.
+func NewNodeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,64 @@
+package backplane
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory connects in-process nodes, for tests and single-binary setups.
+type Memory struct {
+	mu    sync.Mutex
+	nodes map[*memoryNode]struct{}
+}
+
+type memoryNode struct {
+	cluster *Memory
+	id      string
+	inbox   chan envelope
+}
+
+// NewMemory creates an empty in-memory cluster.
+func NewMemory() *Memory {
+	return &Memory{nodes: map[*memoryNode]struct{}{}}
+}
+
+// Node joins a node with the given ID to the cluster. Messages published by other nodes are
+// buffered until the node's Run picks them up; Publish blocks while a node's buffer is full.
+func (m *Memory) Node(id string) Backplane {
+	n := &memoryNode{cluster: m, id: id, inbox: make(chan envelope, 256)}
+	m.mu.Lock()
+	m.nodes[n] = struct{}{}
+	m.mu.Unlock()
+	return n
+}
+
+func (n *memoryNode) Publish(ctx context.Context, topic string, payload []byte) error {
+	e := envelope{Node: n.id, Topic: topic, Payload: append([]byte(nil), payload...)}
+	n.cluster.mu.Lock()
+	peers := make([]*memoryNode, 0, len(n.cluster.nodes))
+	for peer := range n.cluster.nodes {
+		if peer.id != n.id {
+			peers = append(peers, peer)
+		}
+	}
+	n.cluster.mu.Unlock()
+	for _, peer := range peers {
+		select {
+		case peer.inbox <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (n *memoryNode) Run(ctx context.Context, handle func(topic string, payload []byte)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-n.inbox:
+			handle(e.Topic, e.Payload)
+		}
+	}
+}
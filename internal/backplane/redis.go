@@ -0,0 +1,64 @@
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// Redis is a Backplane over Redis pub/sub. Each topic is published on the channel prefix+topic
+// and nodes pattern-subscribe to prefix+"*". Pub/sub does not store messages, so a node misses
+// what is published while it is disconnected from Redis.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	node   string
+	log    *slog.Logger
+}
+
+// NewRedis creates the Redis backplane of node. prefix separates independent hubs sharing one
+// Redis, e.g. "lawdocs:ws:", and must not contain glob characters.
+func NewRedis(client *redis.Client, prefix, node string) *Redis {
+	return &Redis{client: client, prefix: prefix, node: node, log: logging.Component("backplane")}
+}
+
+// Publish implements Backplane.
+func (r *Redis) Publish(ctx context.Context, topic string, payload []byte) error {
+	data, err := json.Marshal(envelope{Node: r.node, Topic: topic, Payload: payload})
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, r.prefix+topic, data).Err()
+}
+
+// Run implements Backplane. The subscription connects lazily and reconnects on its own, so Run
+// keeps going while Redis is unavailable.
+func (r *Redis) Run(ctx context.Context, handle func(topic string, payload []byte)) error {
+	sub := r.client.PSubscribe(ctx, r.prefix+"*")
+	defer sub.Close()
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var e envelope
+			if err := json.Unmarshal([]byte(m.Payload), &e); err != nil {
+				r.log.WarnContext(ctx, "Ignoring undecodable message", "channel", m.Channel, "error", err)
+				continue
+			}
+			if e.Node == r.node || e.Topic != strings.TrimPrefix(m.Channel, r.prefix) {
+				continue
+			}
+			handle(e.Topic, e.Payload)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package cdc reads row changes from Postgres logical replication. A Reader streams the pgoutput
+// messages of a publication through a replication slot and hands every insert, update and delete
+// to a handler, in commit order. The slot keeps the position the reader confirmed across restarts
+// and reconnects; a transaction is only confirmed once the handler accepted all of its changes, so
+// changes are delivered at least once, and a handler that remembers the last Position it handled
+// sees each of them once.
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"regexp"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// Operations of a Change.
+const (
+	Insert = "insert"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Row maps column names to their values in text form; nil is NULL. An update leaves out
+// TOASTed columns it did not change, since the server does not send them.
+type Row map[string]*string
+
+// Position orders changes: those of one transaction share its commit LSN and are numbered from 0.
+type Position struct {
+	LSN   LSN
+	Index int
+}
+
+// Change is one row changed by a committed transaction.
+type Change struct {
+	Operation string
+	Relation  *Relation
+	// Old is the row before an update or delete, as far as the table's replica identity covers it.
+	Old Row
+	// New is the row after an insert or update.
+	New         Row
+	Position    Position
+	XID         uint32
+	CommittedAt time.Time
+}
+
+// Handler handles one change. If it fails the reader reconnects and the change is delivered
+// again, along with the rest of its transaction and those after it.
+type Handler func(ctx context.Context, c Change) error
+
+// Options configures a Reader. Zero values select the defaults.
+type Options struct {
+	// Slot is the replication slot, created on first use. It must be unique to this reader:
+	// the server keeps WAL for it until the reader confirms it, so drop the slot of a reader that
+	// is retired with pg_drop_replication_slot.
+	Slot string
+	// Publication names the published tables, see CREATE PUBLICATION.
+	Publication string
+	// StatusInterval is how often the confirmed position is reported to the server, 10s by default.
+	StatusInterval time.Duration
+	// MinBackoff is the first delay before reconnecting, 500ms by default. It doubles with every
+	// failed attempt up to MaxBackoff, 30s by default.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Reader streams changes from a replication slot.
+type Reader struct {
+	dsn  string
+	opts Options
+	log  *slog.Logger
+}
+
+// names of slots and publications, which are used unquoted in replication commands
+var identifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// New creates a Reader connecting to the database at dsn. The role needs the REPLICATION
+// attribute, and the server wal_level = logical.
+func New(dsn string, opts Options) (*Reader, error) {
+	if !identifier.MatchString(opts.Slot) {
+		return nil, fmt.Errorf("cdc: invalid slot name %q", opts.Slot)
+	}
+	if !identifier.MatchString(opts.Publication) {
+		return nil, fmt.Errorf("cdc: invalid publication name %q", opts.Publication)
+	}
+	if opts.StatusInterval <= 0 {
+		opts.StatusInterval = 10 * time.Second
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(30*time.Second, opts.MinBackoff)
+	}
+	return &Reader{dsn: dsn, opts: opts, log: logging.Component("cdc").With("slot", opts.Slot)}, nil
+}
+
+// Run passes changes to h until ctx is cancelled. Failures are logged and retried with backoff.
+func (r *Reader) Run(ctx context.Context, h Handler) error {
+	backoff := r.opts.MinBackoff
+	for {
+		streamed, err := r.stream(ctx, h)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if streamed {
+			backoff = r.opts.MinBackoff
+		}
+		delay := backoff/2 + rand.N(backoff)
+		r.log.ErrorContext(ctx, "Replication failed, reconnecting", "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, r.opts.MaxBackoff)
+	}
+}
+
+// stream serves one replication connection until it fails. It reports whether the connection got
+// as far as streaming.
+func (r *Reader) stream(ctx context.Context, h Handler) (bool, error) {
+	cfg, err := pgconn.ParseConfig(r.dsn)
+	if err != nil {
+		return false, err
+	}
+	cfg.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput NOEXPORT_SNAPSHOT", r.opts.Slot)).ReadAll()
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil:
+		r.log.InfoContext(ctx, "Created replication slot")
+	case errors.As(err, &pgErr) && pgErr.Code == "42710":
+		// duplicate_object: the slot exists and remembers where the last reader stopped
+	default:
+		return false, fmt.Errorf("unable to create replication slot: %w", err)
+	}
+
+	// 0/0 starts at the slot's confirmed position
+	conn.Frontend().SendQuery(&pgproto3.Query{String: fmt.Sprintf(
+		"START_REPLICATION SLOT %s LOGICAL 0/0 (proto_version '1', publication_names '%s')", r.opts.Slot, r.opts.Publication)})
+	if err := conn.Frontend().Flush(); err != nil {
+		return false, err
+	}
+	for started := false; !started; {
+		msg, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return false, err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			started = true
+		case *pgproto3.ErrorResponse:
+			return false, fmt.Errorf("unable to start replication: %w", pgconn.ErrorResponseToPgError(msg))
+		}
+	}
+	r.log.InfoContext(ctx, "Streaming changes", "publication", r.opts.Publication)
+
+	s := &session{reader: r, conn: conn, handler: h, relations: map[uint32]*Relation{}}
+	return true, s.run(ctx)
+}
+
+// session is the state of one replication connection.
+type session struct {
+	reader    *Reader
+	conn      *pgconn.PgConn
+	handler   Handler
+	relations map[uint32]*Relation
+
+	// tx is the transaction being received, nil between transactions
+	tx *beginMessage
+	// index numbers the changes of tx
+	index int
+	// confirmed is the position up to which every change was handled
+	confirmed LSN
+}
+
+func (s *session) run(ctx context.Context) error {
+	next := time.Now().Add(s.reader.opts.StatusInterval)
+	for {
+		if !time.Now().Before(next) {
+			if err := s.sendStatus(); err != nil {
+				return err
+			}
+			next = time.Now().Add(s.reader.opts.StatusInterval)
+		}
+		recvCtx, cancel := context.WithDeadline(ctx, next)
+		msg, err := s.conn.ReceiveMessage(recvCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyData:
+			reply, err := s.copyData(ctx, msg.Data)
+			if err != nil {
+				return err
+			}
+			if reply {
+				if err := s.sendStatus(); err != nil {
+					return err
+				}
+				next = time.Now().Add(s.reader.opts.StatusInterval)
+			}
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyDone:
+			return errors.New("server ended replication")
+		}
+	}
+}
+
+// copyData handles a message of the streaming replication protocol and reports whether the
+// server asked for a status update.
+func (s *session) copyData(ctx context.Context, data []byte) (bool, error) {
+	d := &decoder{buf: data}
+	switch d.uint8() {
+	case 'w':
+		d.uint64() // start of the data in the log
+		d.uint64() // current end of the log
+		d.uint64() // server time
+		if d.err != nil {
+			return false, fmt.Errorf("decoding XLogData: %w", d.err)
+		}
+		return false, s.handle(ctx, d.buf)
+	case 'k':
+		walEnd := LSN(d.uint64())
+		d.uint64() // server time
+		reply := d.uint8() == 1
+		if d.err != nil {
+			return false, fmt.Errorf("decoding keepalive: %w", d.err)
+		}
+		// between transactions every change the server has sent was handled, so the slot may move
+		// past WAL that held nothing for the publication
+		if s.tx == nil && walEnd > s.confirmed {
+			s.confirmed = walEnd
+		}
+		return reply, nil
+	}
+	return false, nil
+}
+
+func (s *session) handle(ctx context.Context, data []byte) error {
+	msg, err := parse(data)
+	if err != nil {
+		return err
+	}
+	var operation string
+	var relationID uint32
+	var old, new []datum
+	switch msg := msg.(type) {
+	case beginMessage:
+		s.tx, s.index = &msg, 0
+		return nil
+	case commitMessage:
+		s.tx = nil
+		s.confirmed = max(s.confirmed, msg.EndLSN)
+		return nil
+	case Relation:
+		s.relations[msg.ID] = &msg
+		return nil
+	case truncateMessage:
+		s.reader.log.WarnContext(ctx, "Ignoring truncate", "relations", len(msg.RelationIDs))
+		return nil
+	case insertMessage:
+		operation, relationID, new = Insert, msg.RelationID, msg.New
+	case updateMessage:
+		operation, relationID, old, new = Update, msg.RelationID, msg.Old, msg.New
+	case deleteMessage:
+		operation, relationID, old = Delete, msg.RelationID, msg.Old
+	default:
+		return nil
+	}
+
+	if s.tx == nil {
+		return fmt.Errorf("%s outside a transaction", operation)
+	}
+	rel := s.relations[relationID]
+	if rel == nil {
+		return fmt.Errorf("%s on unknown relation %d", operation, relationID)
+	}
+	c := Change{
+		Operation:   operation,
+		Relation:    rel,
+		Old:         rel.row(old),
+		New:         rel.row(new),
+		Position:    Position{LSN: s.tx.FinalLSN, Index: s.index},
+		XID:         s.tx.XID,
+		CommittedAt: s.tx.CommitTime,
+	}
+	s.index++
+	return s.handler(ctx, c)
+}
+
+func (r *Relation) row(tuple []datum) Row {
+	if tuple == nil {
+		return nil
+	}
+	row := make(Row, len(tuple))
+	for i, d := range tuple {
+		if i >= len(r.Columns) {
+			break
+		}
+		switch d.kind {
+		case 't':
+			row[r.Columns[i]] = &d.text
+		case 'n':
+			row[r.Columns[i]] = nil
+		}
+	}
+	return row
+}
+
+// sendStatus reports the confirmed position as written, flushed and applied.
+func (s *session) sendStatus() error {
+	buf := make([]byte, 0, 34)
+	buf = append(buf, 'r')
+	for range 3 {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(s.confirmed))
+	}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(pgMicros(time.Now())))
+	buf = append(buf, 0) // no reply wanted
+	s.conn.Frontend().Send(&pgproto3.CopyData{Data: buf})
+	if err := s.conn.Frontend().Flush(); err != nil {
+		return fmt.Errorf("unable to send status: %w", err)
+	}
+	return nil
+}
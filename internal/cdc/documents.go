@@ -0,0 +1,143 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/events"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/streams"
+)
+
+// Event is a document change as published by lawdocs cdc. Unlike the events of the change feed
+// it carries the tenant, and the position of the change in the log.
+type Event struct {
+	events.DocumentEvent
+	TenantID int32 `json:"tenant_id"`
+	// LSN is the commit LSN of the change's transaction, which lists its changes by Index.
+	LSN         string    `json:"lsn"`
+	Index       int       `json:"index"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// Publisher returns a Handler publishing the changes of the documents table to p as Events.
+// Every change is published under the stream entry ID <commit LSN>-<index>, so that Redis refuses
+// the changes a restarted reader delivers again: the stream itself records which LSNs were
+// published. The stream must have no other producers. Changes of other tables are skipped.
+func Publisher(p *streams.Producer) Handler {
+	return func(ctx context.Context, c Change) error {
+		if c.Relation.Name != "documents" {
+			metrics.ReplicationChanges.WithLabelValues(c.Operation, "skipped").Inc()
+			return nil
+		}
+		e, ok, err := DocumentEvent(c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			metrics.ReplicationChanges.WithLabelValues(c.Operation, "skipped").Inc()
+			return nil
+		}
+		id := fmt.Sprintf("%d-%d", uint64(c.Position.LSN), c.Position.Index)
+		duplicate, err := p.PublishID(ctx, id, e.Type, e)
+		if err != nil {
+			return err
+		}
+		outcome := "published"
+		if duplicate {
+			outcome = "duplicate"
+		}
+		metrics.ReplicationChanges.WithLabelValues(c.Operation, outcome).Inc()
+		metrics.ReplicationLSN.Set(float64(c.Position.LSN))
+		return nil
+	}
+}
+
+// DocumentEvent converts a change of the documents table into an event, with the same types and
+// columns as the notify_document_change trigger and document.deleted for deletes. It reports
+// false for updates that changed nothing.
+func DocumentEvent(c Change) (Event, bool, error) {
+	if c.Relation.Name != "documents" {
+		return Event{}, false, fmt.Errorf("change on unexpected table %s.%s", c.Relation.Namespace, c.Relation.Name)
+	}
+	row := c.New
+	if c.Operation == Delete {
+		row = c.Old
+	}
+	var e events.DocumentEvent
+	id, err := intColumn(row, "id")
+	if err != nil {
+		return Event{}, false, err
+	}
+	tenantID, err := intColumn(row, "tenant_id")
+	if err != nil {
+		return Event{}, false, err
+	}
+	e.DocumentID, e.TenantID = id, tenantID
+	e.Title = text(row, "title")
+	e.Status = text(row, "status")
+	if updated := text(row, "updated_at"); updated != "" {
+		if e.UpdatedAt, err = time.Parse("2006-01-02 15:04:05.999999", updated); err != nil {
+			return Event{}, false, fmt.Errorf("column updated_at: %w", err)
+		}
+	}
+
+	switch c.Operation {
+	case Insert:
+		e.Type = events.DocumentCreated
+	case Delete:
+		e.Type = events.DocumentDeleted
+	case Update:
+		e.Changed = changed(c.Old, c.New)
+		if c.Old != nil && len(e.Changed) == 0 {
+			return Event{}, false, nil
+		}
+		e.Type = events.DocumentUpdated
+		if previous, ok := c.Old["status"]; ok && previous != nil && *previous != e.Status {
+			e.Type = events.DocumentStatusChanged
+			e.PreviousStatus = *previous
+		}
+	}
+	return Event{
+		DocumentEvent: e,
+		TenantID:      tenantID,
+		LSN:           c.Position.LSN.String(),
+		Index:         c.Position.Index,
+		CommittedAt:   c.CommittedAt,
+	}, true, nil
+}
+
+// changed lists the columns of new that differ from old, sorted like the trigger's. Without the
+// old row, with a replica identity other than FULL (see Setup), it is nil.
+func changed(old, new Row) []string {
+	if old == nil {
+		return nil
+	}
+	var out []string
+	for name, value := range new {
+		before, ok := old[name]
+		if !ok || (before == nil) != (value == nil) || (before != nil && *before != *value) {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func text(row Row, column string) string {
+	if v := row[column]; v != nil {
+		return *v
+	}
+	return ""
+}
+
+func intColumn(row Row, column string) (int32, error) {
+	n, err := strconv.ParseInt(text(row, column), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("column %s: %w", column, err)
+	}
+	return int32(n), nil
+}
@@ -0,0 +1,119 @@
+package cdc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/events"
+)
+
+func ptr(s string) *string { return &s }
+
+// document returns a documents row; the columns given in override replace or, as nil, null
+// the defaults.
+func document(override Row) Row {
+	row := Row{
+		"id":         ptr("42"),
+		"tenant_id":  ptr("7"),
+		"title":      ptr("Lease"),
+		"content":    ptr("long"),
+		"status":     ptr("draft"),
+		"updated_at": ptr("2026-01-02 03:04:05.678901"),
+	}
+	for k, v := range override {
+		row[k] = v
+	}
+	return row
+}
+
+func TestDocumentEvent(t *testing.T) {
+	documents := &Relation{Namespace: "public", Name: "documents"}
+	at := Position{LSN: 0x16_B374D848, Index: 2}
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 678901000, time.UTC)
+	withoutContent := document(nil)
+	delete(withoutContent, "content")
+
+	for _, tc := range []struct {
+		name   string
+		change Change
+		want   events.DocumentEvent
+		skip   bool
+	}{
+		{
+			name:   "insert",
+			change: Change{Operation: Insert, New: document(nil)},
+			want:   events.DocumentEvent{Type: events.DocumentCreated, Title: "Lease", Status: "draft"},
+		},
+		{
+			name:   "status change",
+			change: Change{Operation: Update, Old: document(nil), New: document(Row{"status": ptr("auditing")})},
+			want: events.DocumentEvent{Type: events.DocumentStatusChanged, Title: "Lease", Status: "auditing",
+				PreviousStatus: "draft", Changed: []string{"status"}},
+		},
+		{
+			name: "unchanged TOASTed content",
+			change: Change{Operation: Update, Old: document(nil),
+				New: func() Row { r := document(Row{"title": ptr("Lease 2")}); delete(r, "content"); return r }()},
+			want: events.DocumentEvent{Type: events.DocumentUpdated, Title: "Lease 2", Status: "draft", Changed: []string{"title"}},
+		},
+		{
+			name:   "column set to null",
+			change: Change{Operation: Update, Old: document(nil), New: document(Row{"title": nil})},
+			want:   events.DocumentEvent{Type: events.DocumentUpdated, Status: "draft", Changed: []string{"title"}},
+		},
+		{
+			name:   "column set from null",
+			change: Change{Operation: Update, Old: document(Row{"content": nil}), New: document(nil)},
+			want:   events.DocumentEvent{Type: events.DocumentUpdated, Title: "Lease", Status: "draft", Changed: []string{"content"}},
+		},
+		{
+			name:   "update without the old row",
+			change: Change{Operation: Update, New: withoutContent},
+			want:   events.DocumentEvent{Type: events.DocumentUpdated, Title: "Lease", Status: "draft"},
+		},
+		{
+			name:   "update changing nothing",
+			change: Change{Operation: Update, Old: document(nil), New: withoutContent},
+			skip:   true,
+		},
+		{
+			name:   "delete",
+			change: Change{Operation: Delete, Old: document(Row{"status": ptr("archived")})},
+			want:   events.DocumentEvent{Type: events.DocumentDeleted, Title: "Lease", Status: "archived"},
+		},
+	} {
+		tc.change.Relation, tc.change.Position, tc.change.CommittedAt = documents, at, updated
+		got, ok, err := DocumentEvent(tc.change)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if ok == tc.skip {
+			t.Errorf("%s: got ok %v", tc.name, ok)
+			continue
+		}
+		if tc.skip {
+			continue
+		}
+		tc.want.DocumentID, tc.want.TenantID, tc.want.UpdatedAt = 42, 7, updated
+		want := Event{DocumentEvent: tc.want, TenantID: 7, LSN: "16/B374D848", Index: 2, CommittedAt: updated}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tc.name, got, want)
+		}
+	}
+}
+
+func TestDocumentEventErrors(t *testing.T) {
+	documents := &Relation{Namespace: "public", Name: "documents"}
+	for name, c := range map[string]Change{
+		"other table":        {Operation: Insert, Relation: &Relation{Namespace: "public", Name: "comments"}, New: document(nil)},
+		"null id":            {Operation: Insert, Relation: documents, New: document(Row{"id": nil})},
+		"invalid tenant":     {Operation: Insert, Relation: documents, New: document(Row{"tenant_id": ptr("x")})},
+		"invalid updated_at": {Operation: Insert, Relation: documents, New: document(Row{"updated_at": ptr("yesterday")})},
+	} {
+		if e, _, err := DocumentEvent(c); err == nil {
+			t.Errorf("%s: got %+v, want an error", name, e)
+		}
+	}
+}
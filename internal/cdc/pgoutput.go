@@ -0,0 +1,230 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LSN is a position in the write-ahead log.
+type LSN uint64
+
+// String renders l like Postgres does, e.g. 16/B374D848.
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// The replication protocol counts time in microseconds since 2000-01-01 UTC.
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func pgTime(micros int64) time.Time {
+	return postgresEpoch.Add(time.Duration(micros) * time.Microsecond)
+}
+
+func pgMicros(t time.Time) int64 {
+	return t.Sub(postgresEpoch).Microseconds()
+}
+
+// Messages of the pgoutput plugin, protocol version 1, which this package needs. Origin and
+// type messages are skipped.
+type (
+	beginMessage struct {
+		// FinalLSN is the LSN of the transaction's commit record.
+		FinalLSN   LSN
+		CommitTime time.Time
+		XID        uint32
+	}
+	commitMessage struct {
+		CommitLSN LSN
+		// EndLSN is the end of the transaction in the log, the position to confirm once it has
+		// been handled.
+		EndLSN     LSN
+		CommitTime time.Time
+	}
+	// Relation describes a table. The server sends it before the first change to the table on
+	// every connection, and again after its schema changed.
+	Relation struct {
+		ID        uint32
+		Namespace string
+		Name      string
+		Columns   []string
+	}
+	insertMessage struct {
+		RelationID uint32
+		New        []datum
+	}
+	// updateMessage has Old only if the table's replica identity covers changed columns or
+	// is FULL.
+	updateMessage struct {
+		RelationID uint32
+		Old        []datum
+		New        []datum
+	}
+	deleteMessage struct {
+		RelationID uint32
+		Old        []datum
+	}
+	truncateMessage struct {
+		RelationIDs []uint32
+	}
+)
+
+// datum is one column of a tuple.
+type datum struct {
+	// kind is 'n' for null, 'u' for an unchanged TOASTed value that is not sent, or 't' for text
+	kind byte
+	text string
+}
+
+var errShort = errors.New("pgoutput: message too short")
+
+// decoder reads the fields of one message.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errShort
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uint8() byte {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	for i, c := range d.buf {
+		if c == 0 {
+			s := string(d.buf[:i])
+			d.buf = d.buf[i+1:]
+			return s
+		}
+	}
+	d.err = errShort
+	return ""
+}
+
+func (d *decoder) tuple() []datum {
+	n := int(d.uint16())
+	if d.err != nil {
+		return nil
+	}
+	tuple := make([]datum, n)
+	for i := range tuple {
+		tuple[i].kind = d.uint8()
+		switch tuple[i].kind {
+		case 'n', 'u':
+		case 't':
+			size := d.uint32()
+			tuple[i].text = string(d.take(int(size)))
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("pgoutput: unknown column kind %q", tuple[i].kind)
+			}
+			return nil
+		}
+	}
+	return tuple
+}
+
+// parse decodes a pgoutput message. It returns nil for messages this package ignores.
+func parse(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, errShort
+	}
+	d := &decoder{buf: data[1:]}
+	var msg any
+	switch data[0] {
+	case 'B':
+		msg = beginMessage{FinalLSN: LSN(d.uint64()), CommitTime: pgTime(int64(d.uint64())), XID: d.uint32()}
+	case 'C':
+		d.uint8() // flags, unused
+		msg = commitMessage{CommitLSN: LSN(d.uint64()), EndLSN: LSN(d.uint64()), CommitTime: pgTime(int64(d.uint64()))}
+	case 'R':
+		r := Relation{ID: d.uint32(), Namespace: d.string(), Name: d.string()}
+		d.uint8() // replica identity setting
+		r.Columns = make([]string, d.uint16())
+		for i := range r.Columns {
+			d.uint8() // flags: part of the key
+			r.Columns[i] = d.string()
+			d.uint32() // type OID
+			d.uint32() // type modifier
+		}
+		msg = r
+	case 'I':
+		m := insertMessage{RelationID: d.uint32()}
+		if tag := d.uint8(); tag != 'N' && d.err == nil {
+			return nil, fmt.Errorf("pgoutput: unexpected tuple %q in insert", tag)
+		}
+		m.New = d.tuple()
+		msg = m
+	case 'U':
+		m := updateMessage{RelationID: d.uint32()}
+		tag := d.uint8()
+		if tag == 'K' || tag == 'O' {
+			m.Old = d.tuple()
+			tag = d.uint8()
+		}
+		if tag != 'N' && d.err == nil {
+			return nil, fmt.Errorf("pgoutput: unexpected tuple %q in update", tag)
+		}
+		m.New = d.tuple()
+		msg = m
+	case 'D':
+		m := deleteMessage{RelationID: d.uint32()}
+		if tag := d.uint8(); tag != 'K' && tag != 'O' && d.err == nil {
+			return nil, fmt.Errorf("pgoutput: unexpected tuple %q in delete", tag)
+		}
+		m.Old = d.tuple()
+		msg = m
+	case 'T':
+		m := truncateMessage{RelationIDs: make([]uint32, d.uint32())}
+		d.uint8() // options: CASCADE, RESTART IDENTITY
+		for i := range m.RelationIDs {
+			m.RelationIDs[i] = d.uint32()
+		}
+		msg = m
+	case 'O', 'Y':
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("pgoutput: unknown message type %q", data[0])
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("decoding %q message: %w", data[0], d.err)
+	}
+	return msg, nil
+}
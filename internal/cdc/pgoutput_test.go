@@ -0,0 +1,207 @@
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// msg builds a pgoutput message in the wire format the server sends.
+type msg []byte
+
+func (m msg) u8(b byte) msg        { return append(m, b) }
+func (m msg) u16(v uint16) msg     { return binary.BigEndian.AppendUint16(m, v) }
+func (m msg) u32(v uint32) msg     { return binary.BigEndian.AppendUint32(m, v) }
+func (m msg) u64(v uint64) msg     { return binary.BigEndian.AppendUint64(m, v) }
+func (m msg) str(s string) msg     { return append(append(m, s...), 0) }
+func (m msg) time(t time.Time) msg { return m.u64(uint64(pgMicros(t))) }
+
+func (m msg) tuple(columns ...datum) msg {
+	m = m.u16(uint16(len(columns)))
+	for _, c := range columns {
+		m = m.u8(c.kind)
+		if c.kind == 't' {
+			m = append(m.u32(uint32(len(c.text))), c.text...)
+		}
+	}
+	return m
+}
+
+// val is a column sent in text form.
+func val(s string) datum { return datum{kind: 't', text: s} }
+
+var (
+	null    = datum{kind: 'n'}
+	toasted = datum{kind: 'u'}
+)
+
+var committed = time.Date(2026, 1, 2, 3, 4, 5, 678901000, time.UTC)
+
+func begin(final LSN, xid uint32) msg {
+	return msg{'B'}.u64(uint64(final)).time(committed).u32(xid)
+}
+
+func commit(lsn, end LSN) msg {
+	return msg{'C'}.u8(0).u64(uint64(lsn)).u64(uint64(end)).time(committed)
+}
+
+// documentsRelation describes a documents table with the columns the tests use.
+func documentsRelation(id uint32) msg {
+	m := msg{'R'}.u32(id).str("public").str("documents").u8('f').u16(5)
+	for i, name := range []string{"id", "tenant_id", "title", "content", "status"} {
+		key := byte(0)
+		if i == 0 {
+			key = 1
+		}
+		m = m.u8(key).str(name).u32(25).u32(0xffffffff)
+	}
+	return m
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data msg
+		want any
+	}{
+		{"begin", begin(0x16_B374D848, 731), beginMessage{FinalLSN: 0x16_B374D848, CommitTime: committed, XID: 731}},
+		{"commit", commit(0x100, 0x128), commitMessage{CommitLSN: 0x100, EndLSN: 0x128, CommitTime: committed}},
+		{"relation", documentsRelation(16384), Relation{ID: 16384, Namespace: "public", Name: "documents",
+			Columns: []string{"id", "tenant_id", "title", "content", "status"}}},
+		{"insert", msg{'I'}.u32(16384).u8('N').tuple(val("42"), val("1"), val("Lease"), null, val("draft")),
+			insertMessage{RelationID: 16384, New: []datum{val("42"), val("1"), val("Lease"), null, val("draft")}}},
+		{"update with old row", msg{'U'}.u32(16384).u8('O').tuple(val("42"), val("1"), val("Lease"), val("long"), val("draft")).
+			u8('N').tuple(val("42"), val("1"), val("Lease"), toasted, val("auditing")),
+			updateMessage{RelationID: 16384,
+				Old: []datum{val("42"), val("1"), val("Lease"), val("long"), val("draft")},
+				New: []datum{val("42"), val("1"), val("Lease"), toasted, val("auditing")}}},
+		{"update with key", msg{'U'}.u32(16384).u8('K').tuple(val("41"), null, null, null, null).
+			u8('N').tuple(val("42"), val("1"), val(""), null, val("draft")),
+			updateMessage{RelationID: 16384,
+				Old: []datum{val("41"), null, null, null, null},
+				New: []datum{val("42"), val("1"), val(""), null, val("draft")}}},
+		{"update without old row", msg{'U'}.u32(16384).u8('N').tuple(val("42"), val("1"), val("Lease"), toasted, val("draft")),
+			updateMessage{RelationID: 16384, New: []datum{val("42"), val("1"), val("Lease"), toasted, val("draft")}}},
+		{"delete", msg{'D'}.u32(16384).u8('O').tuple(val("42"), val("1"), val("Lease"), val("long"), val("draft")),
+			deleteMessage{RelationID: 16384, Old: []datum{val("42"), val("1"), val("Lease"), val("long"), val("draft")}}},
+		{"truncate", msg{'T'}.u32(2).u8(1).u32(16384).u32(16390), truncateMessage{RelationIDs: []uint32{16384, 16390}}},
+		{"origin", msg{'O'}.u64(0x100).str("upstream"), nil},
+		{"type", msg{'Y'}.u32(25).str("pg_catalog").str("text"), nil},
+	} {
+		got, err := parse(tc.data)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	full := msg{'I'}.u32(16384).u8('N').tuple(val("42"), val("1"))
+	for _, tc := range []struct {
+		name string
+		data msg
+	}{
+		{"empty", msg{}},
+		{"unknown type", msg{'Z'}},
+		{"truncated header", begin(0x100, 1)[:10]},
+		{"truncated tuple", full[:len(full)-1]},
+		{"unterminated name", msg{'R'}.u32(1).u8('p')},
+		{"unknown column kind", msg{'I'}.u32(16384).u8('N').u16(1).u8('x')},
+		{"insert without new row", msg{'I'}.u32(16384).u8('O').tuple(val("42"))},
+		{"update without new row", msg{'U'}.u32(16384).u8('O').tuple(val("42")).u8('O').tuple(val("42"))},
+		{"delete without old row", msg{'D'}.u32(16384).u8('N').tuple(val("42"))},
+	} {
+		if got, err := parse(tc.data); err == nil {
+			t.Errorf("%s: got %+v, want an error", tc.name, got)
+		}
+	}
+	if _, err := parse(msg{'B'}.u64(1)); !errors.Is(err, errShort) {
+		t.Errorf("got %v, want errShort for a short message", err)
+	}
+}
+
+func TestSessionChanges(t *testing.T) {
+	var changes []Change
+	s := &session{
+		reader:    &Reader{log: logging.Component("cdc")},
+		relations: map[uint32]*Relation{},
+		handler: func(_ context.Context, c Change) error {
+			changes = append(changes, c)
+			return nil
+		},
+	}
+	ctx := context.Background()
+	for _, m := range []msg{
+		begin(0x200, 7),
+		documentsRelation(16384),
+		msg{'I'}.u32(16384).u8('N').tuple(val("42"), val("1"), val("Lease"), null, val("draft")),
+		msg{'U'}.u32(16384).u8('O').tuple(val("42"), val("1"), val("Lease"), val("long"), val("draft")).
+			u8('N').tuple(val("42"), val("1"), val("Lease"), toasted, val("auditing")),
+		msg{'D'}.u32(16384).u8('O').tuple(val("42"), val("1"), val("Lease"), null, val("auditing")),
+		commit(0x200, 0x228),
+	} {
+		if err := s.handle(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3", len(changes))
+	}
+	for i, c := range changes {
+		if c.Position != (Position{LSN: 0x200, Index: i}) || c.XID != 7 || !c.CommittedAt.Equal(committed) {
+			t.Errorf("change %d at %+v of %d at %s", i, c.Position, c.XID, c.CommittedAt)
+		}
+		if c.Relation.Name != "documents" {
+			t.Errorf("change %d of %s", i, c.Relation.Name)
+		}
+	}
+	insert, update, del := changes[0], changes[1], changes[2]
+	if insert.Operation != Insert || insert.Old != nil {
+		t.Errorf("insert: %+v", insert)
+	}
+	if v, ok := insert.New["content"]; !ok || v != nil {
+		t.Errorf("a null column is %v (present %v), want present and nil", v, ok)
+	}
+	if _, ok := update.New["content"]; ok {
+		t.Error("an unchanged TOASTed column is in the new row")
+	}
+	if *update.Old["content"] != "long" || *update.New["status"] != "auditing" {
+		t.Errorf("update: %+v", update)
+	}
+	if del.Operation != Delete || del.New != nil || *del.Old["id"] != "42" {
+		t.Errorf("delete: %+v", del)
+	}
+	if s.tx != nil || s.confirmed != 0x228 {
+		t.Errorf("after the commit tx is %v and confirmed %s, want nil and 0/228", s.tx, s.confirmed)
+	}
+}
+
+func TestSessionRejects(t *testing.T) {
+	s := &session{reader: &Reader{log: logging.Component("cdc")}, relations: map[uint32]*Relation{},
+		handler: func(context.Context, Change) error { return nil }}
+	ctx := context.Background()
+	insert := msg{'I'}.u32(16384).u8('N').tuple(val("42"))
+	if err := s.handle(ctx, insert); err == nil {
+		t.Error("accepted a change outside a transaction")
+	}
+	s.handle(ctx, begin(0x200, 7))
+	if err := s.handle(ctx, insert); err == nil {
+		t.Error("accepted a change of a relation that was not described")
+	}
+}
+
+func TestLSN(t *testing.T) {
+	if got := LSN(0x16_B374D848).String(); got != "16/B374D848" {
+		t.Errorf("got %s", got)
+	}
+}
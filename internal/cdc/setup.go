@@ -0,0 +1,53 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Setup prepares the documents table for a Reader of publication: it creates the publication
+// unless it exists and sets the table's replica identity to FULL, so that updates and deletes
+// carry the whole old row and DocumentEvent can name the previous status, the changed columns and
+// the tenant of a deleted document. That writes the old row, content included, to the WAL on every
+// update and delete, which is why it is left to deployments that run lawdocs cdc.
+func Setup(ctx context.Context, pool *pgxpool.Pool, publication string) error {
+	if !identifier.MatchString(publication) {
+		return fmt.Errorf("cdc: invalid publication name %q", publication)
+	}
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "alter table documents replica identity full"); err != nil {
+			return fmt.Errorf("unable to set the replica identity: %w", err)
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, "select exists (select 1 from pg_publication where pubname = $1)", publication).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		if _, err := tx.Exec(ctx, "create publication "+publication+" for table documents"); err != nil {
+			return fmt.Errorf("unable to create publication: %w", err)
+		}
+		return nil
+	})
+}
+
+// Teardown drops the publication and restores the default replica identity of the documents
+// table, undoing Setup. Drop the slots of the publication's readers first.
+func Teardown(ctx context.Context, pool *pgxpool.Pool, publication string) error {
+	if !identifier.MatchString(publication) {
+		return fmt.Errorf("cdc: invalid publication name %q", publication)
+	}
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "drop publication if exists "+publication); err != nil {
+			return fmt.Errorf("unable to drop publication: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "alter table documents replica identity default"); err != nil {
+			return fmt.Errorf("unable to reset the replica identity: %w", err)
+		}
+		return nil
+	})
+}
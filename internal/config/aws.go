@@ -0,0 +1,26 @@
+package config
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// AWS builds the SDK config shared by the S3 and SQS clients.
+// When AWSEndpoint is set every service is routed to it, which is how LocalStack is used in development.
+func (cfg *Config) AWS(ctx context.Context) (aws.Config, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.AWSRegion),
+	}
+	if cfg.AWSEndpoint != "" {
+		opts = append(opts, awsconfig.WithBaseEndpoint(cfg.AWSEndpoint))
+	}
+	if cfg.AWSKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AWSKeyID, cfg.AWSSecret, ""),
+		))
+	}
+	return awsconfig.LoadDefaultConfig(ctx, opts...)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"time"
+)
+
+// Config holds the settings shared by every lawdocs subcommand.
+// Values are read from the environment first and can be overridden by flags.
+type Config struct {
+	DatabaseURL string
+	RedisAddr   string
+
+	AWSRegion   string
+	AWSEndpoint string
+	AWSKeyID    string
+	AWSSecret   string
+	Bucket      string
+	QueueURL    string
+
+	// AdminToken, when set, must be sent as a bearer token on /api/v1/admin requests.
+	// Document routes use per-tenant tokens issued with `lawdocs admin token-create`.
+	AdminToken string
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse proxies whose
+	// X-Forwarded-For header names the client. With none, the client IP is the peer address.
+	TrustedProxies string
+
+	LogLevel  string
+	LogFormat string
+
+	// TracingExporter is one of "none", "otlp" or "stdout".
+	TracingExporter string
+	// OTLPEndpoint overrides OTEL_EXPORTER_OTLP_ENDPOINT for the otlp exporter.
+	OTLPEndpoint string
+
+	// ShutdownTimeout bounds how long components get to drain after a shutdown signal.
+	ShutdownTimeout time.Duration
+}
+
+// Load returns a Config populated from the environment, falling back to the
+// local development defaults used by docker-compose.
+func Load() *Config {
+	return &Config{
+		DatabaseURL: env("DATABASE_URL", "postgres://boya:@localhost:28813/law_docs"),
+		RedisAddr:   env("REDIS_ADDR", "localhost:6380"),
+		AWSRegion:   env("AWS_REGION", "us-east-1"),
+		AWSEndpoint: env("AWS_ENDPOINT_URL", "http://localhost:4566"),
+		AWSKeyID:    env("AWS_ACCESS_KEY_ID", "test"),
+		AWSSecret:   env("AWS_SECRET_ACCESS_KEY", "test"),
+		Bucket:      env("S3_BUCKET", "test"),
+		QueueURL:    env("SQS_QUEUE_URL", "http://sqs.eu-west-1.localhost.localstack.cloud:4566/000000000000/my-queue"),
+		AdminToken:  env("ADMIN_TOKEN", ""),
+		LogLevel:    env("LOG_LEVEL", "info"),
+		LogFormat:   env("LOG_FORMAT", "text"),
+
+		TrustedProxies: env("TRUSTED_PROXIES", ""),
+
+		TracingExporter: env("TRACING_EXPORTER", "none"),
+		OTLPEndpoint:    env("OTLP_ENDPOINT", ""),
+
+		ShutdownTimeout: envDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+}
+
+// RegisterFlags binds the global flags to cfg, using the current values as defaults.
+func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.DatabaseURL, "database-url", cfg.DatabaseURL, "Postgres connection string")
+	fs.StringVar(&cfg.RedisAddr, "redis-addr", cfg.RedisAddr, "Redis address")
+	fs.StringVar(&cfg.AWSRegion, "aws-region", cfg.AWSRegion, "AWS region")
+	fs.StringVar(&cfg.AWSEndpoint, "aws-endpoint", cfg.AWSEndpoint, "AWS endpoint override, empty for the real AWS endpoints")
+	fs.StringVar(&cfg.Bucket, "bucket", cfg.Bucket, "S3 bucket for uploaded documents")
+	fs.StringVar(&cfg.QueueURL, "queue-url", cfg.QueueURL, "SQS queue URL for document notifications")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "log level (debug, info, warn, error)")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log format (text, json)")
+	fs.StringVar(&cfg.TracingExporter, "tracing-exporter", cfg.TracingExporter, "trace exporter (none, otlp, stdout)")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", cfg.OTLPEndpoint, "OTLP/HTTP endpoint URL, e.g. http://localhost:4318")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "deadline for draining work on shutdown")
+}
+
+func env(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
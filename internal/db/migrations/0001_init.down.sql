@@ -0,0 +1,4 @@
+drop trigger if exists doc_notify on documents;
+drop function if exists notify_document_change();
+drop table if exists documents;
+drop table if exists users;
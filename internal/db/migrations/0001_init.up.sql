@@ -20,11 +20,6 @@ create table if not exists documents (
     file_path text
 );
 
-
-create trigger doc_notify
-    after insert or update on documents
-    for each row
-    execute function notify_document_change();
 create or replace function notify_document_change()
     returns trigger as $$
 declare
@@ -44,5 +39,8 @@ begin
 end;
 $$ language plpgsql;
 
-
-    
\ No newline at end of file
+drop trigger if exists doc_notify on documents;
+create trigger doc_notify
+    after insert or update on documents
+    for each row
+    execute function notify_document_change();
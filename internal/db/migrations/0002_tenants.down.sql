@@ -0,0 +1,15 @@
+drop policy if exists tenant_isolation on documents;
+alter table documents no force row level security;
+alter table documents disable row level security;
+drop policy if exists tenant_isolation on users;
+alter table users no force row level security;
+alter table users disable row level security;
+
+drop table if exists api_tokens;
+
+alter table documents drop constraint if exists documents_author_tenant_fkey;
+alter table documents drop column if exists tenant_id;
+alter table users drop constraint if exists users_id_tenant_id_key;
+alter table users drop column if exists tenant_id;
+
+drop table if exists tenants;
@@ -0,0 +1,55 @@
+create table tenants (
+    id serial primary key,
+    name text not null unique,
+    created_at timestamp default current_timestamp
+);
+
+-- everything created before tenants existed belongs to the default firm
+insert into tenants (id, name) values (1, 'default');
+select setval('tenants_id_seq', (select max(id) from tenants));
+
+-- tenant_id defaults to the tenant of the current transaction, see internal/tenant
+alter table users add column tenant_id integer references tenants(id);
+update users set tenant_id = 1;
+alter table users
+    alter column tenant_id set not null,
+    alter column tenant_id set default nullif(current_setting('app.tenant_id', true), '')::integer,
+    add constraint users_id_tenant_id_key unique (id, tenant_id);
+create index users_tenant_id_idx on users (tenant_id);
+
+alter table documents add column tenant_id integer references tenants(id);
+update documents set tenant_id = 1;
+alter table documents
+    alter column tenant_id set not null,
+    alter column tenant_id set default nullif(current_setting('app.tenant_id', true), '')::integer,
+    add constraint documents_author_tenant_fkey foreign key (author_id, tenant_id) references users (id, tenant_id);
+create index documents_tenant_id_idx on documents (tenant_id);
+
+create table api_tokens (
+    id serial primary key,
+    tenant_id integer not null references tenants(id),
+    user_id integer not null,
+    name text not null,
+    token_hash bytea not null unique,
+    created_at timestamp default current_timestamp,
+    revoked_at timestamp,
+    foreign key (user_id, tenant_id) references users (id, tenant_id)
+);
+
+-- Rows are visible only to transactions that set app.tenant_id to their tenant, or app.system
+-- for background jobs acting on every tenant. force applies the policies to the table owner too.
+alter table users enable row level security;
+alter table users force row level security;
+create policy tenant_isolation on users
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
+
+alter table documents enable row level security;
+alter table documents force row level security;
+create policy tenant_isolation on documents
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
@@ -0,0 +1,44 @@
+-- Append-only log of every document access and change. Each row's hash covers its contents and
+-- the previous row's hash within the same tenant, so editing or removing a row breaks the chain.
+create table audit_events (
+    id bigserial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    occurred_at timestamptz not null,
+    actor_id integer,
+    action text not null,
+    -- no foreign key: deleted documents keep their history
+    document_id integer,
+    ip text,
+    user_agent text,
+    request_id text,
+    before_hash bytea,
+    after_hash bytea,
+    prev_hash bytea,
+    hash bytea not null
+);
+create index audit_events_tenant_id_id_idx on audit_events (tenant_id, id);
+create index audit_events_document_id_idx on audit_events (document_id);
+
+create or replace function audit_events_append_only()
+    returns trigger as $$
+begin
+    raise exception 'audit_events is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_events_append_only
+    before update or delete on audit_events
+    for each row
+    execute function audit_events_append_only();
+create trigger audit_events_no_truncate
+    before truncate on audit_events
+    for each statement
+    execute function audit_events_append_only();
+
+alter table audit_events enable row level security;
+alter table audit_events force row level security;
+create policy tenant_isolation on audit_events
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer);
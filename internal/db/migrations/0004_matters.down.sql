@@ -0,0 +1,12 @@
+drop table if exists document_tags;
+
+alter table documents drop constraint if exists documents_folder_requires_matter;
+alter table documents drop constraint if exists documents_folder_matter_tenant_fkey;
+alter table documents drop constraint if exists documents_matter_tenant_fkey;
+alter table documents drop constraint if exists documents_id_tenant_id_key;
+alter table documents drop column if exists folder_id;
+alter table documents drop column if exists matter_id;
+
+drop table if exists tags;
+drop table if exists folders;
+drop table if exists matters;
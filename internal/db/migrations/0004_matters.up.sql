@@ -0,0 +1,95 @@
+-- Matters (cases) group a firm's documents. Within a matter, folders form a tree; tags cut across
+-- matters. Composite foreign keys include tenant_id so nothing can link rows of two tenants.
+create table matters (
+    id serial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    name text not null,
+    reference text,
+    description text,
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp,
+    unique (tenant_id, name),
+    unique (id, tenant_id)
+);
+
+create table folders (
+    id serial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    matter_id integer not null,
+    -- null for top-level folders of the matter
+    parent_id integer,
+    name text not null,
+    created_at timestamp default current_timestamp,
+    unique (id, matter_id, tenant_id),
+    foreign key (matter_id, tenant_id) references matters (id, tenant_id) on delete cascade,
+    -- a parent must belong to the same matter
+    foreign key (parent_id, matter_id, tenant_id) references folders (id, matter_id, tenant_id) on delete cascade
+);
+create unique index folders_matter_id_parent_id_name_idx on folders (matter_id, coalesce(parent_id, 0), name);
+
+create table tags (
+    id serial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    name text not null,
+    created_at timestamp default current_timestamp,
+    unique (tenant_id, name),
+    unique (id, tenant_id)
+);
+
+-- matters and folders with documents cannot be deleted; move the documents first
+alter table documents
+    add column matter_id integer,
+    add column folder_id integer,
+    add constraint documents_id_tenant_id_key unique (id, tenant_id),
+    add constraint documents_matter_tenant_fkey foreign key (matter_id, tenant_id) references matters (id, tenant_id),
+    add constraint documents_folder_matter_tenant_fkey foreign key (folder_id, matter_id, tenant_id)
+        references folders (id, matter_id, tenant_id),
+    add constraint documents_folder_requires_matter check (folder_id is null or matter_id is not null);
+create index documents_matter_id_idx on documents (matter_id);
+create index documents_folder_id_idx on documents (folder_id);
+
+create table document_tags (
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    document_id integer not null,
+    tag_id integer not null,
+    primary key (document_id, tag_id),
+    foreign key (document_id, tenant_id) references documents (id, tenant_id) on delete cascade,
+    foreign key (tag_id, tenant_id) references tags (id, tenant_id) on delete cascade
+);
+create index document_tags_tag_id_idx on document_tags (tag_id);
+
+alter table matters enable row level security;
+alter table matters force row level security;
+create policy tenant_isolation on matters
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
+
+alter table folders enable row level security;
+alter table folders force row level security;
+create policy tenant_isolation on folders
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
+
+alter table tags enable row level security;
+alter table tags force row level security;
+create policy tenant_isolation on tags
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
+
+alter table document_tags enable row level security;
+alter table document_tags force row level security;
+create policy tenant_isolation on document_tags
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
@@ -0,0 +1,2 @@
+drop table if exists comment_mentions;
+drop table if exists comments;
@@ -0,0 +1,51 @@
+-- Comments are threaded per document: a thread is a root comment and its replies (parent_id set).
+-- Only roots carry an anchor into the document and can be resolved.
+create table comments (
+    id serial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    document_id integer not null,
+    parent_id integer,
+    author_id integer not null,
+    body text not null,
+    -- {"type": "text", "start", "end", "quote"} or {"type": "pdf", "page", "x", "y", "width", "height"}
+    anchor jsonb,
+    created_at timestamp default current_timestamp,
+    resolved_at timestamp,
+    resolved_by integer,
+    unique (id, tenant_id),
+    unique (id, document_id, tenant_id),
+    foreign key (document_id, tenant_id) references documents (id, tenant_id) on delete cascade,
+    foreign key (parent_id, document_id, tenant_id) references comments (id, document_id, tenant_id) on delete cascade,
+    foreign key (author_id, tenant_id) references users (id, tenant_id),
+    foreign key (resolved_by, tenant_id) references users (id, tenant_id),
+    check (parent_id is null or (anchor is null and resolved_at is null))
+);
+create index comments_document_id_idx on comments (document_id, id);
+
+create table comment_mentions (
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    comment_id integer not null,
+    user_id integer not null,
+    primary key (comment_id, user_id),
+    foreign key (comment_id, tenant_id) references comments (id, tenant_id) on delete cascade,
+    foreign key (user_id, tenant_id) references users (id, tenant_id)
+);
+create index comment_mentions_user_id_idx on comment_mentions (user_id, comment_id);
+
+alter table comments enable row level security;
+alter table comments force row level security;
+create policy tenant_isolation on comments
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
+
+alter table comment_mentions enable row level security;
+alter table comment_mentions force row level security;
+create policy tenant_isolation on comment_mentions
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
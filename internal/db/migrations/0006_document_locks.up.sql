@@ -0,0 +1,21 @@
+-- Advisory edit locks: at most one user edits a document at a time. A lock past expires_at is
+-- free for anyone to take; holders renew it while they keep editing.
+create table document_locks (
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    document_id integer not null,
+    user_id integer not null,
+    acquired_at timestamp not null,
+    expires_at timestamp not null,
+    primary key (document_id, tenant_id),
+    foreign key (document_id, tenant_id) references documents (id, tenant_id) on delete cascade,
+    foreign key (user_id, tenant_id) references users (id, tenant_id) on delete cascade
+);
+
+alter table document_locks enable row level security;
+alter table document_locks force row level security;
+create policy tenant_isolation on document_locks
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
@@ -0,0 +1,20 @@
+drop index if exists documents_updated_at_idx;
+
+create or replace function notify_document_change()
+    returns trigger as $$
+declare
+    payload text;
+    channel text := 'document_changes';
+begin
+    payload := json_build_object(
+        'operation', TG_OP,
+        'schema', TG_TABLE_NAME,
+        'table', TG_TABLE_NAME,
+        'record', to_jsonb(NEW),
+        'old_record', to_jsonb(OLD)
+    )::text;
+
+    perform pg_notify(channel, payload);
+    return NEW;
+end;
+$$ language plpgsql;
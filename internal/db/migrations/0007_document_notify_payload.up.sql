@@ -0,0 +1,34 @@
+-- NOTIFY payloads are limited to 8000 bytes, which to_jsonb(NEW) exceeds for any real document
+-- content. The trigger now sends the document's identity, status and the names of the changed
+-- columns; listeners read anything else from the table. Titles are cut to 1000 characters.
+create or replace function notify_document_change()
+    returns trigger as $$
+declare
+    changed text[];
+begin
+    if TG_OP = 'UPDATE' then
+        select coalesce(array_agg(n.key order by n.key), '{}') into changed
+        from jsonb_each(to_jsonb(NEW)) n
+        where n.value is distinct from to_jsonb(OLD) -> n.key;
+        if cardinality(changed) = 0 then
+            return NEW;
+        end if;
+    end if;
+
+    perform pg_notify('document_changes', json_build_object(
+        'operation', TG_OP,
+        'table', TG_TABLE_NAME,
+        'id', NEW.id,
+        'tenant_id', NEW.tenant_id,
+        'title', left(NEW.title, 1000),
+        'status', NEW.status,
+        'old_status', case when TG_OP = 'UPDATE' then OLD.status end,
+        'updated_at', NEW.updated_at,
+        'changed', changed
+    )::text);
+    return NEW;
+end;
+$$ language plpgsql;
+
+-- listeners catch up on the changes they missed while disconnected by updated_at
+create index if not exists documents_updated_at_idx on documents (updated_at, id);
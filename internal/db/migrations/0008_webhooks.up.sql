@@ -0,0 +1,58 @@
+-- Outgoing webhooks: a tenant's subscriptions to document events, and the log of their deliveries.
+-- A delivery is pending until it succeeds or runs out of attempts; webhooks whose deliveries keep
+-- failing are disabled.
+create table webhooks (
+    id serial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    url text not null,
+    events text[] not null,
+    -- HMAC-SHA256 key of the signatures, readable because the server signs with it
+    secret text not null,
+    enabled boolean not null default true,
+    -- deliveries that failed every attempt since the last success
+    consecutive_failures integer not null default 0,
+    disabled_at timestamp,
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp,
+    unique (id, tenant_id)
+);
+
+create table webhook_deliveries (
+    id serial primary key,
+    tenant_id integer not null references tenants(id)
+        default nullif(current_setting('app.tenant_id', true), '')::integer,
+    webhook_id integer not null,
+    event_type text not null,
+    -- the document version an event describes: every replica receives the same notification, and
+    -- events replayed after a reconnect repeat it, but each is delivered once
+    event_key text not null,
+    payload jsonb not null,
+    status text not null default 'pending' check (status in ('pending', 'succeeded', 'failed')),
+    attempts integer not null default 0,
+    next_attempt_at timestamp not null default current_timestamp,
+    response_status integer,
+    last_error text,
+    created_at timestamp default current_timestamp,
+    delivered_at timestamp,
+    unique (webhook_id, event_key),
+    foreign key (webhook_id, tenant_id) references webhooks (id, tenant_id) on delete cascade
+);
+create index webhook_deliveries_webhook_id_idx on webhook_deliveries (webhook_id, id);
+create index webhook_deliveries_pending_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
+
+alter table webhooks enable row level security;
+alter table webhooks force row level security;
+create policy tenant_isolation on webhooks
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
+
+alter table webhook_deliveries enable row level security;
+alter table webhook_deliveries force row level security;
+create policy tenant_isolation on webhook_deliveries
+    using (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on')
+    with check (tenant_id = nullif(current_setting('app.tenant_id', true), '')::integer
+        or current_setting('app.system', true) = 'on');
@@ -0,0 +1,9 @@
+// Package migrations embeds the numbered SQL migrations that define the database schema.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql; sqlc reads the
+// same directory so the generated queries in internal/db always match the migrated schema.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -9,6 +9,51 @@ import (
 	"github.com/wilbyang/law-docs/internal/models"
 )
 
+type ApiToken struct {
+	ID        int32
+	TenantID  int32
+	UserID    int32
+	Name      string
+	TokenHash []byte
+	CreatedAt pgtype.Timestamp
+	RevokedAt pgtype.Timestamp
+}
+
+type AuditEvent struct {
+	ID         int64
+	TenantID   int32
+	OccurredAt pgtype.Timestamptz
+	ActorID    pgtype.Int4
+	Action     string
+	DocumentID pgtype.Int4
+	Ip         pgtype.Text
+	UserAgent  pgtype.Text
+	RequestID  pgtype.Text
+	BeforeHash []byte
+	AfterHash  []byte
+	PrevHash   []byte
+	Hash       []byte
+}
+
+type Comment struct {
+	ID         int32
+	TenantID   int32
+	DocumentID int32
+	ParentID   pgtype.Int4
+	AuthorID   int32
+	Body       string
+	Anchor     *models.Anchor
+	CreatedAt  pgtype.Timestamp
+	ResolvedAt pgtype.Timestamp
+	ResolvedBy pgtype.Int4
+}
+
+type CommentMention struct {
+	TenantID  int32
+	CommentID int32
+	UserID    int32
+}
+
 type Document struct {
 	ID        int32
 	Title     string
@@ -20,6 +65,55 @@ type Document struct {
 	Status    pgtype.Text
 	AuthorID  pgtype.Int4
 	FilePath  pgtype.Text
+	TenantID  int32
+	MatterID  pgtype.Int4
+	FolderID  pgtype.Int4
+}
+
+type DocumentLock struct {
+	TenantID   int32
+	DocumentID int32
+	UserID     int32
+	AcquiredAt pgtype.Timestamp
+	ExpiresAt  pgtype.Timestamp
+}
+
+type DocumentTag struct {
+	TenantID   int32
+	DocumentID int32
+	TagID      int32
+}
+
+type Folder struct {
+	ID        int32
+	TenantID  int32
+	MatterID  int32
+	ParentID  pgtype.Int4
+	Name      string
+	CreatedAt pgtype.Timestamp
+}
+
+type Matter struct {
+	ID          int32
+	TenantID    int32
+	Name        string
+	Reference   pgtype.Text
+	Description pgtype.Text
+	CreatedAt   pgtype.Timestamp
+	UpdatedAt   pgtype.Timestamp
+}
+
+type Tag struct {
+	ID        int32
+	TenantID  int32
+	Name      string
+	CreatedAt pgtype.Timestamp
+}
+
+type Tenant struct {
+	ID        int32
+	Name      string
+	CreatedAt pgtype.Timestamp
 }
 
 type User struct {
@@ -29,4 +123,34 @@ type User struct {
 	Password  string
 	CreatedAt pgtype.Timestamp
 	UpdatedAt pgtype.Timestamp
+	TenantID  int32
+}
+
+type Webhook struct {
+	ID                  int32
+	TenantID            int32
+	Url                 string
+	Events              []string
+	Secret              string
+	Enabled             bool
+	ConsecutiveFailures int32
+	DisabledAt          pgtype.Timestamp
+	CreatedAt           pgtype.Timestamp
+	UpdatedAt           pgtype.Timestamp
+}
+
+type WebhookDelivery struct {
+	ID             int32
+	TenantID       int32
+	WebhookID      int32
+	EventType      string
+	EventKey       string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamp
+	ResponseStatus pgtype.Int4
+	LastError      pgtype.Text
+	CreatedAt      pgtype.Timestamp
+	DeliveredAt    pgtype.Timestamp
 }
@@ -12,6 +12,296 @@ import (
 	"github.com/wilbyang/law-docs/internal/models"
 )
 
+const acquireDocumentLock = `-- name: AcquireDocumentLock :one
+INSERT INTO document_locks (document_id, user_id, acquired_at, expires_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (document_id, tenant_id) DO UPDATE
+SET user_id = excluded.user_id,
+    acquired_at = CASE WHEN document_locks.user_id = excluded.user_id AND document_locks.expires_at > excluded.acquired_at
+        THEN document_locks.acquired_at ELSE excluded.acquired_at END,
+    expires_at = excluded.expires_at
+WHERE document_locks.user_id = excluded.user_id OR document_locks.expires_at <= excluded.acquired_at
+RETURNING tenant_id, document_id, user_id, acquired_at, expires_at
+`
+
+type AcquireDocumentLockParams struct {
+	DocumentID int32
+	UserID     int32
+	AcquiredAt pgtype.Timestamp
+	ExpiresAt  pgtype.Timestamp
+}
+
+func (q *Queries) AcquireDocumentLock(ctx context.Context, arg AcquireDocumentLockParams) (DocumentLock, error) {
+	row := q.db.QueryRow(ctx, acquireDocumentLock,
+		arg.DocumentID,
+		arg.UserID,
+		arg.AcquiredAt,
+		arg.ExpiresAt,
+	)
+	var i DocumentLock
+	err := row.Scan(
+		&i.TenantID,
+		&i.DocumentID,
+		&i.UserID,
+		&i.AcquiredAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d SET next_attempt_at = $1
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT p.id FROM webhook_deliveries p JOIN webhooks pw ON pw.id = p.webhook_id
+    WHERE p.status = 'pending' AND p.next_attempt_at <= $2 AND pw.enabled
+    ORDER BY p.next_attempt_at, p.id
+    LIMIT $3
+    FOR UPDATE OF p SKIP LOCKED)
+RETURNING d.id, d.tenant_id, d.webhook_id, d.event_type, d.event_key, d.payload, d.status, d.attempts, d.next_attempt_at, d.response_status, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil pgtype.Timestamp
+	Now        pgtype.Timestamp
+	MaxRows    int32
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	WebhookDelivery WebhookDelivery
+	Url             string
+	Secret          string
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries,
+		arg.LeaseUntil,
+		arg.Now,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.WebhookDelivery.ID,
+			&i.WebhookDelivery.TenantID,
+			&i.WebhookDelivery.WebhookID,
+			&i.WebhookDelivery.EventType,
+			&i.WebhookDelivery.EventKey,
+			&i.WebhookDelivery.Payload,
+			&i.WebhookDelivery.Status,
+			&i.WebhookDelivery.Attempts,
+			&i.WebhookDelivery.NextAttemptAt,
+			&i.WebhookDelivery.ResponseStatus,
+			&i.WebhookDelivery.LastError,
+			&i.WebhookDelivery.CreatedAt,
+			&i.WebhookDelivery.DeliveredAt,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countDocumentsByStatus = `-- name: CountDocumentsByStatus :many
+SELECT status, count(*) FROM documents GROUP BY status
+`
+
+type CountDocumentsByStatusRow struct {
+	Status pgtype.Text
+	Count  int64
+}
+
+func (q *Queries) CountDocumentsByStatus(ctx context.Context) ([]CountDocumentsByStatusRow, error) {
+	rows, err := q.db.Query(ctx, countDocumentsByStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountDocumentsByStatusRow
+	for rows.Next() {
+		var i CountDocumentsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countDocumentsWithFile = `-- name: CountDocumentsWithFile :one
+SELECT count(*) FROM documents WHERE file_path = $1
+`
+
+func (q *Queries) CountDocumentsWithFile(ctx context.Context, filePath pgtype.Text) (int64, error) {
+	row := q.db.QueryRow(ctx, countDocumentsWithFile, filePath)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (tenant_id, user_id, name, token_hash) VALUES ($1, $2, $3, $4) RETURNING id, tenant_id, user_id, name, token_hash, created_at, revoked_at
+`
+
+type CreateAPITokenParams struct {
+	TenantID  int32
+	UserID    int32
+	Name      string
+	TokenHash []byte
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRow(ctx, createAPIToken,
+		arg.TenantID,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const createAuditEvent = `-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+    tenant_id,
+    occurred_at,
+    actor_id,
+    action,
+    document_id,
+    ip,
+    user_agent,
+    request_id,
+    before_hash,
+    after_hash,
+    prev_hash,
+    hash
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING id, tenant_id, occurred_at, actor_id, action, document_id, ip, user_agent, request_id, before_hash, after_hash, prev_hash, hash
+`
+
+type CreateAuditEventParams struct {
+	TenantID   int32
+	OccurredAt pgtype.Timestamptz
+	ActorID    pgtype.Int4
+	Action     string
+	DocumentID pgtype.Int4
+	Ip         pgtype.Text
+	UserAgent  pgtype.Text
+	RequestID  pgtype.Text
+	BeforeHash []byte
+	AfterHash  []byte
+	PrevHash   []byte
+	Hash       []byte
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.db.QueryRow(ctx, createAuditEvent,
+		arg.TenantID,
+		arg.OccurredAt,
+		arg.ActorID,
+		arg.Action,
+		arg.DocumentID,
+		arg.Ip,
+		arg.UserAgent,
+		arg.RequestID,
+		arg.BeforeHash,
+		arg.AfterHash,
+		arg.PrevHash,
+		arg.Hash,
+	)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OccurredAt,
+		&i.ActorID,
+		&i.Action,
+		&i.DocumentID,
+		&i.Ip,
+		&i.UserAgent,
+		&i.RequestID,
+		&i.BeforeHash,
+		&i.AfterHash,
+		&i.PrevHash,
+		&i.Hash,
+	)
+	return i, err
+}
+
+const createComment = `-- name: CreateComment :one
+INSERT INTO comments (document_id, parent_id, author_id, body, anchor) VALUES ($1, $2, $3, $4, $5) RETURNING id, tenant_id, document_id, parent_id, author_id, body, anchor, created_at, resolved_at, resolved_by
+`
+
+type CreateCommentParams struct {
+	DocumentID int32
+	ParentID   pgtype.Int4
+	AuthorID   int32
+	Body       string
+	Anchor     *models.Anchor
+}
+
+func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (Comment, error) {
+	row := q.db.QueryRow(ctx, createComment,
+		arg.DocumentID,
+		arg.ParentID,
+		arg.AuthorID,
+		arg.Body,
+		arg.Anchor,
+	)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.DocumentID,
+		&i.ParentID,
+		&i.AuthorID,
+		&i.Body,
+		&i.Anchor,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const createCommentMentions = `-- name: CreateCommentMentions :exec
+INSERT INTO comment_mentions (comment_id, user_id)
+SELECT $1, unnest($2::int[])
+`
+
+type CreateCommentMentionsParams struct {
+	CommentID int32
+	UserIds   []int32
+}
+
+func (q *Queries) CreateCommentMentions(ctx context.Context, arg CreateCommentMentionsParams) error {
+	_, err := q.db.Exec(ctx, createCommentMentions, arg.CommentID, arg.UserIds)
+	return err
+}
+
 const createDocument = `-- name: CreateDocument :one
 INSERT INTO documents (
     title,
@@ -22,7 +312,9 @@ INSERT INTO documents (
     meta,
     status,
     author_id,
-    file_path
+    file_path,
+    matter_id,
+    folder_id
 ) VALUES (
     $1,
     $2,
@@ -32,78 +324,1456 @@ INSERT INTO documents (
     $6,
     $7,
     $8,
-    $9
-) RETURNING id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path
+    $9,
+    $10,
+    $11
+) RETURNING id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id
+`
+
+type CreateDocumentParams struct {
+	Title     string
+	Content   string
+	DocSize   int32
+	CreatedAt pgtype.Timestamp
+	UpdatedAt pgtype.Timestamp
+	Meta      models.Meta
+	Status    pgtype.Text
+	AuthorID  pgtype.Int4
+	FilePath  pgtype.Text
+	MatterID  pgtype.Int4
+	FolderID  pgtype.Int4
+}
+
+func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams) (Document, error) {
+	row := q.db.QueryRow(ctx, createDocument,
+		arg.Title,
+		arg.Content,
+		arg.DocSize,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Meta,
+		arg.Status,
+		arg.AuthorID,
+		arg.FilePath,
+		arg.MatterID,
+		arg.FolderID,
+	)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.DocSize,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Meta,
+		&i.Status,
+		&i.AuthorID,
+		&i.FilePath,
+		&i.TenantID,
+		&i.MatterID,
+		&i.FolderID,
+	)
+	return i, err
+}
+
+const createFolder = `-- name: CreateFolder :one
+INSERT INTO folders (matter_id, parent_id, name) VALUES ($1, $2, $3) RETURNING id, tenant_id, matter_id, parent_id, name, created_at
+`
+
+type CreateFolderParams struct {
+	MatterID int32
+	ParentID pgtype.Int4
+	Name     string
+}
+
+func (q *Queries) CreateFolder(ctx context.Context, arg CreateFolderParams) (Folder, error) {
+	row := q.db.QueryRow(ctx, createFolder,
+		arg.MatterID,
+		arg.ParentID,
+		arg.Name,
+	)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.MatterID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createMatter = `-- name: CreateMatter :one
+INSERT INTO matters (name, reference, description) VALUES ($1, $2, $3) RETURNING id, tenant_id, name, reference, description, created_at, updated_at
+`
+
+type CreateMatterParams struct {
+	Name        string
+	Reference   pgtype.Text
+	Description pgtype.Text
+}
+
+func (q *Queries) CreateMatter(ctx context.Context, arg CreateMatterParams) (Matter, error) {
+	row := q.db.QueryRow(ctx, createMatter,
+		arg.Name,
+		arg.Reference,
+		arg.Description,
+	)
+	var i Matter
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Reference,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createTag = `-- name: CreateTag :one
+INSERT INTO tags (name) VALUES ($1) RETURNING id, tenant_id, name, created_at
+`
+
+func (q *Queries) CreateTag(ctx context.Context, name string) (Tag, error) {
+	row := q.db.QueryRow(ctx, createTag, name)
+	var i Tag
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createTenant = `-- name: CreateTenant :one
+INSERT INTO tenants (name) VALUES ($1) RETURNING id, name, created_at
+`
+
+func (q *Queries) CreateTenant(ctx context.Context, name string) (Tenant, error) {
+	row := q.db.QueryRow(ctx, createTenant, name)
+	var i Tenant
+	err := row.Scan(&i.ID, &i.Name, &i.CreatedAt)
+	return i, err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id, name, email, password, created_at, updated_at, tenant_id
+`
+
+type CreateUserParams struct {
+	Name     string
+	Email    string
+	Password string
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createUser, arg.Name, arg.Email, arg.Password)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (url, events, secret) VALUES ($1, $2, $3) RETURNING id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at, created_at, updated_at
+`
+
+type CreateWebhookParams struct {
+	Url    string
+	Events []string
+	Secret string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, createWebhook,
+		arg.Url,
+		arg.Events,
+		arg.Secret,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteDocument = `-- name: DeleteDocument :one
+DELETE FROM documents WHERE id = $1 RETURNING id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id
+`
+
+func (q *Queries) DeleteDocument(ctx context.Context, id int32) (Document, error) {
+	row := q.db.QueryRow(ctx, deleteDocument, id)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.DocSize,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Meta,
+		&i.Status,
+		&i.AuthorID,
+		&i.FilePath,
+		&i.TenantID,
+		&i.MatterID,
+		&i.FolderID,
+	)
+	return i, err
+}
+
+const deleteFolder = `-- name: DeleteFolder :one
+DELETE FROM folders WHERE id = $1 RETURNING id, tenant_id, matter_id, parent_id, name, created_at
+`
+
+func (q *Queries) DeleteFolder(ctx context.Context, id int32) (Folder, error) {
+	row := q.db.QueryRow(ctx, deleteFolder, id)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.MatterID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteMatter = `-- name: DeleteMatter :one
+DELETE FROM matters WHERE id = $1 RETURNING id, tenant_id, name, reference, description, created_at, updated_at
+`
+
+func (q *Queries) DeleteMatter(ctx context.Context, id int32) (Matter, error) {
+	row := q.db.QueryRow(ctx, deleteMatter, id)
+	var i Matter
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Reference,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteTag = `-- name: DeleteTag :one
+DELETE FROM tags WHERE id = $1 RETURNING id, tenant_id, name, created_at
+`
+
+func (q *Queries) DeleteTag(ctx context.Context, id int32) (Tag, error) {
+	row := q.db.QueryRow(ctx, deleteTag, id)
+	var i Tag
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :one
+DELETE FROM webhooks WHERE id = $1 RETURNING id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at, created_at, updated_at
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id int32) (Webhook, error) {
+	row := q.db.QueryRow(ctx, deleteWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, event_type, event_key, payload, next_attempt_at)
+SELECT id, $1, $2, $3, $4 FROM webhooks
+WHERE enabled AND events && $5::text[]
+ON CONFLICT (webhook_id, event_key) DO NOTHING
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	EventType     string
+	EventKey      string
+	Payload       []byte
+	NextAttemptAt pgtype.Timestamp
+	Events        []string
+}
+
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueWebhookDeliveries,
+		arg.EventType,
+		arg.EventKey,
+		arg.Payload,
+		arg.NextAttemptAt,
+		arg.Events,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getAPITokenByHash = `-- name: GetAPITokenByHash :one
+SELECT id, tenant_id, user_id, name, token_hash, created_at, revoked_at FROM api_tokens WHERE token_hash = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) GetAPITokenByHash(ctx context.Context, tokenHash []byte) (ApiToken, error) {
+	row := q.db.QueryRow(ctx, getAPITokenByHash, tokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getComment = `-- name: GetComment :one
+SELECT id, tenant_id, document_id, parent_id, author_id, body, anchor, created_at, resolved_at, resolved_by FROM comments WHERE id = $1
+`
+
+func (q *Queries) GetComment(ctx context.Context, id int32) (Comment, error) {
+	row := q.db.QueryRow(ctx, getComment, id)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.DocumentID,
+		&i.ParentID,
+		&i.AuthorID,
+		&i.Body,
+		&i.Anchor,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const getDocumentById = `-- name: GetDocumentById :one
+SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id FROM documents WHERE id = $1
+`
+
+func (q *Queries) GetDocumentById(ctx context.Context, id int32) (Document, error) {
+	row := q.db.QueryRow(ctx, getDocumentById, id)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.DocSize,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Meta,
+		&i.Status,
+		&i.AuthorID,
+		&i.FilePath,
+		&i.TenantID,
+		&i.MatterID,
+		&i.FolderID,
+	)
+	return i, err
+}
+
+const getDocumentByIdForUpdate = `-- name: GetDocumentByIdForUpdate :one
+SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id FROM documents WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetDocumentByIdForUpdate(ctx context.Context, id int32) (Document, error) {
+	row := q.db.QueryRow(ctx, getDocumentByIdForUpdate, id)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.DocSize,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Meta,
+		&i.Status,
+		&i.AuthorID,
+		&i.FilePath,
+		&i.TenantID,
+		&i.MatterID,
+		&i.FolderID,
+	)
+	return i, err
+}
+
+const getDocumentLock = `-- name: GetDocumentLock :one
+SELECT tenant_id, document_id, user_id, acquired_at, expires_at FROM document_locks WHERE document_id = $1
+`
+
+func (q *Queries) GetDocumentLock(ctx context.Context, documentID int32) (DocumentLock, error) {
+	row := q.db.QueryRow(ctx, getDocumentLock, documentID)
+	var i DocumentLock
+	err := row.Scan(
+		&i.TenantID,
+		&i.DocumentID,
+		&i.UserID,
+		&i.AcquiredAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getDocuments = `-- name: GetDocuments :many
+SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id FROM documents WHERE author_id = $1
+`
+
+func (q *Queries) GetDocuments(ctx context.Context, authorID pgtype.Int4) ([]Document, error) {
+	rows, err := q.db.Query(ctx, getDocuments, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Content,
+			&i.DocSize,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Meta,
+			&i.Status,
+			&i.AuthorID,
+			&i.FilePath,
+			&i.TenantID,
+			&i.MatterID,
+			&i.FolderID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFolder = `-- name: GetFolder :one
+SELECT id, tenant_id, matter_id, parent_id, name, created_at FROM folders WHERE id = $1
+`
+
+func (q *Queries) GetFolder(ctx context.Context, id int32) (Folder, error) {
+	row := q.db.QueryRow(ctx, getFolder, id)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.MatterID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLastAuditEvent = `-- name: GetLastAuditEvent :one
+SELECT id, tenant_id, occurred_at, actor_id, action, document_id, ip, user_agent, request_id, before_hash, after_hash, prev_hash, hash FROM audit_events WHERE tenant_id = $1 ORDER BY id DESC LIMIT 1
+`
+
+func (q *Queries) GetLastAuditEvent(ctx context.Context, tenantID int32) (AuditEvent, error) {
+	row := q.db.QueryRow(ctx, getLastAuditEvent, tenantID)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OccurredAt,
+		&i.ActorID,
+		&i.Action,
+		&i.DocumentID,
+		&i.Ip,
+		&i.UserAgent,
+		&i.RequestID,
+		&i.BeforeHash,
+		&i.AfterHash,
+		&i.PrevHash,
+		&i.Hash,
+	)
+	return i, err
+}
+
+const getMatter = `-- name: GetMatter :one
+SELECT id, tenant_id, name, reference, description, created_at, updated_at FROM matters WHERE id = $1
+`
+
+func (q *Queries) GetMatter(ctx context.Context, id int32) (Matter, error) {
+	row := q.db.QueryRow(ctx, getMatter, id)
+	var i Matter
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Reference,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserById = `-- name: GetUserById :one
+SELECT id, name, email, password, created_at, updated_at, tenant_id FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at, created_at, updated_at FROM webhooks WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int32) (Webhook, error) {
+	row := q.db.QueryRow(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const isFolderAncestor = `-- name: IsFolderAncestor :one
+WITH RECURSIVE ancestors AS (
+    SELECT id, parent_id FROM folders WHERE id = $1
+    UNION ALL
+    SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+)
+SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+`
+
+type IsFolderAncestorParams struct {
+	FolderID   int32
+	AncestorID int32
+}
+
+func (q *Queries) IsFolderAncestor(ctx context.Context, arg IsFolderAncestorParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isFolderAncestor,
+		arg.FolderID,
+		arg.AncestorID,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, tenant_id, occurred_at, actor_id, action, document_id, ip, user_agent, request_id, before_hash, after_hash, prev_hash, hash FROM audit_events
+WHERE tenant_id = $1
+  AND id > $2
+  AND ($3::int IS NULL OR document_id = $3)
+  AND ($4::int IS NULL OR actor_id = $4)
+  AND ($5::text IS NULL OR action = $5)
+  AND ($6::timestamptz IS NULL OR occurred_at >= $6)
+  AND ($7::timestamptz IS NULL OR occurred_at < $7)
+ORDER BY id
+LIMIT $8
+`
+
+type ListAuditEventsParams struct {
+	TenantID   int32
+	AfterID    int64
+	DocumentID pgtype.Int4
+	ActorID    pgtype.Int4
+	Action     pgtype.Text
+	Since      pgtype.Timestamptz
+	Until      pgtype.Timestamptz
+	MaxRows    int32
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents,
+		arg.TenantID,
+		arg.AfterID,
+		arg.DocumentID,
+		arg.ActorID,
+		arg.Action,
+		arg.Since,
+		arg.Until,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.OccurredAt,
+			&i.ActorID,
+			&i.Action,
+			&i.DocumentID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.BeforeHash,
+			&i.AfterHash,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCommentMentions = `-- name: ListCommentMentions :many
+SELECT tenant_id, comment_id, user_id FROM comment_mentions WHERE comment_id = ANY($1::int[]) ORDER BY comment_id, user_id
+`
+
+func (q *Queries) ListCommentMentions(ctx context.Context, commentIds []int32) ([]CommentMention, error) {
+	rows, err := q.db.Query(ctx, listCommentMentions, commentIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CommentMention
+	for rows.Next() {
+		var i CommentMention
+		if err := rows.Scan(
+			&i.TenantID,
+			&i.CommentID,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listComments = `-- name: ListComments :many
+SELECT id, tenant_id, document_id, parent_id, author_id, body, anchor, created_at, resolved_at, resolved_by FROM comments WHERE document_id = $1 ORDER BY id
+`
+
+func (q *Queries) ListComments(ctx context.Context, documentID int32) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, listComments, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.DocumentID,
+			&i.ParentID,
+			&i.AuthorID,
+			&i.Body,
+			&i.Anchor,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+			&i.ResolvedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDocumentChanges = `-- name: ListDocumentChanges :many
+SELECT id, tenant_id, title, status, created_at, updated_at FROM documents
+WHERE (updated_at, id) > ($1::timestamp, $2::int)
+ORDER BY updated_at, id
+LIMIT $3
+`
+
+type ListDocumentChangesParams struct {
+	UpdatedAt pgtype.Timestamp
+	ID        int32
+	Limit     int32
+}
+
+type ListDocumentChangesRow struct {
+	ID        int32
+	TenantID  int32
+	Title     string
+	Status    pgtype.Text
+	CreatedAt pgtype.Timestamp
+	UpdatedAt pgtype.Timestamp
+}
+
+func (q *Queries) ListDocumentChanges(ctx context.Context, arg ListDocumentChangesParams) ([]ListDocumentChangesRow, error) {
+	rows, err := q.db.Query(ctx, listDocumentChanges, arg.UpdatedAt, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDocumentChangesRow
+	for rows.Next() {
+		var i ListDocumentChangesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Title,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDocumentTags = `-- name: ListDocumentTags :many
+SELECT dt.document_id, t.id, t.name FROM document_tags dt JOIN tags t ON t.id = dt.tag_id
+WHERE dt.document_id = ANY($1::int[])
+ORDER BY dt.document_id, t.name
+`
+
+type ListDocumentTagsRow struct {
+	DocumentID int32
+	ID         int32
+	Name       string
+}
+
+func (q *Queries) ListDocumentTags(ctx context.Context, documentIds []int32) ([]ListDocumentTagsRow, error) {
+	rows, err := q.db.Query(ctx, listDocumentTags, documentIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDocumentTagsRow
+	for rows.Next() {
+		var i ListDocumentTagsRow
+		if err := rows.Scan(&i.DocumentID, &i.ID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDocuments = `-- name: ListDocuments :many
+SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id FROM documents ORDER BY id
+`
+
+func (q *Queries) ListDocuments(ctx context.Context) ([]Document, error) {
+	rows, err := q.db.Query(ctx, listDocuments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Content,
+			&i.DocSize,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Meta,
+			&i.Status,
+			&i.AuthorID,
+			&i.FilePath,
+			&i.TenantID,
+			&i.MatterID,
+			&i.FolderID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDocumentsByID = `-- name: ListDocumentsByID :many
+SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id FROM documents WHERE id = ANY($1::int[]) ORDER BY id
+`
+
+func (q *Queries) ListDocumentsByID(ctx context.Context, ids []int32) ([]Document, error) {
+	rows, err := q.db.Query(ctx, listDocumentsByID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Content,
+			&i.DocSize,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Meta,
+			&i.Status,
+			&i.AuthorID,
+			&i.FilePath,
+			&i.TenantID,
+			&i.MatterID,
+			&i.FolderID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFolders = `-- name: ListFolders :many
+SELECT id, tenant_id, matter_id, parent_id, name, created_at FROM folders WHERE matter_id = $1 ORDER BY parent_id NULLS FIRST, name
+`
+
+func (q *Queries) ListFolders(ctx context.Context, matterID int32) ([]Folder, error) {
+	rows, err := q.db.Query(ctx, listFolders, matterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Folder
+	for rows.Next() {
+		var i Folder
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.MatterID,
+			&i.ParentID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMatters = `-- name: ListMatters :many
+SELECT id, tenant_id, name, reference, description, created_at, updated_at FROM matters ORDER BY name
+`
+
+func (q *Queries) ListMatters(ctx context.Context) ([]Matter, error) {
+	rows, err := q.db.Query(ctx, listMatters)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Matter
+	for rows.Next() {
+		var i Matter
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.Reference,
+			&i.Description,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMentionedComments = `-- name: ListMentionedComments :many
+SELECT c.id, c.tenant_id, c.document_id, c.parent_id, c.author_id, c.body, c.anchor, c.created_at, c.resolved_at, c.resolved_by FROM comments c JOIN comment_mentions m ON m.comment_id = c.id
+WHERE m.user_id = $1
+ORDER BY c.id DESC
+LIMIT $2
+`
+
+type ListMentionedCommentsParams struct {
+	UserID  int32
+	MaxRows int32
+}
+
+func (q *Queries) ListMentionedComments(ctx context.Context, arg ListMentionedCommentsParams) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, listMentionedComments,
+		arg.UserID,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.DocumentID,
+			&i.ParentID,
+			&i.AuthorID,
+			&i.Body,
+			&i.Anchor,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+			&i.ResolvedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStaleDocuments = `-- name: ListStaleDocuments :many
+SELECT id, tenant_id, title, updated_at FROM documents
+WHERE status = $1 AND updated_at < $2
+ORDER BY updated_at
+LIMIT $3
+`
+
+type ListStaleDocumentsParams struct {
+	Status    pgtype.Text
+	UpdatedAt pgtype.Timestamp
+	Limit     int32
+}
+
+type ListStaleDocumentsRow struct {
+	ID        int32
+	TenantID  int32
+	Title     string
+	UpdatedAt pgtype.Timestamp
+}
+
+func (q *Queries) ListStaleDocuments(ctx context.Context, arg ListStaleDocumentsParams) ([]ListStaleDocumentsRow, error) {
+	rows, err := q.db.Query(ctx, listStaleDocuments, arg.Status, arg.UpdatedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStaleDocumentsRow
+	for rows.Next() {
+		var i ListStaleDocumentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Title,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTags = `-- name: ListTags :many
+SELECT id, tenant_id, name, created_at FROM tags ORDER BY name
+`
+
+func (q *Queries) ListTags(ctx context.Context) ([]Tag, error) {
+	rows, err := q.db.Query(ctx, listTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tag
+	for rows.Next() {
+		var i Tag
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagsByID = `-- name: ListTagsByID :many
+SELECT id, tenant_id, name, created_at FROM tags WHERE id = ANY($1::int[]) ORDER BY id
+`
+
+func (q *Queries) ListTagsByID(ctx context.Context, ids []int32) ([]Tag, error) {
+	rows, err := q.db.Query(ctx, listTagsByID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tag
+	for rows.Next() {
+		var i Tag
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserIDs = `-- name: ListUserIDs :many
+SELECT id FROM users WHERE id = ANY($1::int[]) ORDER BY id
+`
+
+func (q *Queries) ListUserIDs(ctx context.Context, ids []int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listUserIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, tenant_id, webhook_id, event_type, event_key, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at FROM webhook_deliveries WHERE webhook_id = $1 AND ($2::int IS NULL OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID int32
+	Before    pgtype.Int4
+	MaxRows   int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries,
+		arg.WebhookID,
+		arg.Before,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.WebhookID,
+			&i.EventType,
+			&i.EventKey,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at, created_at, updated_at FROM webhooks ORDER BY id
 `
 
-type CreateDocumentParams struct {
-	Title     string
-	Content   string
-	DocSize   int32
-	CreatedAt pgtype.Timestamp
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Url,
+			&i.Events,
+			&i.Secret,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAuditChain = `-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(1635083369, $1::int)
+`
+
+func (q *Queries) LockAuditChain(ctx context.Context, tenantID int32) error {
+	_, err := q.db.Exec(ctx, lockAuditChain, tenantID)
+	return err
+}
+
+const moveDocuments = `-- name: MoveDocuments :many
+UPDATE documents SET matter_id = $1, folder_id = $2, updated_at = $3
+WHERE id = ANY($4::int[])
+RETURNING id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id
+`
+
+type MoveDocumentsParams struct {
+	MatterID  pgtype.Int4
+	FolderID  pgtype.Int4
 	UpdatedAt pgtype.Timestamp
-	Meta      models.Meta
-	Status    pgtype.Text
-	AuthorID  pgtype.Int4
-	FilePath  pgtype.Text
+	Ids       []int32
 }
 
-func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams) (Document, error) {
-	row := q.db.QueryRow(ctx, createDocument,
-		arg.Title,
-		arg.Content,
-		arg.DocSize,
-		arg.CreatedAt,
+func (q *Queries) MoveDocuments(ctx context.Context, arg MoveDocumentsParams) ([]Document, error) {
+	rows, err := q.db.Query(ctx, moveDocuments,
+		arg.MatterID,
+		arg.FolderID,
 		arg.UpdatedAt,
-		arg.Meta,
+		arg.Ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Content,
+			&i.DocSize,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Meta,
+			&i.Status,
+			&i.AuthorID,
+			&i.FilePath,
+			&i.TenantID,
+			&i.MatterID,
+			&i.FolderID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :one
+UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, response_status = $3, last_error = $4,
+    next_attempt_at = $5, delivered_at = $6
+WHERE id = $1 RETURNING id, tenant_id, webhook_id, event_type, event_key, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at
+`
+
+type RecordWebhookAttemptParams struct {
+	ID             int32
+	Status         string
+	ResponseStatus pgtype.Int4
+	LastError      pgtype.Text
+	NextAttemptAt  pgtype.Timestamp
+	DeliveredAt    pgtype.Timestamp
+}
+
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, recordWebhookAttempt,
+		arg.ID,
 		arg.Status,
-		arg.AuthorID,
-		arg.FilePath,
+		arg.ResponseStatus,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.DeliveredAt,
 	)
-	var i Document
+	var i WebhookDelivery
 	err := row.Scan(
 		&i.ID,
-		&i.Title,
-		&i.Content,
-		&i.DocSize,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Meta,
+		&i.TenantID,
+		&i.WebhookID,
+		&i.EventType,
+		&i.EventKey,
+		&i.Payload,
 		&i.Status,
-		&i.AuthorID,
-		&i.FilePath,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.DeliveredAt,
 	)
 	return i, err
 }
 
-const getDocumentById = `-- name: GetDocumentById :one
-SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path FROM documents WHERE id = $1
+const recordWebhookFailure = `-- name: RecordWebhookFailure :one
+UPDATE webhooks SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND consecutive_failures + 1 < $1,
+    disabled_at = CASE WHEN enabled AND consecutive_failures + 1 >= $1 THEN $2 ELSE disabled_at END
+WHERE id = $3 RETURNING id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at, created_at, updated_at
 `
 
-func (q *Queries) GetDocumentById(ctx context.Context, id int32) (Document, error) {
-	row := q.db.QueryRow(ctx, getDocumentById, id)
-	var i Document
+type RecordWebhookFailureParams struct {
+	DisableAfter int32
+	Now          pgtype.Timestamp
+	ID           int32
+}
+
+func (q *Queries) RecordWebhookFailure(ctx context.Context, arg RecordWebhookFailureParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, recordWebhookFailure,
+		arg.DisableAfter,
+		arg.Now,
+		arg.ID,
+	)
+	var i Webhook
 	err := row.Scan(
 		&i.ID,
-		&i.Title,
-		&i.Content,
-		&i.DocSize,
+		&i.TenantID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.Meta,
+	)
+	return i, err
+}
+
+const redeliverWebhookDelivery = `-- name: RedeliverWebhookDelivery :one
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $3, delivered_at = NULL
+WHERE id = $1 AND webhook_id = $2 RETURNING id, tenant_id, webhook_id, event_type, event_key, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at
+`
+
+type RedeliverWebhookDeliveryParams struct {
+	ID            int32
+	WebhookID     int32
+	NextAttemptAt pgtype.Timestamp
+}
+
+func (q *Queries) RedeliverWebhookDelivery(ctx context.Context, arg RedeliverWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, redeliverWebhookDelivery,
+		arg.ID,
+		arg.WebhookID,
+		arg.NextAttemptAt,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.WebhookID,
+		&i.EventType,
+		&i.EventKey,
+		&i.Payload,
 		&i.Status,
-		&i.AuthorID,
-		&i.FilePath,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.DeliveredAt,
 	)
 	return i, err
 }
 
-const getDocuments = `-- name: GetDocuments :many
-SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path FROM documents WHERE author_id = $1
+const releaseDocumentLock = `-- name: ReleaseDocumentLock :execrows
+DELETE FROM document_locks WHERE document_id = $1 AND user_id = $2
 `
 
-func (q *Queries) GetDocuments(ctx context.Context, authorID pgtype.Int4) ([]Document, error) {
-	rows, err := q.db.Query(ctx, getDocuments, authorID)
+type ReleaseDocumentLockParams struct {
+	DocumentID int32
+	UserID     int32
+}
+
+func (q *Queries) ReleaseDocumentLock(ctx context.Context, arg ReleaseDocumentLockParams) (int64, error) {
+	result, err := q.db.Exec(ctx, releaseDocumentLock,
+		arg.DocumentID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const resetWebhookFailures = `-- name: ResetWebhookFailures :execrows
+UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0
+`
+
+func (q *Queries) ResetWebhookFailures(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, resetWebhookFailures, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeAPIToken = `-- name: RevokeAPIToken :exec
+UPDATE api_tokens SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAPIToken(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, revokeAPIToken, id)
+	return err
+}
+
+const searchDocuments = `-- name: SearchDocuments :many
+SELECT id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id FROM documents
+WHERE ($1::int IS NULL OR author_id = $1)
+  AND ($2::int IS NULL OR matter_id = $2)
+  AND ($3::int IS NULL OR folder_id = $3)
+  AND ($4::text IS NULL OR title ILIKE '%' || $4 || '%')
+  AND (cardinality($5::text[]) = 0 OR id IN (
+      SELECT dt.document_id FROM document_tags dt JOIN tags t ON t.id = dt.tag_id
+      WHERE t.name = ANY($5::text[])
+      GROUP BY dt.document_id
+      HAVING count(*) = cardinality($5::text[])))
+ORDER BY id
+`
+
+type SearchDocumentsParams struct {
+	AuthorID pgtype.Int4
+	MatterID pgtype.Int4
+	FolderID pgtype.Int4
+	Title    pgtype.Text
+	Tags     []string
+}
+
+func (q *Queries) SearchDocuments(ctx context.Context, arg SearchDocumentsParams) ([]Document, error) {
+	rows, err := q.db.Query(ctx, searchDocuments,
+		arg.AuthorID,
+		arg.MatterID,
+		arg.FolderID,
+		arg.Title,
+		arg.Tags,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -122,6 +1792,9 @@ func (q *Queries) GetDocuments(ctx context.Context, authorID pgtype.Int4) ([]Doc
 			&i.Status,
 			&i.AuthorID,
 			&i.FilePath,
+			&i.TenantID,
+			&i.MatterID,
+			&i.FolderID,
 		); err != nil {
 			return nil, err
 		}
@@ -133,8 +1806,83 @@ func (q *Queries) GetDocuments(ctx context.Context, authorID pgtype.Int4) ([]Doc
 	return items, nil
 }
 
+const setCommentResolved = `-- name: SetCommentResolved :one
+UPDATE comments SET resolved_at = $2, resolved_by = $3 WHERE id = $1 AND parent_id IS NULL RETURNING id, tenant_id, document_id, parent_id, author_id, body, anchor, created_at, resolved_at, resolved_by
+`
+
+type SetCommentResolvedParams struct {
+	ID         int32
+	ResolvedAt pgtype.Timestamp
+	ResolvedBy pgtype.Int4
+}
+
+func (q *Queries) SetCommentResolved(ctx context.Context, arg SetCommentResolvedParams) (Comment, error) {
+	row := q.db.QueryRow(ctx, setCommentResolved,
+		arg.ID,
+		arg.ResolvedAt,
+		arg.ResolvedBy,
+	)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.DocumentID,
+		&i.ParentID,
+		&i.AuthorID,
+		&i.Body,
+		&i.Anchor,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const tagDocuments = `-- name: TagDocuments :execrows
+INSERT INTO document_tags (document_id, tag_id)
+SELECT d, t FROM unnest($1::int[]) AS d, unnest($2::int[]) AS t
+ON CONFLICT DO NOTHING
+`
+
+type TagDocumentsParams struct {
+	DocumentIds []int32
+	TagIds      []int32
+}
+
+func (q *Queries) TagDocuments(ctx context.Context, arg TagDocumentsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, tagDocuments,
+		arg.DocumentIds,
+		arg.TagIds,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const untagDocuments = `-- name: UntagDocuments :execrows
+DELETE FROM document_tags
+WHERE document_id = ANY($1::int[]) AND tag_id = ANY($2::int[])
+`
+
+type UntagDocumentsParams struct {
+	DocumentIds []int32
+	TagIds      []int32
+}
+
+func (q *Queries) UntagDocuments(ctx context.Context, arg UntagDocumentsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, untagDocuments,
+		arg.DocumentIds,
+		arg.TagIds,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateDocument = `-- name: UpdateDocument :one
-UPDATE documents SET title = $2, content = $3, doc_size = $4, updated_at = $5, meta = $6, status = $7, file_path = $8 WHERE id = $1 RETURNING id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path
+UPDATE documents SET title = $2, content = $3, doc_size = $4, updated_at = $5, meta = $6, status = $7, file_path = $8 WHERE id = $1 RETURNING id, title, content, doc_size, created_at, updated_at, meta, status, author_id, file_path, tenant_id, matter_id, folder_id
 `
 
 type UpdateDocumentParams struct {
@@ -171,6 +1919,135 @@ func (q *Queries) UpdateDocument(ctx context.Context, arg UpdateDocumentParams)
 		&i.Status,
 		&i.AuthorID,
 		&i.FilePath,
+		&i.TenantID,
+		&i.MatterID,
+		&i.FolderID,
+	)
+	return i, err
+}
+
+const updateFolder = `-- name: UpdateFolder :one
+UPDATE folders SET parent_id = $2, name = $3 WHERE id = $1 RETURNING id, tenant_id, matter_id, parent_id, name, created_at
+`
+
+type UpdateFolderParams struct {
+	ID       int32
+	ParentID pgtype.Int4
+	Name     string
+}
+
+func (q *Queries) UpdateFolder(ctx context.Context, arg UpdateFolderParams) (Folder, error) {
+	row := q.db.QueryRow(ctx, updateFolder,
+		arg.ID,
+		arg.ParentID,
+		arg.Name,
+	)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.MatterID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateMatter = `-- name: UpdateMatter :one
+UPDATE matters SET name = $2, reference = $3, description = $4, updated_at = $5 WHERE id = $1 RETURNING id, tenant_id, name, reference, description, created_at, updated_at
+`
+
+type UpdateMatterParams struct {
+	ID          int32
+	Name        string
+	Reference   pgtype.Text
+	Description pgtype.Text
+	UpdatedAt   pgtype.Timestamp
+}
+
+func (q *Queries) UpdateMatter(ctx context.Context, arg UpdateMatterParams) (Matter, error) {
+	row := q.db.QueryRow(ctx, updateMatter,
+		arg.ID,
+		arg.Name,
+		arg.Reference,
+		arg.Description,
+		arg.UpdatedAt,
+	)
+	var i Matter
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Reference,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateTag = `-- name: UpdateTag :one
+UPDATE tags SET name = $2 WHERE id = $1 RETURNING id, tenant_id, name, created_at
+`
+
+type UpdateTagParams struct {
+	ID   int32
+	Name string
+}
+
+func (q *Queries) UpdateTag(ctx context.Context, arg UpdateTagParams) (Tag, error) {
+	row := q.db.QueryRow(ctx, updateTag,
+		arg.ID,
+		arg.Name,
+	)
+	var i Tag
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks SET url = $2, events = $3, secret = $4, enabled = $5, updated_at = $6,
+    disabled_at = CASE WHEN $5 THEN NULL ELSE coalesce(disabled_at, $6) END,
+    consecutive_failures = CASE WHEN $5 AND NOT enabled THEN 0 ELSE consecutive_failures END
+WHERE id = $1 RETURNING id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at, created_at, updated_at
+`
+
+type UpdateWebhookParams struct {
+	ID        int32
+	Url       string
+	Events    []string
+	Secret    string
+	Enabled   bool
+	UpdatedAt pgtype.Timestamp
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, updateWebhook,
+		arg.ID,
+		arg.Url,
+		arg.Events,
+		arg.Secret,
+		arg.Enabled,
+		arg.UpdatedAt,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,105 @@
+// Package events turns document changes announced by Postgres NOTIFY into typed events, which
+// Publisher sends to an sse.Broker under a topic per tenant and event type. A Feed receives the
+// notifications from a pgnotify.Listener.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Channel is the NOTIFY channel written by the notify_document_change trigger.
+const Channel = "document_changes"
+
+// Document event types.
+const (
+	DocumentCreated       = "document.created"
+	DocumentUpdated       = "document.updated"
+	DocumentStatusChanged = "document.status_changed"
+	// DocumentDeleted is only published by lawdocs cdc; the trigger does not fire on deletes.
+	DocumentDeleted = "document.deleted"
+)
+
+// Topic is the broker topic of a tenant's events of eventType. Topic(tenantID, "*") matches all
+// of the tenant's events.
+func Topic(tenantID int32, eventType string) string {
+	return fmt.Sprintf("tenants/%d/%s", tenantID, eventType)
+}
+
+// DocumentEvent describes one change of a document. Content is never included.
+type DocumentEvent struct {
+	Type           string    `json:"type" validate:"required" enums:"document.created,document.updated,document.status_changed"`
+	DocumentID     int32     `json:"document_id" validate:"required" example:"42"`
+	Title          string    `json:"title" validate:"required" example:"Tenancy agreement"`
+	Status         string    `json:"status" validate:"required" example:"pre-processed"`
+	PreviousStatus string    `json:"previous_status,omitempty" example:"draft"`
+	UpdatedAt      time.Time `json:"updated_at" validate:"required" format:"date-time"`
+	// Changed names the columns changed by an update.
+	Changed []string `json:"changed,omitempty" example:"status,updated_at"`
+	// Replayed is set on events recovered after the listener lost its database connection.
+	// They describe the current state of a document that changed meanwhile, so they are
+	// document.created or document.updated even if the status changed, and Changed is unknown.
+	Replayed bool  `json:"replayed,omitempty"`
+	TenantID int32 `json:"-"`
+}
+
+// notification is the payload of the notify_document_change trigger.
+type notification struct {
+	Operation string    `json:"operation"`
+	Table     string    `json:"table"`
+	ID        int32     `json:"id"`
+	TenantID  int32     `json:"tenant_id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	OldStatus *string   `json:"old_status"`
+	UpdatedAt timestamp `json:"updated_at"`
+	Changed   []string  `json:"changed"`
+}
+
+// timestamp decodes a Postgres timestamp without time zone as rendered by to_jsonb.
+type timestamp struct {
+	time.Time
+}
+
+func (t *timestamp) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		return err
+	}
+	parsed, err := time.Parse("2006-01-02T15:04:05.999999", s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Decode converts a document_changes payload into an event.
+func Decode(payload string) (DocumentEvent, error) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return DocumentEvent{}, err
+	}
+	if n.Table != "documents" || n.ID == 0 {
+		return DocumentEvent{}, fmt.Errorf("unexpected %s notification on %q", n.Operation, n.Table)
+	}
+	e := DocumentEvent{
+		DocumentID: n.ID,
+		Title:      n.Title,
+		Status:     n.Status,
+		UpdatedAt:  n.UpdatedAt.Time,
+		Changed:    n.Changed,
+		TenantID:   n.TenantID,
+	}
+	switch {
+	case n.Operation == "INSERT":
+		e.Type = DocumentCreated
+	case n.OldStatus != nil && *n.OldStatus != n.Status:
+		e.Type = DocumentStatusChanged
+		e.PreviousStatus = *n.OldStatus
+	default:
+		e.Type = DocumentUpdated
+	}
+	return e, nil
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/sse"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// catchUpPage is the number of changed documents read at once when catching up.
+const catchUpPage = 500
+
+// Publisher returns a handler for a Feed that publishes every event to b under its Topic.
+func Publisher(b *sse.Broker) func(DocumentEvent) error {
+	return func(e DocumentEvent) error {
+		_, err := b.Publish(Topic(e.TenantID, e.Type), e.Type, e)
+		return err
+	}
+}
+
+// Feed is the pgnotify.Handler of Channel. It decodes notifications into DocumentEvents for
+// handle and, after a reconnect, reads the documents of every tenant changed meanwhile.
+type Feed struct {
+	store  *tenant.Store
+	handle func(DocumentEvent) error
+}
+
+// NewFeed creates a Feed that passes events to handle.
+func NewFeed(store *tenant.Store, handle func(DocumentEvent) error) *Feed {
+	return &Feed{store: store, handle: handle}
+}
+
+// HandleNotification implements pgnotify.Handler.
+func (f *Feed) HandleNotification(_ context.Context, payload string) error {
+	e, err := Decode(payload)
+	if err != nil {
+		return err
+	}
+	return f.handle(e)
+}
+
+// CatchUp implements pgnotify.Handler. It replays every document updated since since, in the
+// order of their updates, as a Replayed event.
+func (f *Feed) CatchUp(ctx context.Context, since time.Time) error {
+	after := repository.ListDocumentChangesParams{
+		UpdatedAt: pgtype.Timestamp{Time: since, Valid: true},
+		Limit:     catchUpPage,
+	}
+	for {
+		var rows []repository.ListDocumentChangesRow
+		err := f.store.SystemTx(ctx, func(q *repository.Queries) error {
+			var err error
+			rows, err = q.ListDocumentChanges(ctx, after)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			e := DocumentEvent{
+				Type:       DocumentUpdated,
+				DocumentID: r.ID,
+				Title:      r.Title,
+				Status:     r.Status.String,
+				UpdatedAt:  r.UpdatedAt.Time,
+				Replayed:   true,
+				TenantID:   r.TenantID,
+			}
+			if r.CreatedAt.Valid && !r.CreatedAt.Time.Before(since) {
+				e.Type = DocumentCreated
+			}
+			if err := f.handle(e); err != nil {
+				return err
+			}
+			after.UpdatedAt, after.ID = r.UpdatedAt, r.ID
+		}
+		if len(rows) < catchUpPage {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc reports whether a dependency is reachable.
+type CheckFunc func(ctx context.Context) error
+
+// Result is the outcome of the most recent run of a check.
+type Result struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+
+	mu     sync.Mutex
+	result Result
+}
+
+// Checker runs dependency checks with a per-check timeout and caches results for a TTL,
+// so frequent probes from orchestrators do not hammer the dependencies.
+type Checker struct {
+	timeout      time.Duration
+	ttl          time.Duration
+	checks       []*check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout, ttl time.Duration) *Checker {
+	return &Checker{timeout: timeout, ttl: ttl}
+}
+
+// Register adds a named dependency check.
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.checks = append(c.checks, &check{name: name, fn: fn})
+}
+
+// SetShuttingDown marks the process as draining; Ready reports false from then on.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether SetShuttingDown has been called.
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Check runs every registered check concurrently, reusing cached results younger than the TTL.
+func (c *Checker) Check(ctx context.Context) []Result {
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, chk)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Ready reports whether the process is not shutting down and every check passes.
+func (c *Checker) Ready(ctx context.Context) (bool, []Result) {
+	results := c.Check(ctx)
+	ready := !c.ShuttingDown()
+	for _, r := range results {
+		ready = ready && r.Healthy
+	}
+	return ready, results
+}
+
+func (c *Checker) run(ctx context.Context, chk *check) Result {
+	chk.mu.Lock()
+	defer chk.mu.Unlock()
+	if !chk.result.CheckedAt.IsZero() && time.Since(chk.result.CheckedAt) < c.ttl {
+		return chk.result
+	}
+
+	// the check gets its own deadline instead of the caller's, so a probe that disconnects does
+	// not cache its cancellation as the dependency's failure for the whole TTL
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := chk.fn(ctx)
+	chk.result = Result{
+		Name:      chk.name,
+		Healthy:   err == nil,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		chk.result.Error = err.Error()
+	}
+	return chk.result
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckIgnoresCallerCancellation(t *testing.T) {
+	c := NewChecker(time.Second, time.Minute)
+	calls := 0
+	c.Register("postgres", func(ctx context.Context) error {
+		calls++
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if results := c.Check(ctx); !results[0].Healthy {
+		t.Fatalf("got %+v, want the check to run despite the caller's cancellation", results[0])
+	}
+	if results := c.Check(context.Background()); !results[0].Healthy || calls != 1 {
+		t.Errorf("got %+v after %d calls, want the cached healthy result", results[0], calls)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := NewChecker(10*time.Millisecond, 0)
+	c.Register("s3", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	results := c.Check(context.Background())
+	if results[0].Healthy || results[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("got %+v, want the check's own deadline to expire", results[0])
+	}
+}
@@ -0,0 +1,28 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// HTTPServer wraps srv as a Component. Stop stops accepting connections and waits
+// for in-flight requests to finish. TLS is used when both certFile and keyFile are set.
+func HTTPServer(name string, srv *http.Server, certFile, keyFile string) Component {
+	return Component{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			var err error
+			if certFile != "" && keyFile != "" {
+				err = srv.ListenAndServeTLS(certFile, keyFile)
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		},
+		Stop: srv.Shutdown,
+	}
+}
@@ -0,0 +1,119 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// Component is a unit managed by a Manager. Every hook is optional.
+type Component struct {
+	Name string
+	// Start prepares the component and must not block; components are started in the order they were added.
+	Start func(ctx context.Context) error
+	// Run performs the long-running work until ctx is cancelled or it fails.
+	// Returning an error before shutdown stops the whole Manager.
+	Run func(ctx context.Context) error
+	// Stop releases the component's resources within ctx's deadline.
+	// Components are stopped in reverse order, after their Run context has been cancelled.
+	Stop func(ctx context.Context) error
+}
+
+// Manager starts components in order and shuts them down in reverse order
+// when its context is cancelled or a component fails.
+type Manager struct {
+	components      []Component
+	shutdownTimeout time.Duration
+	log             *slog.Logger
+}
+
+// New creates a Manager whose shutdown must complete within shutdownTimeout.
+func New(shutdownTimeout time.Duration) *Manager {
+	return &Manager{shutdownTimeout: shutdownTimeout, log: logging.Component("lifecycle")}
+}
+
+// Add appends a component; it is started after and stopped before all previously added ones.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+type running struct {
+	component Component
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Run starts every component and blocks until ctx is cancelled or a component fails,
+// then stops everything that was started. It returns the first failure, if any.
+func (m *Manager) Run(ctx context.Context) error {
+	failed := make(chan error, len(m.components))
+	started := make([]running, 0, len(m.components))
+
+	var runErr error
+	for _, c := range m.components {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				runErr = fmt.Errorf("start %s: %w", c.Name, err)
+				break
+			}
+		}
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		r := running{component: c, cancel: cancel, done: make(chan struct{})}
+		go func(c Component) {
+			defer close(r.done)
+			if c.Run == nil {
+				<-runCtx.Done()
+				return
+			}
+			if err := c.Run(runCtx); err != nil && runCtx.Err() == nil {
+				failed <- fmt.Errorf("%s: %w", c.Name, err)
+			}
+		}(c)
+		started = append(started, r)
+		m.log.Info("Component started", "name", c.Name)
+	}
+
+	if runErr == nil {
+		select {
+		case <-ctx.Done():
+			m.log.Info("Shutting down", "timeout", m.shutdownTimeout)
+		case runErr = <-failed:
+			m.log.Error("Component failed, shutting down", "error", runErr)
+		}
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.shutdownTimeout)
+	defer cancel()
+
+	var stopErrs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := m.stop(stopCtx, started[i]); err != nil {
+			stopErrs = append(stopErrs, err)
+		}
+	}
+	return errors.Join(append([]error{runErr}, stopErrs...)...)
+}
+
+func (m *Manager) stop(ctx context.Context, r running) error {
+	name := r.component.Name
+	r.cancel()
+
+	var err error
+	if r.component.Stop != nil {
+		if err = r.component.Stop(ctx); err != nil {
+			err = fmt.Errorf("stop %s: %w", name, err)
+		}
+	}
+
+	select {
+	case <-r.done:
+		m.log.Info("Component stopped", "name", name)
+	case <-ctx.Done():
+		err = errors.Join(err, fmt.Errorf("stop %s: %w", name, ctx.Err()))
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package logging
+
+import "context"
+
+type requestIDKey struct{}
+
+type documentIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID added to every record logged with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithDocumentID returns ctx carrying the ID of the document being worked on.
+func WithDocumentID(ctx context.Context, id int32) context.Context {
+	return context.WithValue(ctx, documentIDKey{}, id)
+}
+
+// DocumentID returns the document ID carried by ctx, if any.
+func DocumentID(ctx context.Context) (int32, bool) {
+	id, ok := ctx.Value(documentIDKey{}).(int32)
+	return id, ok
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"log/slog"
+	"sync"
+)
+
+var levels = &levelSet{components: map[string]*slog.LevelVar{}}
+
+// levelSet holds the default level and per-component overrides.
+type levelSet struct {
+	def        slog.LevelVar
+	mu         sync.RWMutex
+	components map[string]*slog.LevelVar
+}
+
+func (l *levelSet) SetDefault(level slog.Level) {
+	l.def.Set(level)
+}
+
+func (l *levelSet) Level(component string) slog.Level {
+	if component != "" {
+		l.mu.RLock()
+		v, ok := l.components[component]
+		l.mu.RUnlock()
+		if ok {
+			return v.Level()
+		}
+	}
+	return l.def.Level()
+}
+
+// SetLevel changes the level of component, or the default level when component is empty.
+func SetLevel(component string, level slog.Level) {
+	if component == "" {
+		levels.SetDefault(level)
+		return
+	}
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	v, ok := levels.components[component]
+	if !ok {
+		v = new(slog.LevelVar)
+		levels.components[component] = v
+	}
+	v.Set(level)
+}
+
+// ResetLevel removes a component override so it follows the default level again.
+func ResetLevel(component string) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	delete(levels.components, component)
+}
+
+// Levels returns the default level and every component override.
+func Levels() (slog.Level, map[string]slog.Level) {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+	overrides := make(map[string]slog.Level, len(levels.components))
+	for name, v := range levels.components {
+		overrides[name] = v.Level()
+	}
+	return levels.def.Level(), overrides
+}
@@ -0,0 +1,108 @@
+// Package logging configures slog for every lawdocs command.
+//
+// Records are enriched with the request, trace and document IDs found in the context,
+// so log calls should use the *Context variants (InfoContext, ErrorContext, ...).
+// Each subsystem logs through Component(name), whose level can be changed at runtime.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ComponentKey is the attribute naming the subsystem that emitted a record.
+const ComponentKey = "component"
+
+// redacted lists attribute keys whose values never reach the log output.
+var redacted = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"content":       true,
+	"body":          true,
+}
+
+// New creates a logger writing to w in the given format ("text" or "json")
+// and installs it as the slog default so packages using slog directly share it.
+func New(w io.Writer, level, format string) *slog.Logger {
+	levels.SetDefault(ParseLevel(level))
+
+	opts := &slog.HandlerOptions{
+		// Levels are decided per component by Handler.Enabled.
+		Level:       slog.Level(-8),
+		ReplaceAttr: redact,
+	}
+	var next slog.Handler
+	if strings.EqualFold(format, "json") {
+		next = slog.NewJSONHandler(w, opts)
+	} else {
+		next = slog.NewTextHandler(w, opts)
+	}
+	logger := slog.New(&Handler{next: next})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Component returns a logger for the named subsystem.
+func Component(name string) *slog.Logger {
+	return slog.Default().With(ComponentKey, name)
+}
+
+// ParseLevel converts a level name to a slog.Level, defaulting to info.
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if redacted[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	return a
+}
+
+// Handler filters records by their component's level and adds correlation IDs from the context.
+type Handler struct {
+	next      slog.Handler
+	component string
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levels.Level(h.component)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	if id, ok := DocumentID(ctx); ok {
+		r.AddAttrs(slog.Int64("document_id", int64(id)))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, a := range attrs {
+		if a.Key == ComponentKey {
+			component = a.Value.String()
+		}
+	}
+	return &Handler{next: h.next.WithAttrs(attrs), component: component}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), component: h.component}
+}
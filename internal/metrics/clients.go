@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/jackc/pgx/v5"
+)
+
+// InstrumentAWS records latency and errors for every call made by clients built from cfg.
+func InstrumentAWS(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		// After the service metadata middleware so the service and operation names are known.
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Metrics",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				start := time.Now()
+				out, md, err := next.HandleInitialize(ctx, in)
+				ObserveClientCall(strings.ToLower(awsmiddleware.GetServiceID(ctx)), awsmiddleware.GetOperationName(ctx), start, err)
+				return out, md, err
+			}), middleware.After)
+	})
+}
+
+var queryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	operation string
+	start     time.Time
+}
+
+// PgxTracer records latency and errors of Postgres queries. sqlc queries are labelled
+// with their query name, anything else with its leading SQL keyword.
+type PgxTracer struct{}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{operation: QueryOperation(data.SQL), start: time.Now()})
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if qs, ok := ctx.Value(queryStartKey{}).(queryStart); ok {
+		ObserveClientCall("postgres", qs.operation, qs.start, data.Err)
+	}
+}
+
+// QueryOperation derives a low-cardinality name for a SQL statement.
+func QueryOperation(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if m := queryName.FindStringSubmatch(sql); m != nil {
+		return m[1]
+	}
+	if i := strings.IndexAny(sql, " \t\n"); i > 0 {
+		sql = sql[:i]
+	}
+	return strings.ToLower(sql)
+}
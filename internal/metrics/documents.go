@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/workflow"
+)
+
+// WatchDocuments refreshes the documents-by-status gauge every interval until ctx is cancelled.
+// The counts cover every tenant.
+func WatchDocuments(ctx context.Context, store *tenant.Store, interval time.Duration) error {
+	log := logging.Component("metrics")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := store.SystemTx(ctx, func(q *repository.Queries) error {
+			return refreshDocuments(ctx, q)
+		})
+		if err != nil && ctx.Err() == nil {
+			log.ErrorContext(ctx, "Failed to refresh document metrics", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func refreshDocuments(ctx context.Context, repo *repository.Queries) error {
+	rows, err := repo.CountDocumentsByStatus(ctx)
+	if err != nil {
+		return err
+	}
+	// report statuses that no longer have documents as 0 rather than a stale count
+	counts := map[string]float64{
+		workflow.StatusDraft:        0,
+		workflow.StatusPreProcessed: 0,
+		workflow.StatusAuditing:     0,
+		workflow.StatusAudited:      0,
+	}
+	for _, row := range rows {
+		counts[row.Status.String] = float64(row.Count)
+	}
+	for status, n := range counts {
+		DocumentsByStatus.WithLabelValues(status).Set(n)
+	}
+	return nil
+}
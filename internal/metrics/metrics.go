@@ -0,0 +1,181 @@
+// Package metrics defines the Prometheus collectors exported on /metrics.
+// See docs/metrics.md for a description of every series.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests by route, method and status code",
+		},
+		[]string{"method", "route", "status"},
+	)
+	HTTPDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route, method and status code",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	FileUploads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "file_upload_total",
+			Help: "Total number of file uploads",
+		},
+		[]string{"doctype", "priority"},
+	)
+	UploadSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "file_upload_size_bytes",
+			Help:    "Size of uploaded files",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 10), // 1KiB .. 256MiB
+		},
+		[]string{"doctype"},
+	)
+
+	ClientDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "client_call_duration_seconds",
+			Help:    "Latency of calls to external dependencies (s3, sqs, postgres) by operation",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"client", "operation"},
+	)
+	ClientErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "client_call_errors_total",
+			Help: "Failed calls to external dependencies (s3, sqs, postgres) by operation",
+		},
+		[]string{"client", "operation"},
+	)
+
+	QueueLag = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "processor_queue_lag_seconds",
+			Help:    "Time between a notification being sent to SQS and the processor receiving it",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12), // 100ms .. ~7min
+		},
+	)
+	ProcessingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "processor_stage_duration_seconds",
+			Help:    "Time spent in each document processing stage",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"stage"},
+	)
+	ProcessingResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "processor_stage_total",
+			Help: "Document processing stage outcomes",
+		},
+		[]string{"stage", "outcome"},
+	)
+
+	DocumentsByStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "documents",
+			Help: "Number of documents by workflow status",
+		},
+		[]string{"status"},
+	)
+
+	WebsocketClients = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_clients",
+			Help: "Number of connected websocket clients",
+		},
+	)
+	WebsocketDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "websocket_slow_clients_total",
+			Help: "Websocket clients disconnected because their outbound queue was full",
+		},
+	)
+
+	StreamEntries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stream_entries_total",
+			Help: "Redis stream entries finished or failed by consumers, by outcome (handled, failed, dead_lettered, dropped, trimmed)",
+		},
+		[]string{"stream", "outcome"},
+	)
+
+	ReplicationChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "replication_changes_total",
+			Help: "Row changes read from logical replication, by operation and outcome (published, duplicate, skipped)",
+		},
+		[]string{"operation", "outcome"},
+	)
+	ReplicationLSN = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "replication_published_lsn",
+			Help: "Commit LSN of the last change published from logical replication",
+		},
+	)
+
+	WebhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Webhook delivery attempts by outcome (succeeded, retrying, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	Alerts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alerts_total",
+			Help: "Alerts raised by rules, by outcome (fired, deduplicated, silenced)",
+		},
+		[]string{"rule", "outcome"},
+	)
+	AlertDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alert_deliveries_total",
+			Help: "Alert deliveries by sink and outcome (success, failure)",
+		},
+		[]string{"sink", "outcome"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequests, HTTPDuration,
+		FileUploads, UploadSize,
+		ClientDuration, ClientErrors,
+		QueueLag, ProcessingDuration, ProcessingResults,
+		DocumentsByStatus,
+		WebsocketClients, WebsocketDropped,
+		StreamEntries,
+		ReplicationChanges, ReplicationLSN,
+		WebhookDeliveries,
+		Alerts, AlertDeliveries,
+	)
+}
+
+// ObserveClientCall records the latency and, if err is non-nil, the failure of a dependency call.
+func ObserveClientCall(client, operation string, start time.Time, err error) {
+	ClientDuration.WithLabelValues(client, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ClientErrors.WithLabelValues(client, operation).Inc()
+	}
+}
+
+// ObserveStage records the duration and outcome of a processing stage.
+func ObserveStage(stage string, start time.Time, err error) {
+	ProcessingDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	ProcessingResults.WithLabelValues(stage, outcome).Inc()
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wilbyang/law-docs/internal/db/migrations"
+)
+
+// files returns a MapFS with the given file names, each holding its own name.
+func files(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys[name] = &fstest.MapFile{Data: []byte(name)}
+	}
+	return fsys
+}
+
+func TestLoad(t *testing.T) {
+	got, err := Load(files(
+		"0002_tenants.up.sql",
+		"0001_init.down.sql",
+		"0003_audit_events.up.sql",
+		"0001_init.up.sql",
+		"0002_tenants.down.sql",
+		// not migrations
+		"migrations.go",
+		"README.md",
+		"0004_notes.sql",
+		"0004_notes.UP.sql",
+		"notes.up.sql",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "init", Up: "0001_init.up.sql", Down: "0001_init.down.sql"},
+		{Version: 2, Name: "tenants", Up: "0002_tenants.up.sql", Down: "0002_tenants.down.sql"},
+		// down scripts are optional until the migration is reverted
+		{Version: 3, Name: "audit_events", Up: "0003_audit_events.up.sql"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if got, err := Load(files()); err != nil || len(got) != 0 {
+		t.Errorf("empty directory: got %+v (%v)", got, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"no up script", []string{"0001_init.up.sql", "0002_tenants.down.sql"}, "migration 2_tenants has no up script"},
+		{"conflicting names", []string{"0001_init.up.sql", "0001_setup.down.sql"}, `conflicting names "init" and "setup"`},
+		{"duplicate version", []string{"0001_init.up.sql", "1_init.up.sql"}, "migration 1 has two up scripts"},
+		{"gap", []string{"0001_init.up.sql", "0003_matters.up.sql"}, "migration 2 is missing before 3_matters"},
+		{"not from 1", []string{"0002_tenants.up.sql"}, "migration 1 is missing"},
+		{"version zero", []string{"0000_init.up.sql", "0001_tenants.up.sql"}, "migration 1 is missing before 0_init"},
+		{"version overflow", []string{"99999999999999999999_init.up.sql"}, "invalid migration version"},
+	} {
+		_, err := Load(files(tc.files...))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	all, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mig := range all {
+		if mig.Down == "" {
+			t.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+	}
+}
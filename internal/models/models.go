@@ -6,5 +6,21 @@ type Meta struct {
 }
 
 type Notification struct {
-	DocID int32 `json:"doc_id"`
+	DocID    int32 `json:"doc_id"`
+	TenantID int32 `json:"tenant_id"`
+}
+
+// Anchor pins a comment thread to part of a document: a range of characters in its content
+// (Type "text", rune offsets Start to End) or a rectangle on a page of the uploaded file
+// (Type "pdf", coordinates relative to the page size, 0 to 1).
+type Anchor struct {
+	Type   string  `json:"type"`
+	Start  int     `json:"start,omitempty"`
+	End    int     `json:"end,omitempty"`
+	Quote  string  `json:"quote,omitempty"`
+	Page   int     `json:"page,omitempty"`
+	X      float64 `json:"x,omitempty"`
+	Y      float64 `json:"y,omitempty"`
+	Width  float64 `json:"width,omitempty"`
+	Height float64 `json:"height,omitempty"`
 }
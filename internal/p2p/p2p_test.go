@@ -0,0 +1,167 @@
+package p2p_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/wilbyang/law-docs/internal/p2p"
+	"github.com/wilbyang/law-docs/internal/signaling"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+// pair connects a sender and a receiver through signaling on one hub. The receiver accepts the
+// first data channel into dir and hands the transfer to the returned channel.
+func pair(t *testing.T, ctx context.Context, dir string) (*p2p.Peer, <-chan *p2p.Transfer) {
+	t.Helper()
+	hub := ws.NewHub(ws.Options{})
+	rooms := signaling.NewServer(hub, time.Second)
+	runCtx, stop := context.WithCancel(ctx)
+	t.Cleanup(stop)
+	go rooms.Run(runCtx)
+
+	var users atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tenant.WithPrincipal(r.Context(), tenant.Principal{TenantID: 7, UserID: users.Add(1)})
+		hub.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	var se webrtc.SettingEngine
+	se.SetIncludeLoopbackCandidate(true)
+	opts := p2p.Options{API: webrtc.NewAPI(webrtc.WithSettingEngine(se))}
+
+	connect := func() *signaling.Client {
+		client, err := signaling.Dial(ctx, url, "token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+		if _, err := client.Join(ctx, "review"); err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	sender, receiver := connect(), connect()
+
+	newPeer := func(client *signaling.Client, to string) *p2p.Peer {
+		peer, err := p2p.NewPeer(opts, func(sig signaling.Signal) error {
+			sig.To = to
+			return client.Signal(ctx, "review", sig)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { peer.Close() })
+		go func() {
+			for e := range client.Events() {
+				if e.Event == signaling.Signalled && e.Peer.Session == to {
+					if err := peer.HandleSignal(*e.Signal); err != nil {
+						t.Errorf("unable to apply %s: %v", e.Signal.Kind, err)
+					}
+				}
+			}
+		}()
+		return peer
+	}
+	from := newPeer(sender, receiver.Session())
+	to := newPeer(receiver, sender.Session())
+	transfers := make(chan *p2p.Transfer, 1)
+	to.OnDataChannel(func(dc *webrtc.DataChannel) {
+		transfers <- p2p.Accept(dc, dir)
+	})
+	return from, transfers
+}
+
+// accepted returns the receiver's transfer once the sender's data channel reached it.
+func accepted(t *testing.T, ctx context.Context, transfers <-chan *p2p.Transfer) *p2p.Transfer {
+	t.Helper()
+	select {
+	case transfer := <-transfers:
+		return transfer
+	case <-ctx.Done():
+		t.Fatal("data channel never reached the receiver")
+		return nil
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	content := bytes.Repeat([]byte("contract clause\n"), 5000)
+	src := filepath.Join(t.TempDir(), "contract.pdf")
+	if err := os.WriteFile(src, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	from, transfers := pair(t, ctx, dir)
+
+	dc, err := from.Offer("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(chan error, 1)
+	go func() {
+		_, err := p2p.SendFile(ctx, dc, src)
+		sent <- err
+	}()
+	file, err := accepted(t, ctx, transfers).Wait(ctx)
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if file.Name != "contract.pdf" || file.Size != int64(len(content)) || file.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("received %+v", file.Header)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "contract.pdf"))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("saved file differs from the sent one: %v", err)
+	}
+}
+
+func TestTransferChecksumMismatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	from, transfers := pair(t, ctx, dir)
+
+	dc, err := from.Offer("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("the signed version")
+	wrong := sha256.Sum256([]byte("the draft"))
+	h := p2p.Header{Name: "contract.pdf", Size: int64(len(content)), SHA256: hex.EncodeToString(wrong[:])}
+	sent := make(chan error, 1)
+	go func() { sent <- p2p.Send(ctx, dc, h, bytes.NewReader(content)) }()
+
+	if _, err := accepted(t, ctx, transfers).Wait(ctx); err == nil || !strings.Contains(err.Error(), "SHA-256 mismatch") {
+		t.Errorf("receive: got %v, want a SHA-256 mismatch", err)
+	}
+	if err := <-sent; err == nil || !strings.Contains(err.Error(), "SHA-256 mismatch") {
+		t.Errorf("send: got %v, want the receiver's refusal", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("refused file left %s behind", e.Name())
+	}
+}
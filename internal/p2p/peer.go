@@ -0,0 +1,159 @@
+// Package p2p transfers files between two peers over a WebRTC data channel. A Peer exchanges its
+// session description and ICE candidates through a signaling function, one at a time as they are
+// gathered, and SendFile and Accept speak a small protocol on the channel: a JSON header with the
+// file's name, size and SHA-256, the content in binary messages, and the receiver's verdict.
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/signaling"
+)
+
+// Options configures a Peer. Zero values select the defaults.
+type Options struct {
+	// ICEServers are the STUN and TURN servers, none by default, which suffices on one network.
+	ICEServers []webrtc.ICEServer
+	// API creates the peer connection, webrtc's default API by default. Tests pass one whose
+	// setting engine includes loopback candidates.
+	API *webrtc.API
+}
+
+// Peer is one end of a WebRTC connection. Signals are sent with the function passed to NewPeer
+// and the remote peer's signals are passed to HandleSignal.
+type Peer struct {
+	pc   *webrtc.PeerConnection
+	send func(signaling.Signal) error
+
+	mu sync.Mutex
+	// candidates holds remote candidates received before the remote description
+	candidates []webrtc.ICECandidateInit
+	remote     bool
+
+	log *slog.Logger
+}
+
+// NewPeer creates a Peer sending its signals, with Kind and Data set, to send.
+func NewPeer(opts Options, send func(signaling.Signal) error) (*Peer, error) {
+	api := opts.API
+	if api == nil {
+		api = webrtc.NewAPI()
+	}
+	pc, err := api.NewPeerConnection(webrtc.Configuration{ICEServers: opts.ICEServers})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create peer connection: %w", err)
+	}
+	p := &Peer{pc: pc, send: send, log: logging.Component("p2p")}
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		// nil marks the end of gathering, which trickling peers need not announce
+		if c == nil {
+			return
+		}
+		if err := p.signal(signaling.Candidate, c.ToJSON()); err != nil {
+			p.log.Warn("Failed to send ICE candidate", "error", err)
+		}
+	})
+	pc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		p.log.Debug("Peer connection state changed", "state", s.String())
+	})
+	return p, nil
+}
+
+// Offer opens a reliable, ordered data channel and sends the offer. The remote peer receives the
+// channel through OnDataChannel.
+func (p *Peer) Offer(label string) (*webrtc.DataChannel, error) {
+	dc, err := p.pc.CreateDataChannel(label, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create data channel: %w", err)
+	}
+	offer, err := p.pc.CreateOffer(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create offer: %w", err)
+	}
+	if err := p.pc.SetLocalDescription(offer); err != nil {
+		return nil, fmt.Errorf("unable to set local description: %w", err)
+	}
+	if err := p.signal(signaling.Offer, offer); err != nil {
+		return nil, fmt.Errorf("unable to send offer: %w", err)
+	}
+	return dc, nil
+}
+
+// OnDataChannel registers fn to be called with the data channels the remote peer opens. fn must
+// set up the channel's handlers before it returns, as Accept does.
+func (p *Peer) OnDataChannel(fn func(dc *webrtc.DataChannel)) {
+	p.pc.OnDataChannel(fn)
+}
+
+// HandleSignal applies a signal of the remote peer: it answers an offer, takes an answer and adds
+// a candidate, holding candidates back until the remote description is known.
+func (p *Peer) HandleSignal(sig signaling.Signal) error {
+	switch sig.Kind {
+	case signaling.Offer, signaling.Answer:
+		var desc webrtc.SessionDescription
+		if err := json.Unmarshal(sig.Data, &desc); err != nil {
+			return fmt.Errorf("invalid %s: %w", sig.Kind, err)
+		}
+		if err := p.pc.SetRemoteDescription(desc); err != nil {
+			return fmt.Errorf("unable to set remote description: %w", err)
+		}
+		p.mu.Lock()
+		p.remote = true
+		candidates := p.candidates
+		p.candidates = nil
+		p.mu.Unlock()
+		for _, c := range candidates {
+			if err := p.pc.AddICECandidate(c); err != nil {
+				return fmt.Errorf("unable to add ICE candidate: %w", err)
+			}
+		}
+		if sig.Kind == signaling.Answer {
+			return nil
+		}
+		answer, err := p.pc.CreateAnswer(nil)
+		if err != nil {
+			return fmt.Errorf("unable to create answer: %w", err)
+		}
+		if err := p.pc.SetLocalDescription(answer); err != nil {
+			return fmt.Errorf("unable to set local description: %w", err)
+		}
+		return p.signal(signaling.Answer, answer)
+
+	case signaling.Candidate:
+		var c webrtc.ICECandidateInit
+		if err := json.Unmarshal(sig.Data, &c); err != nil {
+			return fmt.Errorf("invalid candidate: %w", err)
+		}
+		p.mu.Lock()
+		if !p.remote {
+			p.candidates = append(p.candidates, c)
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+		if err := p.pc.AddICECandidate(c); err != nil {
+			return fmt.Errorf("unable to add ICE candidate: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown signal kind %q", sig.Kind)
+}
+
+// Close closes the connection and its data channels.
+func (p *Peer) Close() error {
+	return p.pc.Close()
+}
+
+func (p *Peer) signal(kind string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.send(signaling.Signal{Kind: kind, Data: data})
+}
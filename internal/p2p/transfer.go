@@ -0,0 +1,320 @@
+package p2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Header is the first message of a transfer, sent as text.
+type Header struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// result is the receiver's last message, sent as text once the content is verified or refused.
+type result struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	// ChunkSize is the size of the binary messages, which every WebRTC implementation accepts.
+	ChunkSize = 16 << 10
+	// maxBuffered is how much the sender queues on the channel before it waits for it to drain
+	// to lowBuffered.
+	maxBuffered = 1 << 20
+	lowBuffered = 256 << 10
+)
+
+// File is a received file.
+type File struct {
+	Header
+	// Path is where the file was saved.
+	Path string
+}
+
+// SendFile sends the file at path on dc, waiting for the channel to open, and returns once the
+// receiver has verified it.
+func SendFile(ctx context.Context, dc *webrtc.DataChannel, path string) (Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	size, err := io.Copy(sum, f)
+	if err != nil {
+		return Header{}, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Header{}, err
+	}
+	h := Header{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(sum.Sum(nil))}
+	return h, Send(ctx, dc, h, f)
+}
+
+// Send sends h and then h.Size bytes of r on dc, waiting for the channel to open, and returns
+// once the receiver has verified them. Whenever more than a MiB is queued on the channel it waits
+// for the channel to drain instead of queueing more.
+func Send(ctx context.Context, dc *webrtc.DataChannel, h Header, r io.Reader) error {
+	opened := make(chan struct{})
+	var openOnce sync.Once
+	dc.OnOpen(func() { openOnce.Do(func() { close(opened) }) })
+	closed := make(chan struct{})
+	dc.OnClose(func() { close(closed) })
+	verdict := make(chan result, 1)
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		var res result
+		if !msg.IsString || json.Unmarshal(msg.Data, &res) != nil {
+			res = result{Error: "unexpected message from receiver"}
+		}
+		select {
+		case verdict <- res:
+		default:
+		}
+	})
+	low := make(chan struct{}, 1)
+	dc.SetBufferedAmountLowThreshold(lowBuffered)
+	dc.OnBufferedAmountLow(func() {
+		select {
+		case low <- struct{}{}:
+		default:
+		}
+	})
+
+	select {
+	case <-opened:
+	case <-closed:
+		return errors.New("data channel closed before it opened")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := dc.SendText(string(header)); err != nil {
+		return fmt.Errorf("unable to send header: %w", err)
+	}
+
+	buf := make([]byte, ChunkSize)
+	var sent int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if sent += int64(n); sent > h.Size {
+				return fmt.Errorf("content is longer than the %d bytes announced", h.Size)
+			}
+			select {
+			case res := <-verdict:
+				return fmt.Errorf("receiver refused the file: %s", res.Error)
+			default:
+			}
+			for dc.BufferedAmount() > maxBuffered {
+				select {
+				case <-low:
+				case res := <-verdict:
+					return fmt.Errorf("receiver refused the file: %s", res.Error)
+				case <-closed:
+					return errors.New("data channel closed during transfer")
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err := dc.Send(buf[:n]); err != nil {
+				return fmt.Errorf("unable to send content: %w", err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read content: %w", err)
+		}
+	}
+	if sent != h.Size {
+		return fmt.Errorf("content is %d bytes, not the %d announced", sent, h.Size)
+	}
+
+	select {
+	case res := <-verdict:
+		if !res.OK {
+			return fmt.Errorf("receiver refused the file: %s", res.Error)
+		}
+		return nil
+	case <-closed:
+		return errors.New("data channel closed before the receiver verified the file")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Transfer is a file being received.
+type Transfer struct {
+	dc  *webrtc.DataChannel
+	dir string
+
+	// the fields below are only used by the channel's message handler
+	header  *Header
+	tmp     *os.File
+	sum     hash.Hash
+	written int64
+
+	once   sync.Once
+	file   File
+	err    error
+	done   chan struct{}
+	closed chan struct{}
+}
+
+// Accept receives one file on dc into dir. Call it from the OnDataChannel callback, so that its
+// handlers are in place before the first message arrives, and Wait for the outcome. The file is
+// written under a temporary name and moved into place once its size and SHA-256 match the
+// header; an existing file is never overwritten.
+func Accept(dc *webrtc.DataChannel, dir string) *Transfer {
+	t := &Transfer{dc: dc, dir: dir, done: make(chan struct{}), closed: make(chan struct{})}
+	dc.OnMessage(t.message)
+	dc.OnClose(func() {
+		t.finish(File{}, errors.New("data channel closed before the file was complete"))
+		close(t.closed)
+	})
+	return t
+}
+
+// Wait returns the received file once it is saved, or why it was refused.
+func (t *Transfer) Wait(ctx context.Context) (File, error) {
+	select {
+	case <-t.done:
+		return t.file, t.err
+	case <-ctx.Done():
+		return File{}, ctx.Err()
+	}
+}
+
+// Closed returns a channel that is closed with the data channel. Senders close it once they have
+// the verdict, so a receiver that waits for it before closing the connection knows it arrived.
+func (t *Transfer) Closed() <-chan struct{} {
+	return t.closed
+}
+
+func (t *Transfer) message(msg webrtc.DataChannelMessage) {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	if t.header == nil {
+		if !msg.IsString {
+			t.refuse(errors.New("content before header"))
+			return
+		}
+		var h Header
+		if err := json.Unmarshal(msg.Data, &h); err != nil {
+			t.refuse(fmt.Errorf("invalid header: %w", err))
+			return
+		}
+		if err := validHeader(h); err != nil {
+			t.refuse(err)
+			return
+		}
+		tmp, err := os.CreateTemp(t.dir, "."+h.Name+".*.part")
+		if err != nil {
+			t.refuse(err)
+			return
+		}
+		t.header, t.tmp, t.sum = &h, tmp, sha256.New()
+	} else {
+		if msg.IsString {
+			t.refuse(errors.New("unexpected text message during transfer"))
+			return
+		}
+		if t.written+int64(len(msg.Data)) > t.header.Size {
+			t.refuse(fmt.Errorf("content is longer than the %d bytes announced", t.header.Size))
+			return
+		}
+		if _, err := t.tmp.Write(msg.Data); err != nil {
+			t.refuse(err)
+			return
+		}
+		t.sum.Write(msg.Data)
+		t.written += int64(len(msg.Data))
+	}
+	if t.written == t.header.Size {
+		t.complete()
+	}
+}
+
+// complete verifies the content and moves it into place.
+func (t *Transfer) complete() {
+	if err := t.tmp.Close(); err != nil {
+		t.refuse(err)
+		return
+	}
+	if sum := hex.EncodeToString(t.sum.Sum(nil)); sum != strings.ToLower(t.header.SHA256) {
+		t.refuse(fmt.Errorf("SHA-256 mismatch: received %s, announced %s", sum, t.header.SHA256))
+		return
+	}
+	path := filepath.Join(t.dir, t.header.Name)
+	// a link fails if path exists, where a rename would replace it
+	if err := os.Link(t.tmp.Name(), path); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			err = fmt.Errorf("%s already exists", t.header.Name)
+		}
+		t.refuse(err)
+		return
+	}
+	os.Remove(t.tmp.Name())
+	t.reply(result{OK: true})
+	t.finish(File{Header: *t.header, Path: path}, nil)
+}
+
+// refuse tells the sender why the file was refused and removes what was written.
+func (t *Transfer) refuse(err error) {
+	if t.tmp != nil {
+		t.tmp.Close()
+		os.Remove(t.tmp.Name())
+	}
+	t.reply(result{Error: err.Error()})
+	t.finish(File{}, err)
+}
+
+func (t *Transfer) reply(res result) {
+	data, _ := json.Marshal(res)
+	t.dc.SendText(string(data))
+}
+
+func (t *Transfer) finish(file File, err error) {
+	t.once.Do(func() {
+		t.file, t.err = file, err
+		close(t.done)
+	})
+}
+
+// validHeader checks a received header. Names are plain file names, so a sender cannot write
+// outside the directory.
+func validHeader(h Header) error {
+	if h.Name == "" || h.Name == "." || h.Name == ".." || strings.ContainsAny(h.Name, "/\\\x00") {
+		return fmt.Errorf("invalid file name %q", h.Name)
+	}
+	if h.Size < 0 {
+		return fmt.Errorf("invalid size %d", h.Size)
+	}
+	if sum, err := hex.DecodeString(h.SHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 %q", h.SHA256)
+	}
+	return nil
+}
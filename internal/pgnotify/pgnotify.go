@@ -0,0 +1,177 @@
+// Package pgnotify keeps a Postgres LISTEN running. It holds one connection out of a pool,
+// reconnects with exponential backoff when the connection fails, LISTENs again on the new
+// connection and lets the handler catch up on what was committed while nobody was listening.
+package pgnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// Handler receives the notifications of a Listener.
+type Handler interface {
+	// HandleNotification handles the payload of one notification. Errors are logged; the
+	// notification is not delivered again.
+	HandleNotification(ctx context.Context, payload string) error
+	// CatchUp is called after a reconnect, once LISTEN is in place again, with the time from
+	// which notifications may have been missed. The handler reads those changes from the
+	// database itself. If it fails the listener reconnects and tries again.
+	CatchUp(ctx context.Context, since time.Time) error
+}
+
+// Options configures a Listener. Zero values select the defaults.
+type Options struct {
+	// Channels are the channels to LISTEN on.
+	Channels []string
+	// MinBackoff is the first delay before reconnecting, 500ms by default. It doubles with every
+	// failed attempt up to MaxBackoff, 30s by default.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PingInterval is how long the connection may be idle before it is pinged, 30s by default.
+	// Without pings a connection that silently died would wait for notifications forever.
+	PingInterval time.Duration
+	// Overlap is subtracted from the time passed to CatchUp, 1 minute by default, to allow for
+	// clock differences between the servers that stamp changes and this one.
+	Overlap time.Duration
+}
+
+// Listener LISTENs on a set of channels.
+type Listener struct {
+	opts Options
+	log  *slog.Logger
+	// connect returns a connection of the listener's own, which it closes
+	connect func(ctx context.Context) (conn, error)
+}
+
+// conn is the part of *pgx.Conn a Listener uses.
+type conn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Ping(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// New creates a Listener taking its connection from pool.
+func New(pool *pgxpool.Pool, opts Options) *Listener {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(30*time.Second, opts.MinBackoff)
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+	if opts.Overlap <= 0 {
+		opts.Overlap = time.Minute
+	}
+	connect := func(ctx context.Context) (conn, error) {
+		pooled, err := pool.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// a listening connection must not go back to the pool
+		return pooled.Hijack(), nil
+	}
+	return &Listener{opts: opts, log: logging.Component("pgnotify").With("channels", opts.Channels), connect: connect}
+}
+
+// Run passes notifications to h until ctx is cancelled. It never gives up on a failed
+// connection; failures are logged and retried.
+func (l *Listener) Run(ctx context.Context, h Handler) error {
+	if len(l.opts.Channels) == 0 {
+		return errors.New("pgnotify: no channels to listen on")
+	}
+	// alive is when the last connection was last known to receive notifications; zero until the
+	// first connection, which has nothing to catch up on
+	var alive time.Time
+	backoff := l.opts.MinBackoff
+	for {
+		connected, err := l.listen(ctx, h, &alive)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if connected {
+			backoff = l.opts.MinBackoff
+		}
+		delay := jitter(backoff)
+		l.log.ErrorContext(ctx, "Listener connection failed, reconnecting", "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, l.opts.MaxBackoff)
+	}
+}
+
+// jitter returns a random delay between half and one and a half times backoff. It keeps replicas
+// from reconnecting in lockstep after a database restart.
+func jitter(backoff time.Duration) time.Duration {
+	return backoff/2 + rand.N(backoff)
+}
+
+// listen serves one connection until it fails. It reports whether the connection got as far as
+// receiving notifications, and keeps *alive up to date.
+func (l *Listener) listen(ctx context.Context, h Handler, alive *time.Time) (bool, error) {
+	conn, err := l.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	for _, channel := range l.opts.Channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return false, fmt.Errorf("unable to listen to %s: %w", channel, err)
+		}
+	}
+	// every change committed from now on is notified on conn
+	listening := time.Now()
+	if !alive.IsZero() {
+		since := alive.Add(-l.opts.Overlap)
+		if err := h.CatchUp(ctx, since); err != nil {
+			return false, fmt.Errorf("failed to catch up: %w", err)
+		}
+		l.log.InfoContext(ctx, "Caught up after reconnecting", "since", since)
+	}
+	*alive = listening
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, l.opts.PingInterval)
+		n, err := conn.WaitForNotification(waitCtx)
+		// checked before cancel, which would make any failure look like a timeout
+		idle := waitCtx.Err() != nil
+		cancel()
+		switch {
+		case err == nil:
+			*alive = time.Now()
+			if err := h.HandleNotification(ctx, n.Payload); err != nil {
+				l.log.WarnContext(ctx, "Failed to handle notification", "channel", n.Channel, "error", err)
+			}
+			continue
+		case ctx.Err() != nil:
+			return true, nil
+		case !idle:
+			return true, err
+		}
+		// idle for PingInterval; a timeout leaves the connection usable
+		pinged := time.Now()
+		pingCtx, cancel := context.WithTimeout(ctx, l.opts.PingInterval)
+		err = conn.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			return true, fmt.Errorf("ping failed: %w", err)
+		}
+		*alive = pinged
+	}
+}
@@ -0,0 +1,174 @@
+package pgnotify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestJitter(t *testing.T) {
+	for _, backoff := range []time.Duration{time.Millisecond, 500 * time.Millisecond, 30 * time.Second} {
+		for range 1000 {
+			if d := jitter(backoff); d < backoff/2 || d >= backoff/2+backoff {
+				t.Fatalf("jitter(%s) = %s", backoff, d)
+			}
+		}
+	}
+}
+
+var errLost = errors.New("connection lost")
+
+// step is what one WaitForNotification of a fakeConn does: deliver payload, fail with err, or,
+// when idle, wait for its context to time out.
+type step struct {
+	payload string
+	err     error
+	idle    bool
+}
+
+// fakeConn plays its steps and then fails with errLost.
+type fakeConn struct {
+	steps []step
+	execs []string
+	// idle and pinged are when the last idle step started and when Ping was called
+	idle, pinged time.Time
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.execs = append(c.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	if len(c.steps) == 0 {
+		return nil, errLost
+	}
+	s := c.steps[0]
+	c.steps = c.steps[1:]
+	switch {
+	case s.idle:
+		c.idle = time.Now()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case s.err != nil:
+		return nil, s.err
+	}
+	return &pgconn.Notification{Channel: "documents", Payload: s.payload}, nil
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	c.pinged = time.Now()
+	return nil
+}
+
+func (c *fakeConn) Close(context.Context) error { return nil }
+
+// handler records what a Listener passes it. CatchUp fails as often as failCatchUp says.
+type handler struct {
+	failCatchUp int
+	handled     []time.Time
+	payloads    []string
+	catchUps    []time.Time
+}
+
+func (h *handler) HandleNotification(_ context.Context, payload string) error {
+	h.handled = append(h.handled, time.Now())
+	h.payloads = append(h.payloads, payload)
+	return nil
+}
+
+func (h *handler) CatchUp(_ context.Context, since time.Time) error {
+	h.catchUps = append(h.catchUps, since)
+	if h.failCatchUp > 0 {
+		h.failCatchUp--
+		return errors.New("catch-up failed")
+	}
+	return nil
+}
+
+// run runs l over conns, a nil one failing to connect, and returns the time of every connection
+// attempt once they are used up.
+func run(t *testing.T, l *Listener, h Handler, conns ...*fakeConn) []time.Time {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var attempts []time.Time
+	l.connect = func(context.Context) (conn, error) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) > len(conns) {
+			cancel()
+			return nil, context.Canceled
+		}
+		if c := conns[len(attempts)-1]; c != nil {
+			return c, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	if err := l.Run(ctx, h); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		t.Fatal("timed out")
+	}
+	return attempts[:len(conns)]
+}
+
+func TestCatchUp(t *testing.T) {
+	const overlap = time.Minute
+	l := New(nil, Options{
+		Channels:     []string{"documents", "comments"},
+		MinBackoff:   time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+		PingInterval: 20 * time.Millisecond,
+		Overlap:      overlap,
+	})
+	first := &fakeConn{steps: []step{{payload: "1"}, {payload: "2"}}}
+	failing := &fakeConn{}
+	idle := &fakeConn{steps: []step{{idle: true}}}
+	last := &fakeConn{steps: []step{{payload: "3"}}}
+	h := &handler{failCatchUp: 1}
+	run(t, l, h, first, failing, idle, last)
+
+	if want := []string{`LISTEN "documents"`, `LISTEN "comments"`}; !slices.Equal(first.execs, want) {
+		t.Errorf("executed %q, want %q", first.execs, want)
+	}
+	if !slices.Equal(h.payloads, []string{"1", "2", "3"}) {
+		t.Errorf("handled %q", h.payloads)
+	}
+	// the first connection has nothing to catch up on; the others catch up from when the
+	// previous one last received a notification or answered a ping
+	if len(h.catchUps) != 3 {
+		t.Fatalf("caught up %d times, want 3", len(h.catchUps))
+	}
+	lastHandled := h.handled[1].Add(-overlap)
+	if since := h.catchUps[0]; since.After(lastHandled) || since.Before(lastHandled.Add(-time.Second)) {
+		t.Errorf("caught up since %s, want the last notification less the overlap, %s", since, lastHandled)
+	}
+	if !h.catchUps[1].Equal(h.catchUps[0]) {
+		t.Errorf("after a failed catch-up, caught up since %s, want %s again", h.catchUps[1], h.catchUps[0])
+	}
+	if since := h.catchUps[2].Add(overlap); since.Before(idle.idle) || since.After(idle.pinged) {
+		t.Errorf("caught up since %s, want the ping between %s and %s less the overlap", h.catchUps[2], idle.idle, idle.pinged)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	const minBackoff = 10 * time.Millisecond
+	l := New(nil, Options{Channels: []string{"documents"}, MinBackoff: minBackoff, MaxBackoff: time.Second})
+	// four failed attempts, one that listened until it lost the connection, and one more
+	attempts := run(t, l, &handler{}, nil, nil, nil, nil, &fakeConn{}, nil)
+
+	for i, backoff := range []time.Duration{minBackoff, 2 * minBackoff, 4 * minBackoff, 8 * minBackoff} {
+		if gap := attempts[i+1].Sub(attempts[i]); gap < backoff/2 {
+			t.Errorf("attempt %d came after %s, want at least %s", i+2, gap, backoff/2)
+		}
+	}
+	// without the reset the delay would be at least 80ms, with it below 15ms
+	if gap := attempts[5].Sub(attempts[4]); gap < minBackoff/2 || gap > 50*time.Millisecond {
+		t.Errorf("reconnected after %s, want the backoff reset to %s", gap, minBackoff)
+	}
+}
@@ -0,0 +1,275 @@
+// Package presence tracks who has a document open over the websocket hub. Clients announce
+// themselves with presence messages and every change is published to the document's presence
+// topic, so all nodes of a cluster fold the same events into the same view.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+// Message types handled on the hub. A presence message joins the document named by its topic or
+// updates the session's state there; it doubles as the heartbeat. A leave message leaves it.
+const (
+	TypePresence = "presence"
+	TypeLeave    = "leave"
+)
+
+// Session states.
+const (
+	Viewing = "viewing"
+	Editing = "editing"
+)
+
+// Presence events.
+const (
+	Join   = "join"
+	Update = "update"
+	Leave  = "leave"
+)
+
+// Reasons of leave events.
+const (
+	ReasonLeft         = "left"
+	ReasonIdle         = "idle"
+	ReasonDisconnected = "disconnected"
+)
+
+// maxPosition bounds the encoded cursor and selection of a session.
+const maxPosition = 4 << 10
+
+// Entry is one connection's presence on a document.
+type Entry struct {
+	Session   string          `json:"session"`
+	UserID    int32           `json:"user_id"`
+	State     string          `json:"state"`
+	Cursor    json.RawMessage `json:"cursor,omitempty"`
+	Selection json.RawMessage `json:"selection,omitempty"`
+	SeenAt    time.Time       `json:"seen_at"`
+}
+
+// Event is published to a document's presence topic. Reason is only set on leave events.
+type Event struct {
+	Event  string `json:"event"`
+	Reason string `json:"reason,omitempty"`
+	Entry
+}
+
+// update is the payload of a presence message.
+type update struct {
+	State     string          `json:"state"`
+	Cursor    json.RawMessage `json:"cursor"`
+	Selection json.RawMessage `json:"selection"`
+}
+
+// Topic is the hub topic with the presence events of a document.
+func Topic(tenantID, documentID int32) string {
+	return fmt.Sprintf("tenants.%d.docs.%d.presence", tenantID, documentID)
+}
+
+// Tracker keeps the presence of every document. Entries of this node's connections expire when
+// they send no presence message for the idle timeout; entries of other nodes are forgotten after
+// twice that, in case their node went away without announcing it.
+type Tracker struct {
+	hub  *ws.Hub
+	idle time.Duration
+
+	mu sync.Mutex
+	// documents holds the entries of each presence topic by session
+	documents map[string]map[string]Entry
+	// local holds the presence topics of this node's sessions
+	local *ws.Members
+
+	log *slog.Logger
+}
+
+// NewTracker creates a Tracker and registers its message types and hooks on hub.
+func NewTracker(hub *ws.Hub, idle time.Duration) *Tracker {
+	t := &Tracker{
+		hub:       hub,
+		idle:      idle,
+		documents: map[string]map[string]Entry{},
+		local:     ws.NewMembers(),
+		log:       logging.Component("presence"),
+	}
+	hub.Handle(TypePresence, t.handlePresence)
+	hub.Handle(TypeLeave, t.handleLeave)
+	hub.OnClose(t.disconnected)
+	hub.Observe("tenants.*.docs.*.presence", t.apply)
+	return t
+}
+
+// List returns the sessions on a document, ordered by session ID.
+func (t *Tracker) List(tenantID, documentID int32) []Entry {
+	return t.entries(Topic(tenantID, documentID))
+}
+
+func (t *Tracker) entries(topic string) []Entry {
+	t.mu.Lock()
+	out := make([]Entry, 0, len(t.documents[topic]))
+	for _, e := range t.documents[topic] {
+		out = append(out, e)
+	}
+	t.mu.Unlock()
+	slices.SortFunc(out, func(a, b Entry) int { return strings.Compare(a.Session, b.Session) })
+	return out
+}
+
+// Run expires idle sessions until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.idle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			t.expire(now)
+		}
+	}
+}
+
+func (t *Tracker) handlePresence(conn ws.Conn, msg ws.Message) {
+	if !t.allowed(conn, msg.Topic) {
+		conn.Reply(msg, ws.TypeError, "Not allowed to join this document")
+		return
+	}
+	var u update
+	if err := ws.Decode(msg.Payload, &u); err != nil {
+		conn.Reply(msg, ws.TypeError, "Invalid presence: "+err.Error())
+		return
+	}
+	if u.State == "" {
+		u.State = Viewing
+	}
+	if u.State != Viewing && u.State != Editing {
+		conn.Reply(msg, ws.TypeError, "Invalid presence: state must be viewing or editing")
+		return
+	}
+	if len(u.Cursor) > maxPosition || len(u.Selection) > maxPosition {
+		conn.Reply(msg, ws.TypeError, "Invalid presence: cursor or selection too large")
+		return
+	}
+
+	event := Update
+	if t.local.Join(conn, msg.Topic) {
+		event = Join
+	}
+
+	t.hub.Publish(msg.Topic, Event{Event: event, Entry: Entry{
+		Session:   conn.ID(),
+		UserID:    conn.Principal().UserID,
+		State:     u.State,
+		Cursor:    u.Cursor,
+		Selection: u.Selection,
+		SeenAt:    time.Now().UTC(),
+	}})
+	// the publish above has already been applied, so the snapshot includes the caller
+	conn.Reply(msg, ws.TypeConfirmation, t.entries(msg.Topic))
+}
+
+func (t *Tracker) handleLeave(conn ws.Conn, msg ws.Message) {
+	t.leave(conn.ID(), conn.Principal().UserID, []string{msg.Topic}, ReasonLeft)
+	conn.Reply(msg, ws.TypeConfirmation, "Left successfully")
+}
+
+func (t *Tracker) disconnected(conn ws.Conn) {
+	for _, topic := range t.local.LeaveAll(conn.ID()) {
+		t.announceLeave(conn.ID(), conn.Principal().UserID, topic, ReasonDisconnected)
+	}
+}
+
+// leave publishes leave events for the topics the local session is still on.
+func (t *Tracker) leave(session string, userID int32, topics []string, reason string) {
+	for _, topic := range topics {
+		if t.local.Leave(session, topic) {
+			t.announceLeave(session, userID, topic, reason)
+		}
+	}
+}
+
+func (t *Tracker) announceLeave(session string, userID int32, topic, reason string) {
+	t.hub.Publish(topic, Event{Event: Leave, Reason: reason, Entry: Entry{
+		Session: session,
+		UserID:  userID,
+		SeenAt:  time.Now().UTC(),
+	}})
+}
+
+// expire publishes leave events for idle local sessions and forgets stale remote ones.
+func (t *Tracker) expire(now time.Time) {
+	type idle struct {
+		session, topic string
+		userID         int32
+	}
+	var expired []idle
+	t.mu.Lock()
+	for topic, sessions := range t.documents {
+		for session, e := range sessions {
+			_, local := t.local.Conn(session, topic)
+			switch {
+			case local && now.Sub(e.SeenAt) > t.idle:
+				expired = append(expired, idle{session, topic, e.UserID})
+			case !local && now.Sub(e.SeenAt) > 2*t.idle:
+				delete(sessions, session)
+			}
+		}
+		if len(sessions) == 0 {
+			delete(t.documents, topic)
+		}
+	}
+	t.mu.Unlock()
+	for _, e := range expired {
+		t.leave(e.session, e.userID, []string{e.topic}, ReasonIdle)
+	}
+}
+
+// apply folds a published presence event into the documents.
+func (t *Tracker) apply(topic string, data []byte) {
+	var msg struct {
+		Payload Event `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.log.Warn("Ignoring undecodable presence event", "topic", topic, "error", err)
+		return
+	}
+	e := msg.Payload
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sessions := t.documents[topic]
+	switch e.Event {
+	case Join, Update:
+		if sessions == nil {
+			sessions = map[string]Entry{}
+			t.documents[topic] = sessions
+		}
+		sessions[e.Session] = e.Entry
+	case Leave:
+		delete(sessions, e.Session)
+		if len(sessions) == 0 {
+			delete(t.documents, topic)
+		}
+	}
+}
+
+// allowed reports whether topic is a document's presence topic the connection may see.
+func (t *Tracker) allowed(conn ws.Conn, topic string) bool {
+	segments := strings.Split(topic, ".")
+	if len(segments) != 5 || segments[0] != "tenants" || segments[2] != "docs" || segments[4] != "presence" {
+		return false
+	}
+	if _, err := strconv.ParseInt(segments[3], 10, 32); err != nil {
+		return false
+	}
+	return conn.Can(ws.Subscribe, topic)
+}
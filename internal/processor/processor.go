@@ -0,0 +1,208 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wilbyang/law-docs/internal/alerting"
+	"github.com/wilbyang/law-docs/internal/audit"
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/models"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/tracing"
+	"github.com/wilbyang/law-docs/internal/workflow"
+)
+
+// Processor consumes upload notifications and pre-processes the referenced documents.
+type Processor struct {
+	store   *tenant.Store
+	events  Publisher
+	scanner Scanner
+	log     *slog.Logger
+}
+
+// Publisher records pipeline events, such as a streams.Producer.
+type Publisher interface {
+	Publish(ctx context.Context, eventType string, data any) (string, error)
+}
+
+// Scanner checks uploaded files for malware, such as a services.Clamd.
+type Scanner interface {
+	// Scan returns the malware found in filePath, stored under prefix, or "" if it is clean.
+	Scan(ctx context.Context, prefix, filePath string) (string, error)
+}
+
+// InfectedError is returned for a document whose file the Scanner found infected.
+type InfectedError struct {
+	Finding string
+}
+
+func (e *InfectedError) Error() string {
+	return "uploaded file is infected: " + e.Finding
+}
+
+// New creates a Processor. If events is not nil, the outcome of every document is published to
+// it as an alerting.ProcessingSucceeded, ProcessingFailed or UploadInfected event. If scanner is
+// not nil, uploaded files are scanned before they are processed.
+func New(store *tenant.Store, events Publisher, scanner Scanner) *Processor {
+	gofakeit.Seed(time.Now().UnixNano())
+	return &Processor{store: store, events: events, scanner: scanner, log: logging.Component("processor")}
+}
+
+// HandleMessage decodes an SQS notification body and processes the document it refers to.
+func (p *Processor) HandleMessage(ctx context.Context, message string) error {
+	p.log.InfoContext(ctx, "Received message", "message", message)
+	start := time.Now()
+	var notification models.Notification
+	err := json.Unmarshal([]byte(message), &notification)
+	metrics.ObserveStage("decode", start, err)
+	if err != nil {
+		p.log.ErrorContext(ctx, "Failed to unmarshal message", "error", err)
+		return err
+	}
+	if notification.TenantID == 0 {
+		// queued before multi-tenancy, when every document belonged to the default tenant
+		notification.TenantID = tenant.DefaultID
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("document.id", int(notification.DocID)),
+		attribute.Int("tenant.id", int(notification.TenantID)),
+	)
+	ctx = logging.WithDocumentID(ctx, notification.DocID)
+	ctx = tenant.WithID(ctx, notification.TenantID)
+	err = p.processDocument(ctx, notification)
+	p.record(ctx, notification, err)
+	var infected *InfectedError
+	if errors.As(err, &infected) {
+		// scanning again would find the same; the document stays a draft without content
+		p.log.WarnContext(ctx, "Not processing infected upload", "finding", infected.Finding)
+		return nil
+	}
+	if err != nil {
+		p.log.ErrorContext(ctx, "Failed to process document", "error", err)
+		return err
+	}
+	return nil
+}
+
+// record publishes the outcome of processing a document. Alerting is not worth failing the
+// document for, so errors are only logged.
+func (p *Processor) record(ctx context.Context, notification models.Notification, err error) {
+	if p.events == nil {
+		return
+	}
+	event := alerting.Event{TenantID: notification.TenantID, DocumentID: notification.DocID}
+	eventType := alerting.ProcessingSucceeded
+	var infected *InfectedError
+	switch {
+	case errors.As(err, &infected):
+		eventType, event.Detail = alerting.UploadInfected, infected.Finding
+	case err != nil:
+		eventType, event.Detail = alerting.ProcessingFailed, err.Error()
+	}
+	if _, err := p.events.Publish(ctx, eventType, event); err != nil {
+		p.log.WarnContext(ctx, "Failed to publish pipeline event", "type", eventType, "error", err)
+	}
+}
+
+// ErrChanged is returned when a document was changed while it was being processed. The message
+// is left on the queue, so the document is processed again as it is now.
+var ErrChanged = errors.New("document changed while it was processed")
+
+func (p *Processor) processDocument(ctx context.Context, notification models.Notification) error {
+	start := time.Now()
+	stageCtx, span := tracing.Start(ctx, "processor.fetch")
+	var doc repository.Document
+	err := p.store.Tx(stageCtx, func(q *repository.Queries) error {
+		var err error
+		doc, err = q.GetDocumentById(stageCtx, notification.DocID)
+		return err
+	})
+	if err == nil {
+		// fail before extracting from a document that cannot be pre-processed
+		_, err = workflow.Next(stageCtx, doc.Status.String, workflow.EventPreprocess)
+	}
+	tracing.End(span, err)
+	metrics.ObserveStage("fetch", start, err)
+	if err != nil {
+		return err
+	}
+
+	if p.scanner != nil && doc.FilePath.Valid {
+		start = time.Now()
+		stageCtx, span = tracing.Start(ctx, "processor.scan")
+		var finding string
+		finding, err = p.scanner.Scan(stageCtx, tenant.StoragePrefix(notification.TenantID), doc.FilePath.String)
+		if err == nil && finding != "" {
+			err = &InfectedError{Finding: finding}
+		}
+		tracing.End(span, err)
+		metrics.ObserveStage("scan", start, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	start = time.Now()
+	_, span = tracing.Start(ctx, "processor.extract")
+	//sleep random 1-10 seconds
+	time.Sleep(time.Duration(gofakeit.IntRange(1, 10)) * time.Second)
+	title := gofakeit.Name()
+	content := gofakeit.Paragraph(10, 10, 10, " ")
+	meta := models.Meta{
+		Key:   gofakeit.Name(),
+		Value: gofakeit.Name(),
+	}
+	tracing.End(span, nil)
+	metrics.ObserveStage("extract", start, nil)
+
+	start = time.Now()
+	stageCtx, span = tracing.Start(ctx, "processor.update")
+	err = p.store.Tx(stageCtx, func(q *repository.Queries) error {
+		// the extraction took long enough for an edit to land in between; lock the row and
+		// only write over the version that was extracted from
+		current, err := q.GetDocumentByIdForUpdate(stageCtx, doc.ID)
+		if err != nil {
+			return err
+		}
+		if !current.UpdatedAt.Time.Equal(doc.UpdatedAt.Time) {
+			return ErrChanged
+		}
+		status, err := workflow.Next(stageCtx, current.Status.String, workflow.EventPreprocess)
+		if err != nil {
+			return err
+		}
+		updated, err := q.UpdateDocument(stageCtx, repository.UpdateDocumentParams{
+			ID:        current.ID,
+			Title:     title,
+			Content:   content,
+			DocSize:   current.DocSize,
+			UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			Meta:      meta,
+			Status:    pgtype.Text{String: status, Valid: true},
+			FilePath:  current.FilePath,
+		})
+		if err != nil {
+			return err
+		}
+		return audit.Record(stageCtx, q, audit.Event{
+			Action:     audit.ActionTransition,
+			DocumentID: current.ID,
+			Before:     current,
+			After:      updated,
+		})
+	})
+	tracing.End(span, err)
+	metrics.ObserveStage("update", start, err)
+	return err
+}
@@ -1,98 +1,150 @@
 package services
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
+	"math/rand/v2"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
-)
-
-// TODO: read from config
-const (
-	QueueURL = "http://sqs.eu-west-1.localhost.localstack.cloud:4566/000000000000/my-queue"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Notifier struct {
 	SQSClient *sqs.Client
+	QueueURL  string
+	// VisibilityTimeout hides a message from other receivers once its processing starts, 1m by
+	// default. It must exceed the longest processing time, or the message is received again
+	// while it is still in flight.
+	VisibilityTimeout time.Duration
+	// WaitTime is how long a receive waits for a message on an empty queue, 20s by default,
+	// the longest SQS allows.
+	WaitTime time.Duration
+	log      *slog.Logger
 }
 
-func NewNotifier(ctx context.Context, cfg aws.Config) (*Notifier, error) {
+// Delays between failed receives, doubling from minReceiveBackoff up to maxReceiveBackoff.
+const (
+	minReceiveBackoff = time.Second
+	maxReceiveBackoff = time.Minute
+)
+
+func NewNotifier(ctx context.Context, cfg aws.Config, queueURL string) (*Notifier, error) {
 	sqsClient := sqs.NewFromConfig(cfg)
-	return &Notifier{SQSClient: sqsClient}, nil
+	return &Notifier{SQSClient: sqsClient, QueueURL: queueURL, log: logging.Component("sqs")}, nil
 }
 
+// SendMessage sends message to the queue, carrying the trace context of ctx in the message attributes.
 func (notifier *Notifier) SendMessage(ctx context.Context, message string) error {
-	queueURL, err := notifier.getQueueURL(ctx, QueueURL)
-	if err != nil {
-		return err
-	}
-	_, err = notifier.SQSClient.SendMessage(ctx, &sqs.SendMessageInput{
-		MessageBody: aws.String(message),
-		QueueUrl:    aws.String(queueURL),
+	attributes := tracing.SQSCarrier{}
+	tracing.Inject(ctx, attributes)
+	_, err := notifier.SQSClient.SendMessage(ctx, &sqs.SendMessageInput{
+		MessageBody:       aws.String(message),
+		MessageAttributes: attributes,
+		QueueUrl:          aws.String(notifier.QueueURL),
 	})
 	return err
 }
 
-func (notifier *Notifier) getQueueURL(ctx context.Context, queueName string) (string, error) {
-	return QueueURL, nil
-
-	result, err := notifier.SQSClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
-		QueueName: &queueName,
-	})
-	if err != nil {
-		return "", err
-	}
-	return *result.QueueUrl, nil
-}
-
-// ReceiveMessage receives a message from the queue and processes it, it is blocking, use it in a separate goroutine
-func (notifier *Notifier) ReceiveMessage(processor func(message string) error) {
-	queueURL, err := notifier.getQueueURL(context.TODO(), QueueURL)
-	if err != nil {
-		slog.Error("Failed to get queue URL, exiting sqs listener", "error", err)
-		return
-	}
-	for {
-		msg, err := notifier.SQSClient.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
-			QueueUrl: aws.String(queueURL),
+// ReceiveMessage receives messages from the queue and processes them until ctx is cancelled.
+// It is blocking, use it in a separate goroutine. A message that is already being processed when
+// ctx is cancelled is allowed to finish; messages not yet started are left on the queue. A message
+// that fails is left on the queue too, so it is received again once its visibility timeout ends
+// and the queue's redrive policy moves it to the dead-letter queue after too many attempts.
+// Receives wait up to WaitTime for a message, and failed ones are retried after a growing delay.
+func (notifier *Notifier) ReceiveMessage(ctx context.Context, processor func(ctx context.Context, message string) error) {
+	queueURL := notifier.QueueURL
+	visibility := cmp.Or(notifier.VisibilityTimeout, time.Minute)
+	wait := cmp.Or(notifier.WaitTime, 20*time.Second)
+	// in-flight work must outlive the receive loop so it can be drained on shutdown
+	workCtx := context.WithoutCancel(ctx)
+	backoff := minReceiveBackoff
+	for ctx.Err() == nil {
+		msg, err := notifier.SQSClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(queueURL),
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameSentTimestamp},
+			MessageAttributeNames:       []string{"All"},
+			WaitTimeSeconds:             int32(wait / time.Second),
 		})
 		if err != nil {
-			slog.Error("Failed to receive message", "error", err)
+			if ctx.Err() != nil {
+				return
+			}
+			// jitter keeps workers from retrying in lockstep once the queue is back
+			delay := backoff/2 + rand.N(backoff)
+			notifier.log.ErrorContext(ctx, "Failed to receive message, retrying", "error", err, "delay", delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			backoff = min(2*backoff, maxReceiveBackoff)
 			continue
 		}
+		backoff = minReceiveBackoff
 		for _, message := range msg.Messages {
+			if ctx.Err() != nil {
+				return
+			}
+			observeQueueLag(message)
 
-			// set visibility timeout to 10 seconds
-			//TODO: read from config
-			_, err := notifier.SQSClient.ChangeMessageVisibility(context.TODO(), &sqs.ChangeMessageVisibilityInput{
+			// the receive made the whole batch invisible; restart the clock for this message
+			_, err := notifier.SQSClient.ChangeMessageVisibility(workCtx, &sqs.ChangeMessageVisibilityInput{
 				QueueUrl:          aws.String(queueURL),
 				ReceiptHandle:     message.ReceiptHandle,
-				VisibilityTimeout: 10,
+				VisibilityTimeout: int32(visibility / time.Second),
 			})
 			if err != nil {
-				slog.Error("Failed to change message visibility", "error", err)
+				notifier.log.ErrorContext(workCtx, "Failed to change message visibility", "error", err)
 				continue
 			}
 
-			err = processor(*message.Body)
+			msgCtx, span := tracing.Start(tracing.Extract(workCtx, tracing.SQSCarrier(message.MessageAttributes)), "sqs.process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					semconv.MessagingSystemAWSSqs,
+					semconv.MessagingOperationTypeDeliver,
+					semconv.MessagingMessageID(aws.ToString(message.MessageId)),
+				))
+			err = processor(msgCtx, *message.Body)
+			tracing.End(span, err)
 			if err != nil {
-				slog.Error("Failed to process message", "error", err)
+				notifier.log.ErrorContext(msgCtx, "Failed to process message, leaving it on the queue", "error", err)
+				continue
 			}
 			// delete message from queue
-			_, err = notifier.SQSClient.DeleteMessage(context.TODO(), &sqs.DeleteMessageInput{
+			_, err = notifier.SQSClient.DeleteMessage(workCtx, &sqs.DeleteMessageInput{
 				QueueUrl:      aws.String(queueURL),
 				ReceiptHandle: message.ReceiptHandle,
 			})
 			if err != nil {
-				slog.Error("Failed to delete message", "error", err)
+				notifier.log.ErrorContext(msgCtx, "Failed to delete message", "error", err)
 			}
 		}
+	}
+}
 
-		// sleep for 1 second
-		//TODO: read from config
-		time.Sleep(1 * time.Second)
+// Ping checks that the queue exists and is reachable.
+func (notifier *Notifier) Ping(ctx context.Context) error {
+	_, err := notifier.SQSClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(notifier.QueueURL),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	return err
+}
 
+func observeQueueLag(message types.Message) {
+	sent, err := strconv.ParseInt(message.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64)
+	if err != nil {
+		return
 	}
+	metrics.QueueLag.Observe(time.Since(time.UnixMilli(sent)).Seconds())
 }
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/tracing"
+)
+
+// fakeQueue answers the SQS JSON protocol for one queue: sent messages are received once.
+type fakeQueue struct {
+	mu      sync.Mutex
+	pending []map[string]any
+	deleted []string
+	// wait and visibility are the WaitTimeSeconds and VisibilityTimeout of the last requests
+	wait, visibility any
+	// failing makes every request fail with a non-retryable error, counting them in receives
+	failing  bool
+	receives int
+}
+
+func (q *fakeQueue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var in map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failing {
+		q.receives++
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.sqs#QueueDoesNotExist", "message": "no queue"})
+		return
+	}
+	out := map[string]any{}
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonSQS.") {
+	case "SendMessage":
+		id := strings.Repeat("1", len(q.pending)+1)
+		q.pending = append(q.pending, map[string]any{
+			"MessageId":         id,
+			"ReceiptHandle":     "receipt-" + id,
+			"Body":              in["MessageBody"],
+			"MessageAttributes": in["MessageAttributes"],
+			"Attributes":        map[string]string{"SentTimestamp": "1767225600000"},
+		})
+		out["MessageId"] = id
+	case "ReceiveMessage":
+		q.wait = in["WaitTimeSeconds"]
+		out["Messages"] = q.pending
+		q.pending = nil
+	case "DeleteMessage":
+		q.deleted = append(q.deleted, in["ReceiptHandle"].(string))
+	case "ChangeMessageVisibility":
+		q.visibility = in["VisibilityTimeout"]
+	default:
+		http.Error(w, "unexpected action "+r.Header.Get("X-Amz-Target"), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestNotifierPropagatesTraceContext(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	defer provider.Shutdown(context.Background())
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	queue := &fakeQueue{}
+	srv := httptest.NewServer(queue)
+	defer srv.Close()
+	notifier := &Notifier{
+		SQSClient: sqs.NewFromConfig(aws.Config{
+			Region:       "us-east-1",
+			Credentials:  aws.AnonymousCredentials{},
+			BaseEndpoint: aws.String(srv.URL),
+		}, func(o *sqs.Options) { o.DisableMessageChecksumValidation = true }),
+		QueueURL: srv.URL + "/000000000000/documents",
+		log:      logging.Component("sqs"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	uploadCtx, upload := tracing.Start(ctx, "upload")
+	if err := notifier.SendMessage(uploadCtx, `{"doc_id": 42}`); err != nil {
+		t.Fatal(err)
+	}
+	upload.End()
+
+	var processed trace.SpanContext
+	notifier.ReceiveMessage(ctx, func(msgCtx context.Context, message string) error {
+		processed = trace.SpanContextFromContext(msgCtx)
+		cancel()
+		return nil
+	})
+
+	parent := upload.SpanContext()
+	if processed.TraceID() != parent.TraceID() {
+		t.Fatalf("processor runs in trace %s, want the sender's %s", processed.TraceID(), parent.TraceID())
+	}
+	var process sdktrace.ReadOnlySpan
+	for _, s := range spans.Ended() {
+		if s.Name() == "sqs.process" {
+			process = s
+		}
+	}
+	if process == nil {
+		t.Fatal("no sqs.process span ended")
+	}
+	if process.SpanContext().SpanID() != processed.SpanID() {
+		t.Errorf("processor context carries span %s, want sqs.process %s", processed.SpanID(), process.SpanContext().SpanID())
+	}
+	if got := process.Parent().SpanID(); got != parent.SpanID() || !process.Parent().IsRemote() {
+		t.Errorf("sqs.process has parent %s (remote %v), want the remote upload span %s", got, process.Parent().IsRemote(), parent.SpanID())
+	}
+	if len(queue.deleted) != 1 {
+		t.Errorf("deleted %v, want the processed message", queue.deleted)
+	}
+	if queue.wait != float64(20) || queue.visibility != float64(60) {
+		t.Errorf("received with a wait of %v and visibility of %v, want 20s long polls and 60s", queue.wait, queue.visibility)
+	}
+}
+
+func TestNotifierBacksOff(t *testing.T) {
+	queue := &fakeQueue{failing: true}
+	srv := httptest.NewServer(queue)
+	defer srv.Close()
+	notifier := &Notifier{
+		SQSClient: sqs.NewFromConfig(aws.Config{
+			Region:       "us-east-1",
+			Credentials:  aws.AnonymousCredentials{},
+			BaseEndpoint: aws.String(srv.URL),
+		}),
+		QueueURL: srv.URL + "/000000000000/documents",
+		log:      logging.Component("sqs"),
+	}
+
+	// the first retry comes after at least half of minReceiveBackoff
+	ctx, cancel := context.WithTimeout(context.Background(), minReceiveBackoff/3)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notifier.ReceiveMessage(ctx, func(context.Context, string) error {
+			t.Error("processed a message of a failing queue")
+			return nil
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ReceiveMessage kept waiting after ctx was cancelled")
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.receives != 1 {
+		t.Errorf("received %d times before the backoff ended, want 1", queue.receives)
+	}
+}
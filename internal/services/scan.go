@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Clamd scans uploaded files for malware with a ClamAV daemon.
+type Clamd struct {
+	// Addr is the TCP address of clamd, e.g. localhost:3310.
+	Addr  string
+	Files *S3Uploader
+}
+
+// Scan returns the name of the malware found in filePath, which must be under prefix, or ""
+// if the file is clean.
+func (c *Clamd) Scan(ctx context.Context, prefix, filePath string) (string, error) {
+	body, err := c.Files.Open(ctx, prefix, filePath)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return c.ScanReader(ctx, body)
+}
+
+// ScanReader streams r to clamd with the INSTREAM command and returns the name of the malware
+// found, or "" if r is clean.
+func (c *Clamd) ScanReader(ctx context.Context, r io.Reader) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	// the stream is sent as chunks prefixed with their length and ends with an empty chunk
+	chunk := make([]byte, 32<<10)
+	var size [4]byte
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			w.Write(size[:])
+			w.Write(chunk[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	// "stream: OK", "stream: Eicar-Test-Signature FOUND" or an error such as
+	// "INSTREAM size limit exceeded. ERROR"
+	result := strings.TrimPrefix(strings.TrimSuffix(reply, "\x00"), "stream: ")
+	if result == "OK" {
+		return "", nil
+	}
+	if found, ok := strings.CutSuffix(result, " FOUND"); ok {
+		return found, nil
+	}
+	return "", fmt.Errorf("clamd: %s", result)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd answers INSTREAM commands, reporting streams that contain signature as infected.
+func fakeClamd(t *testing.T, signature string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(data.String(), signature) {
+					io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+				} else {
+					io.WriteString(conn, "stream: OK\x00")
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestClamd(t *testing.T) {
+	const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	c := &Clamd{Addr: fakeClamd(t, eicar)}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// larger than one chunk, so the signature straddles two
+	clean := strings.Repeat("a", 40<<10)
+	if finding, err := c.ScanReader(ctx, strings.NewReader(clean)); err != nil || finding != "" {
+		t.Errorf("clean file: got %q (%v), want no finding", finding, err)
+	}
+	infected := strings.Repeat("a", 32<<10-10) + eicar
+	if finding, err := c.ScanReader(ctx, strings.NewReader(infected)); err != nil || finding != "Eicar-Test-Signature" {
+		t.Errorf("infected file: got %q (%v), want the signature", finding, err)
+	}
+	if finding, err := c.ScanReader(ctx, strings.NewReader("")); err != nil || finding != "" {
+		t.Errorf("empty file: got %q (%v), want no finding", finding, err)
+	}
+}
+
+func TestClamdError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// the command, the chunk of "data" and the final empty chunk
+		io.CopyN(io.Discard, conn, 10+4+4+4)
+		io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+	}()
+	c := &Clamd{Addr: l.Addr().String()}
+	_, err = c.ScanReader(context.Background(), strings.NewReader("data"))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("got %v, want clamd's error", err)
+	}
+}
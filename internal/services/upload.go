@@ -2,9 +2,14 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"log/slog"
 	"mime/multipart"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -15,25 +20,94 @@ type S3Uploader struct {
 	Bucket   string
 }
 
-func NewS3Uploader(ctx context.Context, cfg aws.Config, bucketName string) (*S3Uploader, error) {
-	s3Client := s3.NewFromConfig(cfg)
+func NewS3Uploader(ctx context.Context, cfg aws.Config, bucketName string, optFns ...func(*s3.Options)) (*S3Uploader, error) {
+	s3Client := s3.NewFromConfig(cfg, optFns...)
 	return &S3Uploader{S3Client: s3Client, Bucket: bucketName}, nil
 }
 
-func (uploader *S3Uploader) UploadFile(fileHeader *multipart.FileHeader) (string, error) {
+// UploadFile stores the file under prefix and returns its s3:// path. Every upload gets its own
+// key, <prefix><random ID>/<file name>, so files uploaded under the same name never replace
+// each other.
+func (uploader *S3Uploader) UploadFile(ctx context.Context, prefix string, fileHeader *multipart.FileHeader) (string, error) {
 	file, err := fileHeader.Open()
 	if err != nil {
 		return "", err
 	}
-	_, err = uploader.S3Client.PutObject(context.Background(), &s3.PutObjectInput{
+	defer file.Close()
+	key := prefix + rand.Text() + "/" + path.Base(fileHeader.Filename)
+	_, err = uploader.S3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(uploader.Bucket),
-		Key:    aws.String(fileHeader.Filename),
+		Key:    aws.String(key),
 		Body:   file,
 	})
 	if err != nil {
-		slog.Error("Failed to upload file", "error", err, "filePath", fmt.Sprintf("s3://%s/%s", uploader.Bucket, fileHeader.Filename))
+		slog.ErrorContext(ctx, "Failed to upload file", "component", "s3", "error", err, "filePath", fmt.Sprintf("s3://%s/%s", uploader.Bucket, key))
 		return "", err
 	}
 
-	return fmt.Sprintf("s3://%s/%s", uploader.Bucket, fileHeader.Filename), nil
+	return fmt.Sprintf("s3://%s/%s", uploader.Bucket, key), nil
+}
+
+// Ping checks that the bucket exists and is accessible.
+func (uploader *S3Uploader) Ping(ctx context.Context) error {
+	_, err := uploader.S3Client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(uploader.Bucket),
+	})
+	return err
+}
+
+// DeleteFile removes an object previously returned by UploadFile with the same prefix.
+// Paths outside prefix are refused so one tenant can never delete another's files.
+func (uploader *S3Uploader) DeleteFile(ctx context.Context, prefix, filePath string) error {
+	key, err := uploader.key(prefix, filePath)
+	if err != nil {
+		return err
+	}
+	_, err = uploader.S3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(uploader.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// Open returns the contents of filePath. Like DeleteFile, it refuses paths outside prefix.
+func (uploader *S3Uploader) Open(ctx context.Context, prefix, filePath string) (io.ReadCloser, error) {
+	key, err := uploader.key(prefix, filePath)
+	if err != nil {
+		return nil, err
+	}
+	out, err := uploader.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(uploader.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// PresignGet returns a URL that downloads filePath without credentials until ttl elapses.
+// Like DeleteFile, it refuses paths outside prefix.
+func (uploader *S3Uploader) PresignGet(ctx context.Context, prefix, filePath string, ttl time.Duration) (string, error) {
+	key, err := uploader.key(prefix, filePath)
+	if err != nil {
+		return "", err
+	}
+	req, err := s3.NewPresignClient(uploader.S3Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(uploader.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// key returns the object key of an s3:// path returned by UploadFile, checking it is under prefix.
+func (uploader *S3Uploader) key(prefix, filePath string) (string, error) {
+	key, ok := strings.CutPrefix(filePath, fmt.Sprintf("s3://%s/", uploader.Bucket))
+	if !ok || !strings.HasPrefix(key, prefix) {
+		return "", fmt.Errorf("file %q is not under s3://%s/%s", filePath, uploader.Bucket, prefix)
+	}
+	return key, nil
 }
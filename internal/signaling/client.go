@@ -0,0 +1,181 @@
+package signaling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+// Client is a websocket connection to the hub that joins rooms and exchanges signals, for peers
+// written in Go such as lawdocs p2p. Requests wait for their reply; joins, leaves and
+// signals of the joined rooms arrive on Events.
+type Client struct {
+	conn     *websocket.Conn
+	session  string
+	tenantID int32
+
+	ids     atomic.Uint64
+	mu      sync.Mutex
+	pending map[string]chan ws.Message
+
+	events chan Event
+	done   chan struct{}
+	err    error
+}
+
+// Dial connects to the hub at url, a ws:// or wss:// URL of /ws, with a tenant API token, and
+// waits for the welcome message.
+func Dial(ctx context.Context, url, token string) (*Client, error) {
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": {"Bearer " + token}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", url, err)
+	}
+	var welcome struct {
+		Type    string `json:"type"`
+		ID      string `json:"id"`
+		Payload struct {
+			TenantID int32 `json:"tenant_id"`
+		} `json:"payload"`
+	}
+	if err := wsjson.Read(ctx, conn, &welcome); err != nil {
+		conn.CloseNow()
+		return nil, fmt.Errorf("unable to read welcome message: %w", err)
+	}
+	if welcome.Type != ws.TypeWelcome {
+		conn.CloseNow()
+		return nil, fmt.Errorf("expected a welcome message, got %q", welcome.Type)
+	}
+	c := &Client{
+		conn:     conn,
+		session:  welcome.ID,
+		tenantID: welcome.Payload.TenantID,
+		pending:  map[string]chan ws.Message{},
+		events:   make(chan Event, 64),
+		done:     make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Session returns the session ID of the connection, which peers send signals to.
+func (c *Client) Session() string {
+	return c.session
+}
+
+// TenantID returns the tenant the token belongs to.
+func (c *Client) TenantID() int32 {
+	return c.tenantID
+}
+
+// Events returns the events of the joined rooms. It is closed when the connection ends. Replies
+// are read from the same connection, so a client must keep receiving events while it waits.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Join joins the room of the client's tenant and returns the peers in it, the client included.
+func (c *Client) Join(ctx context.Context, room string) ([]Peer, error) {
+	reply, err := c.request(ctx, ws.Message{Type: TypeJoin, Topic: Topic(c.tenantID, room)})
+	if err != nil {
+		return nil, err
+	}
+	var peers []Peer
+	if err := ws.Decode(reply.Payload, &peers); err != nil {
+		return nil, fmt.Errorf("invalid join confirmation: %w", err)
+	}
+	return peers, nil
+}
+
+// Leave leaves a room.
+func (c *Client) Leave(ctx context.Context, room string) error {
+	_, err := c.request(ctx, ws.Message{Type: TypeLeave, Topic: Topic(c.tenantID, room)})
+	return err
+}
+
+// Signal relays sig to the session sig.To in a joined room.
+func (c *Client) Signal(ctx context.Context, room string, sig Signal) error {
+	_, err := c.request(ctx, ws.Message{Type: TypeSignal, Topic: Topic(c.tenantID, room), Payload: sig})
+	return err
+}
+
+// Close closes the connection, which leaves every room.
+func (c *Client) Close() error {
+	err := c.conn.Close(websocket.StatusNormalClosure, "")
+	<-c.done
+	return err
+}
+
+// request sends msg and waits for its confirmation, turning an error reply into an error.
+func (c *Client) request(ctx context.Context, msg ws.Message) (ws.Message, error) {
+	msg.ID = strconv.FormatUint(c.ids.Add(1), 10)
+	reply := make(chan ws.Message, 1)
+	c.mu.Lock()
+	c.pending[msg.ID] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+	}()
+
+	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
+		return ws.Message{}, err
+	}
+	select {
+	case r := <-reply:
+		if r.Type == ws.TypeError {
+			return r, fmt.Errorf("%s refused: %v", msg.Type, r.Payload)
+		}
+		return r, nil
+	case <-c.done:
+		return ws.Message{}, c.err
+	case <-ctx.Done():
+		return ws.Message{}, ctx.Err()
+	}
+}
+
+// readLoop hands replies to their request and room events to Events until the connection ends.
+func (c *Client) readLoop() {
+	defer close(c.events)
+	defer close(c.done)
+	for {
+		var msg ws.Message
+		if err := wsjson.Read(context.Background(), c.conn, &msg); err != nil {
+			c.err = fmt.Errorf("connection to the hub ended: %w", err)
+			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+				c.err = errors.New("connection to the hub closed")
+			}
+			return
+		}
+		switch msg.Type {
+		case ws.TypeConfirmation, ws.TypeError:
+			c.mu.Lock()
+			reply, ok := c.pending[msg.ID]
+			c.mu.Unlock()
+			if ok {
+				reply <- msg
+			}
+		case TypePeer:
+			var e Event
+			if ws.Decode(msg.Payload, &e) == nil {
+				c.events <- e
+			}
+		case TypeSignal:
+			var sig Signal
+			if ws.Decode(msg.Payload, &sig) == nil {
+				c.events <- Event{Event: Signalled, Peer: Peer{Session: sig.From}, Signal: &sig}
+			}
+		}
+	}
+}
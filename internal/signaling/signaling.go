@@ -0,0 +1,336 @@
+// Package signaling lets websocket clients set up WebRTC connections with each other over the
+// hub. Clients join a room, learn who else is in it and relay offers, answers and ICE candidates
+// to one peer at a time. Everything is published to the room's topic, which clients cannot
+// subscribe to, and every node hands the messages for its own sessions to them, so the peers of a
+// room may be connected to different nodes of a cluster. Nodes re-announce their sessions
+// periodically and when another node starts, so that every node knows every room, and forget
+// the sessions of a node that stopped announcing them.
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+// Message types handled on the hub. Join and leave name the room by their topic; a signal also
+// names the session it is for. The hub sends TypePeer when a session joins or leaves one of the
+// client's rooms and TypeSignal with the signals for the client.
+const (
+	TypeJoin   = "rtc.join"
+	TypeLeave  = "rtc.leave"
+	TypeSignal = "rtc.signal"
+	TypePeer   = "rtc.peer"
+)
+
+// Kinds of signals.
+const (
+	Offer     = "offer"
+	Answer    = "answer"
+	Candidate = "candidate"
+)
+
+// Room events. Signalled events carry a signal and are only handed to its recipient.
+const (
+	Join      = "join"
+	Leave     = "leave"
+	Signalled = "signal"
+	// present re-announces the sessions a node has in a room; clients never see it
+	present = "present"
+)
+
+// syncTopic is where a starting node asks the others to announce their sessions right away.
+const syncTopic = "signaling.sync"
+
+// MaxPeers is the number of sessions a room holds.
+const MaxPeers = 8
+
+// maxData bounds the encoded data of a signal; session descriptions take a few KiB.
+const maxData = 32 << 10
+
+var roomName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Peer is a session in a room.
+type Peer struct {
+	Session string `json:"session"`
+	UserID  int32  `json:"user_id"`
+}
+
+// Signal is relayed from one session of a room to another. Data is opaque to the server: a
+// session description for offers and answers, an ICE candidate for candidates.
+type Signal struct {
+	From string          `json:"from,omitempty"`
+	To   string          `json:"to"`
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Event is published to a room's topic. Peer is the session that joined, left or signalled.
+type Event struct {
+	Event  string  `json:"event"`
+	Peer   Peer    `json:"peer"`
+	Signal *Signal `json:"signal,omitempty"`
+	// Peers are the sessions a node re-announces.
+	Peers []Peer `json:"peers,omitempty"`
+}
+
+// Topic is the hub topic of a room.
+func Topic(tenantID int32, room string) string {
+	return fmt.Sprintf("tenants.%d.rtc.%s", tenantID, room)
+}
+
+// member is a session in a room as known to this node.
+type member struct {
+	Peer
+	seen time.Time
+}
+
+// Server relays signals between the sessions of rooms. Run re-announces this node's sessions.
+type Server struct {
+	hub      *ws.Hub
+	interval time.Duration
+
+	mu sync.Mutex
+	// rooms holds the sessions of each room topic, on every node
+	rooms map[string]map[string]member
+	// local holds the room topics of this node's sessions
+	local *ws.Members
+	// sync is signalled when another node asks for announcements
+	sync chan struct{}
+
+	log *slog.Logger
+}
+
+// NewServer creates a Server and registers its message types and hooks on hub. Every node
+// announces its sessions each interval; sessions of other nodes that are not announced for three
+// intervals are forgotten.
+func NewServer(hub *ws.Hub, interval time.Duration) *Server {
+	s := &Server{
+		hub:      hub,
+		interval: interval,
+		rooms:    map[string]map[string]member{},
+		local:    ws.NewMembers(),
+		sync:     make(chan struct{}, 1),
+		log:      logging.Component("signaling"),
+	}
+	hub.Handle(TypeJoin, s.handleJoin)
+	hub.Handle(TypeLeave, s.handleLeave)
+	hub.Handle(TypeSignal, s.handleSignal)
+	hub.OnClose(s.disconnected)
+	hub.Observe("tenants.*.rtc.*", s.apply)
+	hub.Observe(syncTopic, func(string, []byte) {
+		// observers run while the hub delivers, so the announcements are left to Run
+		select {
+		case s.sync <- struct{}{}:
+		default:
+		}
+	})
+	return s
+}
+
+// Run asks the other nodes for their sessions, then announces this node's sessions and forgets
+// stale ones until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	s.hub.Publish(syncTopic, struct{}{})
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.sync:
+			s.announce()
+		case now := <-ticker.C:
+			s.announce()
+			s.expire(now)
+		}
+	}
+}
+
+func (s *Server) handleJoin(conn ws.Conn, msg ws.Message) {
+	if !allowed(conn, msg.Topic) {
+		conn.Reply(msg, ws.TypeError, "Not allowed to join this room")
+		return
+	}
+	s.mu.Lock()
+	_, joined := s.local.Conn(conn.ID(), msg.Topic)
+	full := !joined && len(s.rooms[msg.Topic]) >= MaxPeers
+	s.mu.Unlock()
+	if full {
+		conn.Reply(msg, ws.TypeError, "Room is full")
+		return
+	}
+	if s.local.Join(conn, msg.Topic) {
+		s.hub.Publish(msg.Topic, Event{Event: Join, Peer: peer(conn)})
+	}
+	// the publish above has already been applied, so the list includes the caller
+	conn.Reply(msg, ws.TypeConfirmation, s.Peers(msg.Topic))
+}
+
+func (s *Server) handleLeave(conn ws.Conn, msg ws.Message) {
+	if s.local.Leave(conn.ID(), msg.Topic) {
+		s.hub.Publish(msg.Topic, Event{Event: Leave, Peer: peer(conn)})
+	}
+	conn.Reply(msg, ws.TypeConfirmation, "Left successfully")
+}
+
+func (s *Server) handleSignal(conn ws.Conn, msg ws.Message) {
+	var sig Signal
+	if err := ws.Decode(msg.Payload, &sig); err != nil {
+		conn.Reply(msg, ws.TypeError, "Invalid signal: "+err.Error())
+		return
+	}
+	switch {
+	case sig.Kind != Offer && sig.Kind != Answer && sig.Kind != Candidate:
+		conn.Reply(msg, ws.TypeError, "Invalid signal: kind must be offer, answer or candidate")
+		return
+	case len(sig.Data) == 0 || len(sig.Data) > maxData:
+		conn.Reply(msg, ws.TypeError, "Invalid signal: data missing or too large")
+		return
+	}
+	if _, joined := s.local.Conn(conn.ID(), msg.Topic); !joined {
+		conn.Reply(msg, ws.TypeError, "Join the room before signalling")
+		return
+	}
+	s.mu.Lock()
+	_, present := s.rooms[msg.Topic][sig.To]
+	s.mu.Unlock()
+	if !present || sig.To == conn.ID() {
+		conn.Reply(msg, ws.TypeError, "No such peer in the room")
+		return
+	}
+	sig.From = conn.ID()
+	s.hub.Publish(msg.Topic, Event{Event: Signalled, Peer: peer(conn), Signal: &sig})
+	conn.Reply(msg, ws.TypeConfirmation, "Signal relayed")
+}
+
+func (s *Server) disconnected(conn ws.Conn) {
+	for _, topic := range s.local.LeaveAll(conn.ID()) {
+		s.hub.Publish(topic, Event{Event: Leave, Peer: peer(conn)})
+	}
+}
+
+// Peers returns the sessions in a room, ordered by session ID.
+func (s *Server) Peers(topic string) []Peer {
+	s.mu.Lock()
+	out := make([]Peer, 0, len(s.rooms[topic]))
+	for _, m := range s.rooms[topic] {
+		out = append(out, m.Peer)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(out, func(a, b Peer) int { return strings.Compare(a.Session, b.Session) })
+	return out
+}
+
+// announce publishes the sessions this node has in each room.
+func (s *Server) announce() {
+	for topic, conns := range s.local.Topics() {
+		peers := make([]Peer, len(conns))
+		for i, conn := range conns {
+			peers[i] = peer(conn)
+		}
+		s.hub.Publish(topic, Event{Event: present, Peers: peers})
+	}
+}
+
+// expire forgets the sessions of other nodes that were not announced for three intervals and
+// tells the local sessions in their rooms that they left.
+func (s *Server) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for topic, members := range s.rooms {
+		for session, m := range members {
+			if _, local := s.local.Conn(session, topic); local || now.Sub(m.seen) <= 3*s.interval {
+				continue
+			}
+			delete(members, session)
+			s.notify(ws.Message{Topic: topic}, Event{Event: Leave, Peer: m.Peer})
+		}
+		if len(members) == 0 {
+			delete(s.rooms, topic)
+		}
+	}
+}
+
+// apply folds a published room event into the rooms and hands it to the local sessions it is
+// for: signals to their recipient, joins and leaves to the other sessions in the room.
+func (s *Server) apply(topic string, data []byte) {
+	var msg struct {
+		ID      string `json:"id"`
+		Payload Event  `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.log.Warn("Ignoring undecodable room event", "topic", topic, "error", err)
+		return
+	}
+	e := msg.Payload
+	req := ws.Message{Topic: topic, ID: msg.ID}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Event {
+	case Join:
+		s.see(topic, e.Peer, now)
+		s.notify(req, e)
+	case present:
+		for _, p := range e.Peers {
+			// a session this node missed the join of, or forgot, is announced to the room again
+			if _, known := s.rooms[topic][p.Session]; !known {
+				s.notify(req, Event{Event: Join, Peer: p})
+			}
+			s.see(topic, p, now)
+		}
+	case Leave:
+		delete(s.rooms[topic], e.Peer.Session)
+		if len(s.rooms[topic]) == 0 {
+			delete(s.rooms, topic)
+		}
+		s.notify(req, e)
+	case Signalled:
+		// Reply only queues, so it is safe while the hub delivers
+		if e.Signal == nil {
+			return
+		}
+		if conn, ok := s.local.Conn(e.Signal.To, topic); ok {
+			conn.Reply(req, TypeSignal, e.Signal)
+		}
+	}
+}
+
+// see records that p is in the room. It must be called with mu held.
+func (s *Server) see(topic string, p Peer, now time.Time) {
+	if s.rooms[topic] == nil {
+		s.rooms[topic] = map[string]member{}
+	}
+	s.rooms[topic][p.Session] = member{Peer: p, seen: now}
+}
+
+// notify hands a join or leave to the local sessions in the room other than the one it is about.
+func (s *Server) notify(req ws.Message, e Event) {
+	for _, conn := range s.local.Topic(req.Topic) {
+		if conn.ID() != e.Peer.Session {
+			conn.Reply(req, TypePeer, e)
+		}
+	}
+}
+
+// allowed reports whether topic is a room of the connection's tenant.
+func allowed(conn ws.Conn, topic string) bool {
+	segments := strings.Split(topic, ".")
+	return len(segments) == 4 && segments[0] == "tenants" && segments[2] == "rtc" &&
+		segments[1] == strconv.Itoa(int(conn.Principal().TenantID)) && roomName.MatchString(segments[3])
+}
+
+func peer(conn ws.Conn) Peer {
+	return Peer{Session: conn.ID(), UserID: conn.Principal().UserID}
+}
@@ -0,0 +1,120 @@
+package signaling
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/backplane"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+var users atomic.Int32
+
+// node starts a hub on the cluster with a Server whose interval is long enough that only the
+// test makes it announce or expire, and returns the Server and a client of the hub in room.
+func node(t *testing.T, ctx context.Context, cluster *backplane.Memory, id, room string) (*Server, *Client) {
+	t.Helper()
+	hub := ws.NewHub(ws.Options{Backplane: cluster.Node(id)})
+	s := NewServer(hub, time.Hour)
+	runCtx, stop := context.WithCancel(ctx)
+	t.Cleanup(stop)
+	go hub.Run(runCtx)
+	go s.Run(runCtx)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tenant.WithPrincipal(r.Context(), tenant.Principal{TenantID: 7, UserID: users.Add(1)})
+		hub.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(srv.Close)
+	client, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Join(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	return s, client
+}
+
+// eventually fails the test unless cond holds within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// next returns the next room event of the client, skipping those about other sessions.
+func next(t *testing.T, ctx context.Context, c *Client, session string) Event {
+	t.Helper()
+	for {
+		select {
+		case e, ok := <-c.Events():
+			if !ok {
+				t.Fatal("connection ended")
+			}
+			if e.Peer.Session == session {
+				return e
+			}
+		case <-ctx.Done():
+			t.Fatalf("no event about %s", session)
+		}
+	}
+}
+
+func TestStartingNodeLearnsRooms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cluster := backplane.NewMemory()
+	topic := Topic(7, "review")
+	_, first := node(t, ctx, cluster, "a", "review")
+
+	// the second node starts after the first session joined, so it only learns of it by asking
+	s, second := node(t, ctx, cluster, "b", "review")
+	eventually(t, "the second node to know both sessions", func() bool { return len(s.Peers(topic)) == 2 })
+
+	if err := second.Signal(ctx, "review", Signal{To: first.Session(), Kind: Offer, Data: []byte(`{}`)}); err != nil {
+		t.Fatalf("signal a session of the other node: %v", err)
+	}
+	if e := next(t, ctx, first, second.Session()); e.Event != Join {
+		t.Errorf("got %+v, want %s to join", e, second.Session())
+	}
+	if e := next(t, ctx, first, second.Session()); e.Event != Signalled || e.Signal.From != second.Session() {
+		t.Errorf("got %+v, want the offer of %s", e, second.Session())
+	}
+}
+
+func TestExpireForgetsSilentNodes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cluster := backplane.NewMemory()
+	topic := Topic(7, "review")
+	_, first := node(t, ctx, cluster, "a", "review")
+	s, second := node(t, ctx, cluster, "b", "review")
+	eventually(t, "the second node to know both sessions", func() bool { return len(s.Peers(topic)) == 2 })
+
+	// nothing was announced for longer than three intervals; the node's own session stays
+	s.expire(time.Now().Add(4 * s.interval))
+	if peers := s.Peers(topic); len(peers) != 1 || peers[0].Session != second.Session() {
+		t.Errorf("got peers %+v, want only %s", peers, second.Session())
+	}
+	if e := next(t, ctx, second, first.Session()); e.Event != Leave {
+		t.Errorf("got %+v, want %s to leave", e, first.Session())
+	}
+
+	// once the other node announces the session again, it is back in the room
+	s.hub.Publish(syncTopic, struct{}{})
+	eventually(t, "the session to be announced again", func() bool { return len(s.Peers(topic)) == 2 })
+	if e := next(t, ctx, second, first.Session()); e.Event != Join {
+		t.Errorf("got %+v, want %s to join again", e, first.Session())
+	}
+}
@@ -0,0 +1,307 @@
+// Package sse serves server-sent event streams. A Broker numbers every published event, keeps the
+// most recent ones so reconnecting clients can resume from their Last-Event-ID, and gives each
+// client its own bounded queue so a stalled client never delays the others.
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/backplane"
+	"github.com/wilbyang/law-docs/internal/logging"
+)
+
+// Event is one published event. ID increases by one with every Publish on a Broker.
+type Event struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+// Policy decides what happens to a client whose queue is full.
+type Policy int
+
+const (
+	// Disconnect closes the client's stream. A client that reconnects with Last-Event-ID replays
+	// what it missed, as long as it is still in the history.
+	Disconnect Policy = iota
+	// Drop discards the event for that client only and keeps the stream open.
+	Drop
+)
+
+// Options configures a Broker. Zero values select the defaults.
+type Options struct {
+	// Buffer is the number of events queued per client, 64 by default.
+	Buffer int
+	// History is the number of recent events kept for replay, 1024 by default.
+	History int
+	// Heartbeat is the interval of comment lines sent on idle streams, 15s by default.
+	Heartbeat time.Duration
+	// Retry is the reconnection delay suggested to clients, 3s by default.
+	Retry time.Duration
+	// Policy applies to clients whose queue is full.
+	Policy Policy
+	// Node qualifies the event IDs sent to clients, so that a Last-Event-ID issued by another
+	// node or an earlier process is recognised. A random ID by default.
+	Node string
+	// Backplane, when set, forwards publishes to the brokers of other nodes. Run must be running
+	// to receive theirs. Events from other nodes get IDs of this node.
+	Backplane backplane.Backplane
+}
+
+// Broker fans published events out to subscribed clients.
+type Broker struct {
+	opts    Options
+	mu      sync.Mutex
+	lastID  uint64
+	history ring
+	clients map[*client]struct{}
+	closed  bool
+	log     *slog.Logger
+}
+
+type client struct {
+	queue  chan Event
+	topics []string
+}
+
+// NewBroker creates a Broker with opts.
+func NewBroker(opts Options) *Broker {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+	if opts.History <= 0 {
+		opts.History = 1024
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = 15 * time.Second
+	}
+	if opts.Retry <= 0 {
+		opts.Retry = 3 * time.Second
+	}
+	if opts.Node == "" {
+		opts.Node = backplane.NewNodeID()
+	}
+	return &Broker{
+		opts:    opts,
+		history: ring{events: make([]Event, opts.History)},
+		clients: map[*client]struct{}{},
+		log:     logging.Component("sse"),
+	}
+}
+
+// Publish sends data, encoded as JSON, to the clients subscribed to topic under the event name
+// eventType and returns the event. It never blocks on clients; forwarding to other nodes is
+// bounded by the heartbeat interval.
+func (b *Broker) Publish(topic, eventType string, data any) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	e := b.publish(topic, eventType, encoded)
+	if b.opts.Backplane == nil {
+		return e, nil
+	}
+	forward, err := json.Marshal(remoteEvent{Type: eventType, Data: encoded})
+	if err != nil {
+		return e, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.opts.Heartbeat)
+	defer cancel()
+	return e, b.opts.Backplane.Publish(ctx, topic, forward)
+}
+
+// remoteEvent is an event forwarded between nodes.
+type remoteEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Run publishes the events forwarded by other nodes until ctx is cancelled. Without a
+// Backplane it only waits for ctx.
+func (b *Broker) Run(ctx context.Context) error {
+	if b.opts.Backplane == nil {
+		<-ctx.Done()
+		return nil
+	}
+	return b.opts.Backplane.Run(ctx, func(topic string, payload []byte) {
+		var e remoteEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			b.log.WarnContext(ctx, "Ignoring undecodable event", "topic", topic, "error", err)
+			return
+		}
+		b.publish(topic, e.Type, e.Data)
+	})
+}
+
+func (b *Broker) publish(topic, eventType string, encoded []byte) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	e := Event{ID: b.lastID, Topic: topic, Type: eventType, Data: encoded}
+	b.history.push(e)
+	for c := range b.clients {
+		if !c.matches(topic) {
+			continue
+		}
+		select {
+		case c.queue <- e:
+		default:
+			if b.opts.Policy == Drop {
+				b.log.Debug("Dropped event for slow client", "topic", topic, "id", e.ID)
+				continue
+			}
+			b.log.Info("Disconnecting slow client", "topic", topic, "id", e.ID)
+			b.remove(c)
+		}
+	}
+	return e
+}
+
+// Subscription is a client's view of a Broker.
+type Subscription struct {
+	// Events delivers the client's events in ID order and is closed when the subscription ends.
+	Events <-chan Event
+	// Reset is set when events after the requested Last-Event-ID are no longer available,
+	// so the client has to reload its state instead of relying on the replay.
+	Reset bool
+
+	b *Broker
+	c *client
+	// lastID is the broker's last event ID when the subscription started
+	lastID uint64
+}
+
+// Subscribe registers a client for topics. A topic ending in "*" matches every topic with that
+// prefix.
+func (b *Broker) Subscribe(topics []string) *Subscription {
+	return b.subscribe(topics, 0, false)
+}
+
+// Resume is Subscribe for a client that has seen the events up to lastID. The later events of
+// topics are queued first, or Reset is set if the history no longer reaches back to lastID.
+func (b *Broker) Resume(topics []string, lastID uint64) *Subscription {
+	return b.subscribe(topics, lastID, true)
+}
+
+func (b *Broker) subscribe(topics []string, lastID uint64, resume bool) *Subscription {
+	c := &client{topics: topics}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var replay []Event
+	reset := false
+	if resume {
+		var complete bool
+		replay, complete = b.history.since(lastID)
+		reset = !complete || lastID > b.lastID
+		if reset {
+			// the client reloads its state, a partial replay would only duplicate it
+			replay = nil
+		}
+		replay = filter(replay, c)
+	}
+	c.queue = make(chan Event, b.opts.Buffer+len(replay))
+	for _, e := range replay {
+		c.queue <- e
+	}
+	if b.closed {
+		close(c.queue)
+		return &Subscription{Events: c.queue, b: b, c: c, lastID: b.lastID}
+	}
+	b.clients[c] = struct{}{}
+	return &Subscription{Events: c.queue, Reset: reset, b: b, c: c, lastID: b.lastID}
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s.c)
+}
+
+// Close ends every subscription and makes later ones end at once, so that streams return and
+// an http.Server shutting down does not wait for them. Register it with RegisterOnShutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for c := range b.clients {
+		b.remove(c)
+	}
+}
+
+// Clients returns the number of subscribed clients.
+func (b *Broker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// remove must be called with b.mu held.
+func (b *Broker) remove(c *client) {
+	if _, ok := b.clients[c]; ok {
+		delete(b.clients, c)
+		close(c.queue)
+	}
+}
+
+func (c *client) matches(topic string) bool {
+	for _, t := range c.topics {
+		if prefix, ok := strings.CutSuffix(t, "*"); (ok && strings.HasPrefix(topic, prefix)) || t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+func filter(events []Event, c *client) []Event {
+	out := events[:0]
+	for _, e := range events {
+		if c.matches(e.Topic) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ring keeps the most recent events, oldest first.
+type ring struct {
+	events []Event
+	start  int
+	count  int
+}
+
+func (r *ring) push(e Event) {
+	if len(r.events) == 0 {
+		return
+	}
+	i := (r.start + r.count) % len(r.events)
+	r.events[i] = e
+	if r.count < len(r.events) {
+		r.count++
+	} else {
+		r.start = (r.start + 1) % len(r.events)
+	}
+}
+
+// since returns a copy of the events after id and whether the history reaches back far enough
+// to contain all of them.
+func (r *ring) since(id uint64) ([]Event, bool) {
+	var out []Event
+	for i := 0; i < r.count; i++ {
+		e := r.events[(r.start+i)%len(r.events)]
+		if e.ID > id {
+			out = append(out, e)
+		}
+	}
+	if r.count == 0 {
+		return out, true
+	}
+	oldest := r.events[r.start].ID
+	return out, id+1 >= oldest
+}
@@ -0,0 +1,162 @@
+package sse
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// drain returns the IDs of the events queued for sub without waiting.
+func drain(sub *Subscription) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestRing(t *testing.T) {
+	r := ring{events: make([]Event, 3)}
+	if got, complete := r.since(0); got != nil || !complete {
+		t.Errorf("empty ring: got %v, %v", got, complete)
+	}
+	for id := uint64(1); id <= 5; id++ {
+		r.push(Event{ID: id})
+	}
+	// 1 and 2 were overwritten
+	for _, tc := range []struct {
+		after    uint64
+		want     []uint64
+		complete bool
+	}{
+		{0, []uint64{3, 4, 5}, false},
+		{1, []uint64{3, 4, 5}, false},
+		{2, []uint64{3, 4, 5}, true},
+		{4, []uint64{5}, true},
+		{5, nil, true},
+	} {
+		got, complete := r.since(tc.after)
+		var ids []uint64
+		for _, e := range got {
+			ids = append(ids, e.ID)
+		}
+		if !slices.Equal(ids, tc.want) || complete != tc.complete {
+			t.Errorf("since(%d) = %v, %v; want %v, %v", tc.after, ids, complete, tc.want, tc.complete)
+		}
+	}
+
+	var none ring
+	none.push(Event{ID: 1})
+	if got, complete := none.since(0); got != nil || !complete {
+		t.Errorf("ring without history: got %v, %v", got, complete)
+	}
+}
+
+func TestResume(t *testing.T) {
+	b := NewBroker(Options{Node: "n1", History: 4})
+	for range 3 {
+		b.Publish("docs.1", "document.updated", 1)
+		b.Publish("docs.2", "document.updated", 2)
+	}
+	// IDs 1 to 6 were published, 3 to 6 are in the history; odd IDs are docs.1
+
+	for _, tc := range []struct {
+		name   string
+		topics []string
+		lastID uint64
+		want   []uint64
+		reset  bool
+	}{
+		{"up to date", []string{"docs.1"}, 6, nil, false},
+		{"missed some", []string{"docs.1"}, 2, []uint64{3, 5}, false},
+		{"prefix", []string{"docs.*"}, 4, []uint64{5, 6}, false},
+		{"history too short", []string{"docs.1"}, 1, nil, true},
+		// an ID the broker issued before a restart, beyond any it has issued since
+		{"ahead of the broker", []string{"docs.1"}, 9, nil, true},
+	} {
+		sub := b.Resume(tc.topics, tc.lastID)
+		if got := drain(sub); !slices.Equal(got, tc.want) || sub.Reset != tc.reset {
+			t.Errorf("%s: got %v with reset %v, want %v with reset %v", tc.name, got, sub.Reset, tc.want, tc.reset)
+		}
+		sub.Close()
+	}
+
+	sub := b.Subscribe([]string{"docs.2"})
+	defer sub.Close()
+	if got := drain(sub); got != nil || sub.Reset {
+		t.Errorf("a new subscription replayed %v", got)
+	}
+	b.Publish("docs.1", "document.updated", 1)
+	b.Publish("docs.2", "document.updated", 2)
+	if got := drain(sub); !slices.Equal(got, []uint64{8}) {
+		t.Errorf("got %v, want only the event of its topic", got)
+	}
+}
+
+func TestSlowClient(t *testing.T) {
+	for _, tc := range []struct {
+		policy Policy
+		want   []uint64
+		open   bool
+	}{
+		{Drop, []uint64{1, 2}, true},
+		{Disconnect, []uint64{1, 2}, false},
+	} {
+		b := NewBroker(Options{Buffer: 2, Policy: tc.policy})
+		slow := b.Subscribe([]string{"docs"})
+		for range 3 {
+			b.Publish("docs", "document.updated", nil)
+		}
+		got := drain(slow)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("policy %d: got %v, want %v", tc.policy, got, tc.want)
+		}
+		if open := b.Clients() == 1; open != tc.open {
+			t.Errorf("policy %d: subscribed %v, want %v", tc.policy, open, tc.open)
+		}
+		if tc.open {
+			b.Publish("docs", "document.updated", nil)
+			if got := drain(slow); !slices.Equal(got, []uint64{4}) {
+				t.Errorf("after dropping, got %v, want the next event", got)
+			}
+		}
+		slow.Close()
+		slow.Close()
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	b := NewBroker(Options{Node: "node-a"})
+	for _, tc := range []struct {
+		header, query   string
+		id              uint64
+		resume, foreign bool
+	}{
+		{"", "", 0, false, false},
+		{"node-a-42", "", 42, true, false},
+		{"", "node-a-7", 7, true, false},
+		{"node-a-42", "node-a-7", 42, true, false},
+		// IDs of another node, or of no node, are foreign
+		{"node-b-42", "", 0, false, true},
+		{"node-a-x", "", 0, false, true},
+		{"42", "", 0, false, true},
+		{"node-a--1", "", 0, false, true},
+	} {
+		r := httptest.NewRequest("GET", "/events?last_event_id="+tc.query, nil)
+		if tc.header != "" {
+			r.Header.Set("Last-Event-ID", tc.header)
+		}
+		id, resume, foreign := b.lastEventID(r)
+		if id != tc.id || resume != tc.resume || foreign != tc.foreign {
+			t.Errorf("header %q, query %q: got %d, %v, %v; want %d, %v, %v",
+				tc.header, tc.query, id, resume, foreign, tc.id, tc.resume, tc.foreign)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResetEvent is sent first when a reconnecting client's Last-Event-ID can no longer be replayed.
+const ResetEvent = "reset"
+
+// Serve streams the events of topics to w until the request ends or the broker drops the client.
+// The Last-Event-ID header, or a last_event_id query parameter for clients that cannot set headers,
+// selects the events to replay.
+func (b *Broker) Serve(w http.ResponseWriter, r *http.Request, topics []string) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("sse: response writer cannot flush")
+	}
+	var sub *Subscription
+	lastID, resume, foreign := b.lastEventID(r)
+	if resume {
+		sub = b.Resume(topics, lastID)
+	} else {
+		sub = b.Subscribe(topics)
+	}
+	defer sub.Close()
+	if foreign {
+		sub.Reset = true
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", b.opts.Retry.Milliseconds()); err != nil {
+		return err
+	}
+	if sub.Reset {
+		// the ID lets the client resume from here next time instead of resetting again
+		if err := b.write(w, Event{ID: sub.lastID, Type: ResetEvent, Data: []byte(`{}`)}); err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(b.opts.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+		case e, ok := <-sub.Events:
+			if !ok {
+				return nil
+			}
+			if err := b.write(w, e); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// write sends e with an ID of the form "<node>-<id>".
+func (b *Broker) write(w io.Writer, e Event) error {
+	_, err := fmt.Fprintf(w, "id: %s-%d\nevent: %s\ndata: %s\n\n", b.opts.Node, e.ID, e.Type, e.Data)
+	return err
+}
+
+// lastEventID returns the event ID the client wants to resume after, if it sent one of this
+// node's IDs, or whether it sent an ID issued by another node or an earlier process.
+func (b *Broker) lastEventID(r *http.Request) (id uint64, resume, foreign bool) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, false, false
+	}
+	i := strings.LastIndexByte(v, '-')
+	if i < 0 || v[:i] != b.opts.Node {
+		return 0, false, true
+	}
+	id, err := strconv.ParseUint(v[i+1:], 10, 64)
+	if err != nil {
+		return 0, false, true
+	}
+	return id, true, false
+}
@@ -0,0 +1,89 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownEndsStreams(t *testing.T) {
+	b := NewBroker(Options{Node: "n1"})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Serve(w, r, []string{"documents"})
+	}))
+	srv.Config.RegisterOnShutdown(b.Close)
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the retry line is flushed once the stream is subscribed
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("got %q (%v), want the retry line", line, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown with an open stream: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("shutdown took %s", d)
+	}
+	if n := b.Clients(); n != 0 {
+		t.Errorf("%d clients left", n)
+	}
+	if sub := b.Subscribe([]string{"documents"}); !closed(sub.Events) {
+		t.Error("subscription after Close stays open")
+	}
+}
+
+// closed reports whether events is closed without delivering anything.
+func closed(events <-chan Event) bool {
+	select {
+	case _, ok := <-events:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func TestServeResetsForeignIDs(t *testing.T) {
+	b := NewBroker(Options{Node: "n1"})
+	b.Publish("documents", "document.created", 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Serve(w, r, []string{"documents"})
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	// issued by a node that has since restarted
+	req.Header.Set("Last-Event-ID", "n0-7")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 6 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	want := []string{"retry: 3000", "", "id: n1-1", "event: reset", "data: {}", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,312 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/tracing"
+)
+
+// Handler handles one event. An entry whose handler fails stays pending and is delivered again
+// once it has been idle for MinIdle.
+type Handler func(ctx context.Context, e Envelope) error
+
+// Options configures a Consumer. Zero values select the defaults.
+type Options struct {
+	// Group is the consumer group. Consumers of one group share its entries, each entry going to
+	// one of them.
+	Group string
+	// Consumer names this consumer within the group. It must stay the same across restarts, so
+	// that the consumer resumes the entries it was handling, and differ between replicas.
+	Consumer string
+	// Start is where a newly created group starts reading, "$" by default: only entries added
+	// after its creation. "0" reads the whole stream.
+	Start string
+	// Count is the number of entries read at once, 10 by default.
+	Count int64
+	// Block is how long a read waits for new entries, 5s by default.
+	Block time.Duration
+	// MinIdle is how long an entry stays pending before the consumer claims it from whichever
+	// consumer of the group holds it, 1 minute by default. It must exceed the handling time.
+	MinIdle time.Duration
+	// ClaimInterval is how often the consumer looks for idle pending entries, 30s by default.
+	ClaimInterval time.Duration
+	// MaxDeliveries is the number of times an entry is delivered before it is given up, 5 by
+	// default.
+	MaxDeliveries int64
+	// DeadLetter is the stream that entries which were given up or are not event envelopes are
+	// moved to, with the fields source_id and error added. Without one they are logged and dropped.
+	DeadLetter string
+	// RetryDelay is the wait after a failed Redis command, 1s by default.
+	RetryDelay time.Duration
+}
+
+// Consumer reads the events of a stream as one member of a consumer group.
+type Consumer struct {
+	client *redis.Client
+	stream string
+	opts   Options
+	log    *slog.Logger
+}
+
+// NewConsumer creates a Consumer of stream. opts.Group and opts.Consumer are required.
+func NewConsumer(client *redis.Client, stream string, opts Options) *Consumer {
+	if opts.Start == "" {
+		opts.Start = "$"
+	}
+	if opts.Count <= 0 {
+		opts.Count = 10
+	}
+	if opts.Block <= 0 {
+		opts.Block = 5 * time.Second
+	}
+	if opts.MinIdle <= 0 {
+		opts.MinIdle = time.Minute
+	}
+	if opts.ClaimInterval <= 0 {
+		opts.ClaimInterval = 30 * time.Second
+	}
+	if opts.MaxDeliveries <= 0 {
+		opts.MaxDeliveries = 5
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+	return &Consumer{
+		client: client,
+		stream: stream,
+		opts:   opts,
+		log:    logging.Component("streams").With("stream", stream, "group", opts.Group, "consumer", opts.Consumer),
+	}
+}
+
+// Run passes the group's events to handle until ctx is cancelled. It first finishes the entries
+// this consumer left pending when it last stopped, and periodically claims the entries that
+// other consumers left pending for longer than MinIdle. Redis errors are logged and retried.
+func (c *Consumer) Run(ctx context.Context, handle Handler) error {
+	if c.opts.Group == "" || c.opts.Consumer == "" {
+		return errors.New("streams: a consumer needs a group and a consumer name")
+	}
+	for {
+		err := c.run(ctx, handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.log.ErrorContext(ctx, "Stream consumer failed", "error", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.opts.RetryDelay):
+		}
+	}
+}
+
+func (c *Consumer) run(ctx context.Context, handle Handler) error {
+	if err := c.createGroup(ctx); err != nil {
+		return err
+	}
+	if err := c.resume(ctx, handle); err != nil {
+		return err
+	}
+	var lastClaim time.Time
+	for {
+		if time.Since(lastClaim) >= c.opts.ClaimInterval {
+			if err := c.claim(ctx, handle); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+		res, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			Streams:  []string{c.stream, ">"},
+			Count:    c.opts.Count,
+			Block:    c.opts.Block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+		for _, s := range res {
+			for _, msg := range s.Messages {
+				c.process(ctx, handle, msg)
+			}
+		}
+	}
+}
+
+// createGroup creates the group, and the stream if it does not exist yet.
+func (c *Consumer) createGroup(ctx context.Context) error {
+	err := c.client.XGroupCreateMkStream(ctx, c.stream, c.opts.Group, c.opts.Start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return nil
+}
+
+// resume handles the entries that are still pending for this consumer, such as those it was
+// handling when it stopped. Entries that fail again stay pending.
+func (c *Consumer) resume(ctx context.Context, handle Handler) error {
+	after := "0"
+	for {
+		res, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			Streams:  []string{c.stream, after},
+			Count:    c.opts.Count,
+			// reading pending entries never blocks
+			Block: -1,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to read pending entries: %w", err)
+		}
+		if len(res) == 0 || len(res[0].Messages) == 0 {
+			return nil
+		}
+		for _, msg := range res[0].Messages {
+			c.process(ctx, handle, msg)
+			after = msg.ID
+		}
+	}
+}
+
+// claim takes over the entries of the group that have been pending for MinIdle, including this
+// consumer's own failures, and handles them again or gives them up after MaxDeliveries.
+func (c *Consumer) claim(ctx context.Context, handle Handler) error {
+	start := "0-0"
+	for {
+		msgs, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.stream,
+			Group:    c.opts.Group,
+			MinIdle:  c.opts.MinIdle,
+			Start:    start,
+			Count:    c.opts.Count,
+			Consumer: c.opts.Consumer,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("failed to claim pending entries: %w", err)
+		}
+		deliveries, err := c.deliveries(ctx, msgs)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if n := deliveries[msg.ID]; n > c.opts.MaxDeliveries {
+				c.deadLetter(ctx, msg, fmt.Sprintf("delivered %d times without success", n-1))
+				continue
+			}
+			c.log.InfoContext(ctx, "Claimed pending entry", "id", msg.ID, "deliveries", deliveries[msg.ID])
+			c.process(ctx, handle, msg)
+		}
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// deliveries returns how often each of msgs has been delivered, counting the claim.
+func (c *Consumer) deliveries(ctx context.Context, msgs []redis.XMessage) (map[string]int64, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	cmds := make([]*redis.XPendingExtCmd, len(msgs))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, msg := range msgs {
+			cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: c.stream,
+				Group:  c.opts.Group,
+				Start:  msg.ID,
+				End:    msg.ID,
+				Count:  1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery counts: %w", err)
+	}
+	out := make(map[string]int64, len(msgs))
+	for _, cmd := range cmds {
+		for _, p := range cmd.Val() {
+			out[p.ID] = p.RetryCount
+		}
+	}
+	return out, nil
+}
+
+// process handles msg and acknowledges it if the handler succeeds.
+func (c *Consumer) process(ctx context.Context, handle Handler, msg redis.XMessage) {
+	if len(msg.Values) == 0 {
+		// the entry was trimmed from the stream while it was pending
+		c.log.WarnContext(ctx, "Pending entry was trimmed before it was handled", "id", msg.ID)
+		c.ack(ctx, msg.ID, "trimmed")
+		return
+	}
+	e, err := decode(msg)
+	if err != nil {
+		c.deadLetter(ctx, msg, err.Error())
+		return
+	}
+	ctx, span := tracing.Start(tracing.Extract(ctx, tracing.MapCarrier(msg.Values)), "redis.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", c.stream),
+			attribute.String("messaging.message.id", msg.ID),
+			attribute.String("event.type", e.Type),
+		))
+	err = handle(ctx, e)
+	tracing.End(span, err)
+	if err != nil {
+		c.log.WarnContext(ctx, "Failed to handle event, leaving it pending", "id", msg.ID, "type", e.Type, "error", err)
+		metrics.StreamEntries.WithLabelValues(c.stream, "failed").Inc()
+		return
+	}
+	c.ack(ctx, msg.ID, "handled")
+}
+
+// ack acknowledges an entry. If that fails the entry is delivered again later.
+func (c *Consumer) ack(ctx context.Context, id, outcome string) {
+	if err := c.client.XAck(ctx, c.stream, c.opts.Group, id).Err(); err != nil {
+		c.log.ErrorContext(ctx, "Failed to acknowledge entry", "id", id, "error", err)
+		return
+	}
+	metrics.StreamEntries.WithLabelValues(c.stream, outcome).Inc()
+}
+
+// deadLetter gives msg up, moving it to the DeadLetter stream if there is one.
+func (c *Consumer) deadLetter(ctx context.Context, msg redis.XMessage, reason string) {
+	c.log.ErrorContext(ctx, "Giving up stream entry", "id", msg.ID, "reason", reason, "dead_letter", c.opts.DeadLetter)
+	if c.opts.DeadLetter == "" {
+		c.ack(ctx, msg.ID, "dropped")
+		return
+	}
+	values := make(map[string]interface{}, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"], values["error"] = msg.ID, reason
+	// adding and acknowledging at once, so the entry is neither lost nor moved twice
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: c.opts.DeadLetter, MaxLen: DefaultMaxLen, Approx: true, Values: values})
+		pipe.XAck(ctx, c.stream, c.opts.Group, msg.ID)
+		return nil
+	})
+	if err != nil {
+		c.log.ErrorContext(ctx, "Failed to move entry to the dead letter stream", "id", msg.ID, "error", err)
+		return
+	}
+	metrics.StreamEntries.WithLabelValues(c.stream, "dead_lettered").Inc()
+}
@@ -0,0 +1,167 @@
+//go:build integration
+
+package streams
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// These tests need a Redis server at REDIS_ADDR, localhost:6380 by default as in docker-compose:
+//
+//	go test -tags integration ./internal/streams
+
+func testClient(t *testing.T) *redis.Client {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6380"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("no Redis at %s: %v", addr, err)
+	}
+	return client
+}
+
+// testStream returns a stream name of its own for the test, deleted afterwards.
+func testStream(t *testing.T, client *redis.Client) string {
+	t.Helper()
+	name := "lawdocs:test:" + strings.ToLower(rand.Text())
+	t.Cleanup(func() { client.Del(context.Background(), name, name+":dead") })
+	return name
+}
+
+func TestPublishID(t *testing.T) {
+	client := testClient(t)
+	stream := testStream(t, client)
+	p := NewProducer(client, stream, 0)
+	ctx := context.Background()
+
+	for _, step := range []struct {
+		id        string
+		duplicate bool
+	}{
+		{"10-0", false}, // creates the stream
+		{"10-0", true},
+		{"9-5", true},
+		{"10-1", false},
+		{"11-0", false},
+		{"10-9", true},
+		// beyond the integers a float64 holds exactly
+		{"9007199254740992-0", false},
+		{"9007199254740993-0", false},
+		{"9007199254740993-0", true},
+	} {
+		duplicate, err := p.PublishID(ctx, step.id, "document.created", map[string]string{"id": step.id})
+		if err != nil {
+			t.Fatalf("%s: %v", step.id, err)
+		}
+		if duplicate != step.duplicate {
+			t.Errorf("%s: got duplicate %v, want %v", step.id, duplicate, step.duplicate)
+		}
+	}
+	if n := client.XLen(ctx, stream).Val(); n != 5 {
+		t.Errorf("stream has %d entries, want 5", n)
+	}
+	if _, err := p.PublishID(ctx, "not-an-id", "document.created", nil); err == nil {
+		t.Error("published under an invalid ID")
+	}
+}
+
+// consume runs a consumer of stream until stop returns true or the test times out.
+func consume(t *testing.T, client *redis.Client, stream string, opts Options, handle Handler, stop func() bool) {
+	t.Helper()
+	opts.Group, opts.Consumer, opts.Start = "test", "c1", "0"
+	opts.Block = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- NewConsumer(client, stream, opts).Run(ctx, handle) }()
+	for !stop() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timed out")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumerAcknowledges(t *testing.T) {
+	client := testClient(t)
+	stream := testStream(t, client)
+	ctx := context.Background()
+	id, err := NewProducer(client, stream, 0).Publish(ctx, "processing.failed", map[string]int{"document_id": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Envelope
+	var handled atomic.Bool
+	consume(t, client, stream, Options{}, func(_ context.Context, e Envelope) error {
+		got = append(got, e)
+		handled.Store(true)
+		return nil
+	}, handled.Load)
+
+	if len(got) != 1 || got[0].ID != id || got[0].Type != "processing.failed" || string(got[0].Data) != `{"document_id":42}` {
+		t.Errorf("handled %+v", got)
+	}
+	if pending := client.XPending(ctx, stream, "test").Val(); pending.Count != 0 {
+		t.Errorf("%d entries left pending", pending.Count)
+	}
+}
+
+func TestConsumerDeadLetters(t *testing.T) {
+	client := testClient(t)
+	stream := testStream(t, client)
+	ctx := context.Background()
+	failing, err := NewProducer(client, stream, 0).Publish(ctx, "processing.failed", map[string]int{"document_id": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: map[string]any{"message": "Critical alert! Server down."}}).Val()
+
+	var calls atomic.Int32
+	opts := Options{
+		MinIdle:       50 * time.Millisecond,
+		ClaimInterval: 10 * time.Millisecond,
+		MaxDeliveries: 2,
+		DeadLetter:    stream + ":dead",
+	}
+	consume(t, client, stream, opts, func(context.Context, Envelope) error {
+		calls.Add(1)
+		return context.DeadlineExceeded
+	}, func() bool { return client.XLen(ctx, opts.DeadLetter).Val() == 2 })
+
+	// delivered by the read, then once more by a claim; the next claim exceeds MaxDeliveries
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handled %d times, want 2", n)
+	}
+	dead := client.XRange(ctx, opts.DeadLetter, "-", "+").Val()
+	reasons := map[string]string{}
+	for _, msg := range dead {
+		reasons[msg.Values["source_id"].(string)] = msg.Values["error"].(string)
+	}
+	if !strings.Contains(reasons[foreign], "not an event envelope") {
+		t.Errorf("foreign entry dead-lettered with %q", reasons[foreign])
+	}
+	if !strings.Contains(reasons[failing], "delivered 2 times") {
+		t.Errorf("failing entry dead-lettered with %q", reasons[failing])
+	}
+	if pending := client.XPending(ctx, stream, "test").Val(); pending.Count != 0 {
+		t.Errorf("%d entries left pending", pending.Count)
+	}
+}
@@ -0,0 +1,158 @@
+// Package streams publishes and consumes typed events on Redis streams. Consumers read through a
+// consumer group, acknowledge an entry only after it was handled, and take over the entries that
+// a crashed consumer of the group left pending.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wilbyang/law-docs/internal/tracing"
+)
+
+// Entry fields. The remaining fields of an entry carry its trace context.
+const (
+	fieldType = "type"
+	fieldTime = "time"
+	fieldData = "data"
+)
+
+// Envelope is one event on a stream.
+type Envelope struct {
+	// ID is the entry ID assigned by Redis.
+	ID   string
+	Type string
+	// Time is when the event was published.
+	Time time.Time
+	// Data is the JSON encoded event.
+	Data json.RawMessage
+}
+
+// Decode unmarshals the event into v.
+func (e Envelope) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+func decode(msg redis.XMessage) (Envelope, error) {
+	e := Envelope{ID: msg.ID}
+	eventType, _ := msg.Values[fieldType].(string)
+	published, _ := msg.Values[fieldTime].(string)
+	data, _ := msg.Values[fieldData].(string)
+	if eventType == "" || !json.Valid([]byte(data)) {
+		return e, fmt.Errorf("entry %s is not an event envelope", msg.ID)
+	}
+	t, err := time.Parse(time.RFC3339Nano, published)
+	if err != nil {
+		return e, fmt.Errorf("entry %s has an invalid time: %w", msg.ID, err)
+	}
+	e.Type, e.Time, e.Data = eventType, t, json.RawMessage(data)
+	return e, nil
+}
+
+// DefaultMaxLen is the default length a Producer trims its stream to.
+const DefaultMaxLen = 10000
+
+// Producer appends events to a stream.
+type Producer struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewProducer creates a Producer for stream. Every publish trims the stream to about maxLen
+// entries, DefaultMaxLen if maxLen is not positive. Trimming also removes entries that a consumer
+// group has not handled yet, so maxLen must cover the longest expected consumer outage.
+func NewProducer(client *redis.Client, stream string, maxLen int64) *Producer {
+	if maxLen <= 0 {
+		maxLen = DefaultMaxLen
+	}
+	return &Producer{client: client, stream: stream, maxLen: maxLen}
+}
+
+// Publish appends data, encoded as JSON, as an event of eventType and returns its entry ID.
+func (p *Producer) Publish(ctx context.Context, eventType string, data any) (string, error) {
+	return p.publish(ctx, "*", eventType, data)
+}
+
+// PublishID is Publish with the entry ID chosen by the caller, "<ms>-<seq>" with both parts
+// increasing like the positions of a log. An entry whose ID is not above the stream's last one is
+// not added, so a producer that restarts from an earlier position publishes every entry once;
+// PublishID reports such entries as duplicates without an error.
+func (p *Producer) PublishID(ctx context.Context, id, eventType string, data any) (duplicate bool, err error) {
+	_, err = p.publish(ctx, id, eventType, data)
+	if errors.Is(err, redis.Nil) {
+		return true, nil
+	}
+	return false, err
+}
+
+// addAfterLast adds an entry with the ID in ARGV[1] unless the stream's last generated ID is
+// already as high, returning false then instead of the error XADD would raise. IDs are compared
+// as decimal strings, since Lua numbers cannot hold every 64-bit part. ARGV[2] is the MAXLEN and
+// the remaining arguments are the entry's fields and values.
+var addAfterLast = redis.NewScript(`
+local function compare(a, b)
+	if #a ~= #b then return #a < #b and -1 or 1 end
+	if a == b then return 0 end
+	return a < b and -1 or 1
+end
+local ms, seq = string.match(ARGV[1], "^(%d+)-(%d+)$")
+local info = redis.pcall("XINFO", "STREAM", KEYS[1])
+if ms and not info.err then
+	for i = 1, #info, 2 do
+		if info[i] == "last-generated-id" then
+			local lastMs, lastSeq = string.match(info[i + 1], "^(%d+)-(%d+)$")
+			local c = compare(ms, lastMs)
+			if c == 0 then c = compare(seq, lastSeq) end
+			if c <= 0 then return false end
+		end
+	end
+end
+return redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[2], ARGV[1], unpack(ARGV, 3))
+`)
+
+func (p *Producer) publish(ctx context.Context, id, eventType string, data any) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	ctx, span := tracing.Start(ctx, "redis.publish", trace.WithSpanKind(trace.SpanKindProducer))
+	values := tracing.MapCarrier{
+		fieldType: eventType,
+		fieldTime: time.Now().UTC().Format(time.RFC3339Nano),
+		fieldData: string(encoded),
+	}
+	tracing.Inject(ctx, values)
+	if id == "*" {
+		id, err = p.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: p.stream,
+			ID:     id,
+			// "~" lets Redis trim whole macro nodes, which is much cheaper than an exact length
+			MaxLen: p.maxLen,
+			Approx: true,
+			Values: map[string]interface{}(values),
+		}).Result()
+	} else {
+		args := []any{id, p.maxLen}
+		for k, v := range values {
+			args = append(args, k, v)
+		}
+		id, err = addAfterLast.Run(ctx, p.client, []string{p.stream}, args...).Text()
+	}
+	// a duplicate is not a failure of the publish
+	tracing.End(span, ignoreNil(err))
+	return id, err
+}
+
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDecode(t *testing.T) {
+	e, err := decode(redis.XMessage{ID: "5-1", Values: map[string]interface{}{
+		fieldType:     "processing.failed",
+		fieldTime:     "2026-01-02T03:04:05.678901Z",
+		fieldData:     `{"document_id":42}`,
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "5-1" || e.Type != "processing.failed" || !e.Time.Equal(time.Date(2026, 1, 2, 3, 4, 5, 678901000, time.UTC)) {
+		t.Errorf("got %+v", e)
+	}
+	var data struct {
+		DocumentID int `json:"document_id"`
+	}
+	if err := e.Decode(&data); err != nil || data.DocumentID != 42 {
+		t.Errorf("decoded %+v (%v)", data, err)
+	}
+
+	for name, values := range map[string]map[string]interface{}{
+		"no type":      {fieldTime: "2026-01-02T03:04:05Z", fieldData: `{}`},
+		"invalid data": {fieldType: "x", fieldTime: "2026-01-02T03:04:05Z", fieldData: `{`},
+		"no data":      {fieldType: "x", fieldTime: "2026-01-02T03:04:05Z"},
+		"invalid time": {fieldType: "x", fieldTime: "yesterday", fieldData: `{}`},
+		"foreign":      {"message": "Critical alert! Server down."},
+	} {
+		if e, err := decode(redis.XMessage{ID: "5-1", Values: values}); err == nil {
+			t.Errorf("%s: decoded %+v, want an error", name, e)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+//go:build integration
+
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wilbyang/law-docs/internal/config"
+	repository "github.com/wilbyang/law-docs/internal/db"
+)
+
+// These tests need a migrated database at DATABASE_URL, connecting as a role that does not bypass
+// row-level security:
+//
+//	go test -tags integration ./internal/tenant
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, config.Load().DatabaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	s := NewStore(pool)
+	bypass, err := s.BypassesRLS(ctx)
+	if err != nil {
+		t.Fatalf("no database: %v", err)
+	}
+	if bypass {
+		t.Skip("the role of DATABASE_URL bypasses row-level security")
+	}
+	return s
+}
+
+// testTenant creates a tenant for the test, deleted afterwards.
+func testTenant(t *testing.T, s *Store) int32 {
+	t.Helper()
+	ctx := context.Background()
+	row, err := s.Queries().CreateTenant(ctx, "test "+rand.Text())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.pool.Exec(ctx, "delete from tenants where id = $1", row.ID) })
+	return row.ID
+}
+
+func TestTenantIsolation(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	a, b := WithID(ctx, testTenant(t, s)), WithID(ctx, testTenant(t, s))
+
+	var doc repository.Document
+	err := s.Tx(a, func(q *repository.Queries) error {
+		var err error
+		doc, err = q.CreateDocument(ctx, repository.CreateDocumentParams{
+			Title:  "Lease",
+			Status: pgtype.Text{String: "draft", Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanups run last first, so before the tenants are deleted
+	t.Cleanup(func() {
+		s.SystemTx(ctx, func(q *repository.Queries) error {
+			_, err := q.DeleteDocument(ctx, doc.ID)
+			return err
+		})
+	})
+	if p, _ := FromContext(a); doc.TenantID != p.TenantID {
+		t.Fatalf("document created for tenant %d, want %d", doc.TenantID, p.TenantID)
+	}
+
+	err = s.Tx(b, func(q *repository.Queries) error {
+		if _, err := q.GetDocumentById(ctx, doc.ID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("another tenant read the document: %v", err)
+		}
+		docs, err := q.ListDocuments(ctx)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(docs, func(d repository.Document) bool { return d.ID == doc.ID }) {
+			t.Error("another tenant listed the document")
+		}
+		if _, err := q.DeleteDocument(ctx, doc.ID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("another tenant deleted the document: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ReadTx(a, func(q *repository.Queries) error {
+		_, err := q.GetDocumentById(ctx, doc.ID)
+		return err
+	}); err != nil {
+		t.Errorf("the owning tenant cannot read its document: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Package tenant isolates each law firm's data.
+//
+// Documents and users carry a tenant_id and are protected by Postgres row-level security
+// policies keyed on the app.tenant_id setting. Store.Tx sets it for the tenant found in the
+// context, so every query a request makes can only see and write that tenant's rows.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+)
+
+// DefaultID is the tenant that owns data created before multi-tenancy was introduced.
+const DefaultID int32 = 1
+
+// ErrNoTenant is returned by Store.Tx when the context does not carry a tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+type principalKey struct{}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	TenantID int32
+	UserID   int32
+}
+
+// WithPrincipal returns a context acting for p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// WithID returns a context acting for a tenant without a specific user, e.g. a background job.
+func WithID(ctx context.Context, id int32) context.Context {
+	return WithPrincipal(ctx, Principal{TenantID: id})
+}
+
+// FromContext returns the caller stored by WithPrincipal or WithID.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok && p.TenantID != 0
+}
+
+// StoragePrefix is the object key prefix under which a tenant's files are stored.
+func StoragePrefix(id int32) string {
+	return fmt.Sprintf("tenants/%d/", id)
+}
+
+// Store runs queries in transactions scoped by the row-level security policies.
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+func NewStore(pool *pgxpool.Pool) *Store {
+	return &Store{pool: pool}
+}
+
+// Tx runs fn in a transaction that only sees rows of the tenant in ctx.
+func (s *Store) Tx(ctx context.Context, fn func(q *repository.Queries) error) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	return s.tx(ctx, pgx.TxOptions{}, "app.tenant_id", strconv.Itoa(int(p.TenantID)), fn)
+}
+
+// ReadTx runs fn in a read-only transaction that only sees rows of the tenant in ctx.
+func (s *Store) ReadTx(ctx context.Context, fn func(q *repository.Queries) error) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	return s.tx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, "app.tenant_id", strconv.Itoa(int(p.TenantID)), fn)
+}
+
+// SystemTx runs fn in a transaction that sees every tenant's rows.
+// Use it only for work that is not done on behalf of a tenant, such as metrics and operator commands.
+func (s *Store) SystemTx(ctx context.Context, fn func(q *repository.Queries) error) error {
+	return s.tx(ctx, pgx.TxOptions{}, "app.system", "on", fn)
+}
+
+func (s *Store) tx(ctx context.Context, opts pgx.TxOptions, setting, value string, fn func(q *repository.Queries) error) error {
+	return pgx.BeginTxFunc(ctx, s.pool, opts, func(tx pgx.Tx) error {
+		// is_local=true scopes the setting to this transaction so it never leaks to the next pool user
+		if _, err := tx.Exec(ctx, "select set_config($1, $2, true)", setting, value); err != nil {
+			return err
+		}
+		return fn(repository.New(tx))
+	})
+}
+
+// Queries returns queries for the tables that are not tenant-scoped, such as tenants and api_tokens.
+func (s *Store) Queries() *repository.Queries {
+	return repository.New(s.pool)
+}
+
+// BypassesRLS reports whether the connected role ignores row-level security,
+// which superusers and roles with BYPASSRLS do regardless of the policies.
+func (s *Store) BypassesRLS(ctx context.Context) (bool, error) {
+	var bypass bool
+	err := s.pool.QueryRow(ctx, "select rolsuper or rolbypassrls from pg_roles where rolname = current_user").Scan(&bypass)
+	return bypass, err
+}
@@ -0,0 +1,46 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+)
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := FromContext(ctx); ok {
+		t.Error("found a principal in an empty context")
+	}
+	if _, ok := FromContext(WithID(ctx, 0)); ok {
+		t.Error("tenant 0 is not a tenant")
+	}
+	p := Principal{TenantID: 7, UserID: 3}
+	if got, ok := FromContext(WithPrincipal(ctx, p)); !ok || got != p {
+		t.Errorf("got %+v, %v; want %+v", got, ok, p)
+	}
+	if got, ok := FromContext(WithID(ctx, 7)); !ok || got != (Principal{TenantID: 7}) {
+		t.Errorf("got %+v, %v; want tenant 7 without a user", got, ok)
+	}
+}
+
+func TestTxWithoutTenant(t *testing.T) {
+	// the store has no pool: refusing must come before any query
+	s := NewStore(nil)
+	for name, ctx := range map[string]context.Context{
+		"no principal": context.Background(),
+		"tenant 0":     WithID(context.Background(), 0),
+	} {
+		fn := func(*repository.Queries) error {
+			t.Errorf("%s: ran the transaction", name)
+			return nil
+		}
+		if err := s.Tx(ctx, fn); !errors.Is(err, ErrNoTenant) {
+			t.Errorf("%s: Tx returned %v, want ErrNoTenant", name, err)
+		}
+		if err := s.ReadTx(ctx, fn); !errors.Is(err, ErrNoTenant) {
+			t.Errorf("%s: ReadTx returned %v, want ErrNoTenant", name, err)
+		}
+	}
+}
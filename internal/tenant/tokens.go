@@ -0,0 +1,62 @@
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+)
+
+// tokenPrefix makes leaked tokens easy to recognise in logs and secret scanners.
+const tokenPrefix = "ldt_"
+
+// ErrInvalidToken is returned by Authenticate for unknown or revoked tokens.
+var ErrInvalidToken = errors.New("invalid API token")
+
+// HashToken returns the value stored in api_tokens.token_hash; tokens themselves are never stored.
+func HashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// IssueToken creates an API token acting as user within the user's tenant and returns it.
+// The token cannot be recovered later, only revoked.
+func (s *Store) IssueToken(ctx context.Context, userID int32, name string) (string, repository.ApiToken, error) {
+	var user repository.User
+	err := s.SystemTx(ctx, func(q *repository.Queries) error {
+		var err error
+		user, err = q.GetUserById(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return "", repository.ApiToken{}, err
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := tokenPrefix + hex.EncodeToString(b)
+	row, err := s.Queries().CreateAPIToken(ctx, repository.CreateAPITokenParams{
+		TenantID:  user.TenantID,
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: HashToken(token),
+	})
+	return token, row, err
+}
+
+// Authenticate resolves an API token to the tenant and user it acts for.
+func (s *Store) Authenticate(ctx context.Context, token string) (Principal, error) {
+	row, err := s.Queries().GetAPITokenByHash(ctx, HashToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Principal{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{TenantID: row.TenantID, UserID: row.UserID}, nil
+}
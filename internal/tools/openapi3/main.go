@@ -0,0 +1,109 @@
+// Command openapi3 converts the Swagger 2.0 document written by swag into OpenAPI 3.
+// It is run by go generate in the docs package.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func main() {
+	in := flag.String("in", "swagger.json", "Swagger 2.0 input")
+	out := flag.String("out", "openapi.json", "OpenAPI 3 output")
+	flag.Parse()
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var v2 openapi2.T
+	if err := json.Unmarshal(data, &v2); err != nil {
+		log.Fatalf("parse %s: %v", *in, err)
+	}
+	v3, err := openapi2conv.ToV3(&v2)
+	if err != nil {
+		log.Fatalf("convert %s: %v", *in, err)
+	}
+
+	// Swagger 2.0 can only express the bearer scheme as an API key header.
+	if scheme := v3.Components.SecuritySchemes["BearerAuth"]; scheme != nil && scheme.Value != nil {
+		scheme.Value = openapi3.NewJWTSecurityScheme().WithDescription(scheme.Value.Description)
+		scheme.Value.BearerFormat = "opaque"
+	}
+	problemContent(v3)
+
+	data, err = json.MarshalIndent(v3, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = shortSchemaNames(v3, data)
+
+	loader := openapi3.NewLoader()
+	v3, err = loader.LoadFromData(data)
+	if err != nil {
+		log.Fatalf("reload OpenAPI 3 document: %v", err)
+	}
+	if err := v3.Validate(loader.Context); err != nil {
+		log.Fatalf("invalid OpenAPI 3 document: %v", err)
+	}
+	data, err = json.MarshalIndent(v3, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+const problemSchema = "#/components/schemas/api.Problem"
+
+// problemContent declares error responses as application/problem+json, which swag cannot express per response.
+func problemContent(doc *openapi3.T) {
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			for _, resp := range op.Responses.Map() {
+				if resp.Value == nil {
+					continue
+				}
+				// operations producing something else, such as an event stream, still answer
+				// errors with a problem
+				for _, media := range resp.Value.Content {
+					if media.Schema != nil && media.Schema.Ref == problemSchema {
+						resp.Value.Content = openapi3.Content{"application/problem+json": media}
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// shortSchemaNames drops swag's package prefix from schema names (api.DocumentResponse becomes
+// DocumentResponse) so generated clients get readable type names. Prefixes are kept on collisions.
+func shortSchemaNames(doc *openapi3.T, data []byte) []byte {
+	seen := map[string]int{}
+	for name := range doc.Components.Schemas {
+		seen[short(name)]++
+	}
+	var replacements []string
+	for name := range doc.Components.Schemas {
+		if s := short(name); s != name && seen[s] == 1 {
+			replacements = append(replacements,
+				strconv.Quote("#/components/schemas/"+name), strconv.Quote("#/components/schemas/"+s),
+				strconv.Quote(name)+":", strconv.Quote(s)+":")
+		}
+	}
+	return []byte(strings.NewReplacer(replacements...).Replace(string(data)))
+}
+
+func short(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,272 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/events"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// Options configures a Dispatcher. Zero values select the defaults.
+type Options struct {
+	// Interval is how often due deliveries are looked for, 1s by default. Events enqueued by the
+	// same Dispatcher are sent right away.
+	Interval time.Duration
+	// Batch is the number of deliveries claimed and sent at once, 10 by default.
+	Batch int
+	// Timeout bounds each request, 10s by default.
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts after which a delivery has failed, 6 by default.
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt, 30s by default. It quadruples with
+	// every further attempt, so by default a delivery is given up after about 3 hours.
+	Backoff time.Duration
+	// DisableAfter is the number of failed deliveries in a row after which a webhook is
+	// disabled, 5 by default.
+	DisableAfter int
+	// Client sends the requests. The default follows no redirects and refuses to connect to
+	// loopback, private and link-local addresses, see Blocked.
+	Client *http.Client
+}
+
+// Dispatcher stores deliveries for document events and sends them.
+type Dispatcher struct {
+	store *tenant.Store
+	opts  Options
+	log   *slog.Logger
+	// wake is signalled when deliveries were enqueued
+	wake chan struct{}
+}
+
+// New creates a Dispatcher.
+func New(store *tenant.Store, opts Options) *Dispatcher {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = 10
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 6
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 30 * time.Second
+	}
+	if opts.DisableAfter <= 0 {
+		opts.DisableAfter = 5
+	}
+	if opts.Client == nil {
+		opts.Client = newClient()
+	}
+	return &Dispatcher{store: store, opts: opts, log: logging.Component("webhooks"), wake: make(chan struct{}, 1)}
+}
+
+// Enqueue stores a delivery of e for each of the tenant's enabled webhooks subscribed to its type.
+// It is a handler for events.NewFeed. Every replica calls it for the same notification, and a
+// replayed event repeats one seen before, but a document version is delivered once per webhook.
+func (d *Dispatcher) Enqueue(e events.DocumentEvent) error {
+	types := []string{e.Type}
+	if e.Replayed && e.Type == events.DocumentUpdated {
+		// the change missed while reconnecting may have been a status change
+		types = append(types, events.DocumentStatusChanged)
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(tenant.WithID(context.Background(), e.TenantID), d.opts.Timeout)
+	defer cancel()
+	var n int64
+	err = d.store.Tx(ctx, func(q *repository.Queries) error {
+		n, err = q.EnqueueWebhookDeliveries(ctx, repository.EnqueueWebhookDeliveriesParams{
+			EventType:     e.Type,
+			EventKey:      fmt.Sprintf("%d@%s", e.DocumentID, e.UpdatedAt.Format(time.RFC3339Nano)),
+			Payload:       payload,
+			NextAttemptAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			Events:        types,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to enqueue webhook deliveries: %w", err)
+	}
+	if n > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+	for {
+		claimed, err := d.dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.log.ErrorContext(ctx, "Failed to dispatch webhook deliveries", "error", err)
+		}
+		if claimed == d.opts.Batch {
+			// more may be due
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatch claims a batch of due deliveries, sends them concurrently and records the outcomes.
+// Claiming moves a delivery's next attempt past the time the batch may take, so other replicas
+// leave it alone, and it is retried then if this one dies meanwhile.
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	var claimed []repository.ClaimWebhookDeliveriesRow
+	err := d.store.SystemTx(ctx, func(q *repository.Queries) error {
+		var err error
+		claimed, err = q.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+			LeaseUntil: pgtype.Timestamp{Time: now.Add(d.opts.Timeout + time.Minute), Valid: true},
+			Now:        pgtype.Timestamp{Time: now, Valid: true},
+			MaxRows:    int32(d.opts.Batch),
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, c := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, failure := d.send(ctx, c)
+			if err := d.record(context.WithoutCancel(ctx), c.WebhookDelivery, status, failure); err != nil {
+				d.log.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", c.WebhookDelivery.ID, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return len(claimed), nil
+}
+
+// send makes one attempt at a delivery. It returns the response status, if there was a response,
+// and why the attempt failed, if it did.
+func (d *Dispatcher) send(ctx context.Context, c repository.ClaimWebhookDeliveriesRow) (int, string) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	delivery := c.WebhookDelivery
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lawdocs-webhooks")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(int(delivery.ID)))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(c.Secret, timestamp, delivery.Payload))
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	// drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, "unexpected status " + resp.Status
+	}
+	return resp.StatusCode, ""
+}
+
+// maxBackoff caps the delay between attempts when MaxAttempts is raised.
+const maxBackoff = 24 * time.Hour
+
+// backoff is the delay after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.Backoff
+	for range attempts - 1 {
+		if delay *= 4; delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// record stores the outcome of an attempt and, when a delivery succeeds or finally fails, updates
+// its webhook's failure count.
+func (d *Dispatcher) record(ctx context.Context, delivery repository.WebhookDelivery, status int, failure string) error {
+	now := time.Now()
+	attempts := int(delivery.Attempts) + 1
+	params := repository.RecordWebhookAttemptParams{
+		ID:             delivery.ID,
+		Status:         StatusSucceeded,
+		ResponseStatus: pgtype.Int4{Int32: int32(status), Valid: status != 0},
+		LastError:      pgtype.Text{String: failure, Valid: failure != ""},
+		NextAttemptAt:  pgtype.Timestamp{Time: now, Valid: true},
+	}
+	switch {
+	case failure == "":
+		params.DeliveredAt = pgtype.Timestamp{Time: now, Valid: true}
+	case attempts < d.opts.MaxAttempts:
+		params.Status = StatusPending
+		params.NextAttemptAt.Time = now.Add(d.backoff(attempts))
+	default:
+		params.Status = StatusFailed
+	}
+	outcome := params.Status
+	if outcome == StatusPending {
+		outcome = "retrying"
+	}
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+	log := d.log.With("delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "tenant_id", delivery.TenantID)
+	return d.store.SystemTx(ctx, func(q *repository.Queries) error {
+		if _, err := q.RecordWebhookAttempt(ctx, params); err != nil {
+			return err
+		}
+		switch params.Status {
+		case StatusSucceeded:
+			_, err := q.ResetWebhookFailures(ctx, delivery.WebhookID)
+			return err
+		case StatusPending:
+			log.InfoContext(ctx, "Webhook delivery failed, retrying", "attempts", attempts, "error", failure,
+				"next_attempt_at", params.NextAttemptAt.Time)
+			return nil
+		}
+		log.WarnContext(ctx, "Webhook delivery failed", "attempts", attempts, "error", failure)
+		webhook, err := q.RecordWebhookFailure(ctx, repository.RecordWebhookFailureParams{
+			DisableAfter: int32(d.opts.DisableAfter),
+			Now:          pgtype.Timestamp{Time: now, Valid: true},
+			ID:           delivery.WebhookID,
+		})
+		if err != nil {
+			return err
+		}
+		if !webhook.Enabled && webhook.ConsecutiveFailures == int32(d.opts.DisableAfter) {
+			log.WarnContext(ctx, "Disabled webhook after repeated failures", "failures", webhook.ConsecutiveFailures)
+		}
+		return nil
+	})
+}
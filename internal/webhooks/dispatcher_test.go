@@ -0,0 +1,135 @@
+//go:build integration
+
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wilbyang/law-docs/internal/config"
+	repository "github.com/wilbyang/law-docs/internal/db"
+	"github.com/wilbyang/law-docs/internal/events"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// This test needs a migrated database at DATABASE_URL. It dispatches every due delivery in it, so
+// point it at a database of its own:
+//
+//	go test -tags integration ./internal/webhooks
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, config.Load().DatabaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	store := tenant.NewStore(pool)
+	owner, err := store.Queries().CreateTenant(ctx, "test "+rand.Text())
+	if err != nil {
+		t.Fatalf("no database: %v", err)
+	}
+	defer pool.Exec(ctx, "delete from tenants where id = $1", owner.ID)
+
+	// the receiver counts the requests it can verify, and fails them all
+	var received, verified atomic.Int32
+	const secret = "whsec_test"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if Verify(secret, r.Header.Get(HeaderSignature), timestamp, body) && r.Header.Get(HeaderEvent) == events.DocumentCreated {
+			verified.Add(1)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ownerCtx := tenant.WithID(ctx, owner.ID)
+	var webhook repository.Webhook
+	err = store.Tx(ownerCtx, func(q *repository.Queries) error {
+		webhook, err = q.CreateWebhook(ctx, repository.CreateWebhookParams{
+			Url:    srv.URL,
+			Events: []string{events.DocumentCreated},
+			Secret: secret,
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.SystemTx(ctx, func(q *repository.Queries) error {
+		_, err := q.DeleteWebhook(ctx, webhook.ID)
+		return err
+	})
+
+	// loopback receivers are only reachable with a client of the test's own
+	d := New(store, Options{MaxAttempts: 1, DisableAfter: 2, Client: srv.Client()})
+	updated := time.Now().UTC()
+	event := func(at time.Time) events.DocumentEvent {
+		return events.DocumentEvent{Type: events.DocumentCreated, DocumentID: 42, TenantID: owner.ID, UpdatedAt: at}
+	}
+	deliveries := func() int {
+		var n int
+		store.Tx(ownerCtx, func(q *repository.Queries) error {
+			rows, err := q.ListWebhookDeliveries(ctx, repository.ListWebhookDeliveriesParams{WebhookID: webhook.ID, MaxRows: 100})
+			n = len(rows)
+			return err
+		})
+		return n
+	}
+	webhookState := func() repository.Webhook {
+		var w repository.Webhook
+		store.Tx(ownerCtx, func(q *repository.Queries) error {
+			var err error
+			w, err = q.GetWebhook(ctx, webhook.ID)
+			return err
+		})
+		return w
+	}
+
+	// every replica enqueues the same notification, and reconnecting replays it
+	for range 3 {
+		if err := d.Enqueue(event(updated)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := deliveries(); n != 1 {
+		t.Fatalf("%d deliveries of one document version, want 1", n)
+	}
+	if _, err := d.dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if received.Load() != 1 || verified.Load() != 1 {
+		t.Fatalf("received %d requests, %d verified; want 1 signed request", received.Load(), verified.Load())
+	}
+	if w := webhookState(); !w.Enabled || w.ConsecutiveFailures != 1 {
+		t.Errorf("after one failed delivery: enabled %v with %d failures", w.Enabled, w.ConsecutiveFailures)
+	}
+
+	// the second failed delivery in a row disables the webhook
+	if err := d.Enqueue(event(updated.Add(time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if w := webhookState(); w.Enabled || w.ConsecutiveFailures != 2 || !w.DisabledAt.Valid {
+		t.Errorf("after two failed deliveries: enabled %v with %d failures, disabled at %v", w.Enabled, w.ConsecutiveFailures, w.DisabledAt)
+	}
+	if err := d.Enqueue(event(updated.Add(2 * time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if n := deliveries(); n != 2 {
+		t.Errorf("%d deliveries, want 2 and none for the disabled webhook", n)
+	}
+}
@@ -0,0 +1,82 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for webhook URLs and connections that would reach the server's own
+// network rather than the internet: loopback, private, link-local, shared and unspecified addresses.
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// sharedAddresses is the carrier-grade NAT range, which IsPrivate does not cover.
+var sharedAddresses = netip.MustParsePrefix("100.64.0.0/10")
+
+// Blocked reports whether deliveries must not be sent to addr.
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || sharedAddresses.Contains(addr)
+}
+
+// CheckURL returns an error unless raw is an http or https URL whose host is not, and does not
+// currently resolve to, a Blocked address. It catches mistakes early; the Dispatcher checks every
+// connection again, since DNS answers change.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("must be an http or https URL")
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if Blocked(addr) {
+			return fmt.Errorf("host %s: %w", host, ErrBlockedAddress)
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	// a host that does not resolve yet may be set up later; dialing it is checked then
+	addrs, _ := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	for _, addr := range addrs {
+		if Blocked(addr) {
+			return fmt.Errorf("host %s resolves to %s: %w", host, addr, ErrBlockedAddress)
+		}
+	}
+	return nil
+}
+
+// control refuses connections to Blocked addresses. It runs after name resolution, on the
+// address actually dialed, so a host cannot pass CheckURL and later resolve to the server's own
+// network.
+func control(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected address %q: %w", address, err)
+	}
+	if Blocked(addr.Addr()) {
+		return fmt.Errorf("dial %s %s: %w", network, address, ErrBlockedAddress)
+	}
+	return nil
+}
+
+// newClient returns the Dispatcher's default client. It follows no redirects, since a redirect
+// would send the signed event somewhere the tenant did not configure, uses no proxy, which would
+// dial on its behalf, and refuses Blocked addresses.
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: 10 * time.Second, Control: control}).DialContext
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	for addr, blocked := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00::1":          true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	} {
+		if got := Blocked(netip.MustParseAddr(addr)); got != blocked {
+			t.Errorf("Blocked(%s) = %v, want %v", addr, got, blocked)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://93.184.216.34/hook":     true,
+		"ftp://93.184.216.34/hook":       false,
+		"https:///hook":                  false,
+		"http://127.0.0.1:6379/":         false,
+		"http://169.254.169.254/latest/": false,
+		"http://[::1]:8080/":             false,
+		"http://localhost:8080/":         false,
+	} {
+		if err := CheckURL(context.Background(), raw); (err == nil) != ok {
+			t.Errorf("CheckURL(%s) = %v, want ok %v", raw, err, ok)
+		}
+	}
+}
+
+func TestClientRefusesBlockedAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, err := newClient().Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("request to %s: got %v, want ErrBlockedAddress", srv.URL, err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package webhooks delivers document events to the URLs a tenant subscribes. Each event is stored
+// as a delivery of every matching webhook before anything is sent, so deliveries survive restarts,
+// and any replica may send them: a Dispatcher claims due deliveries with row locks. Requests are
+// signed with the webhook's secret and failed ones are retried with exponential backoff. A webhook
+// whose deliveries keep failing is disabled until the tenant enables it again.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/wilbyang/law-docs/internal/events"
+)
+
+// Events are the event types a webhook can subscribe to.
+var Events = []string{events.DocumentCreated, events.DocumentUpdated, events.DocumentStatusChanged}
+
+// Statuses of a delivery.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Headers of a delivery request. The body is the event as JSON.
+const (
+	HeaderEvent    = "X-Lawdocs-Event"
+	HeaderDelivery = "X-Lawdocs-Delivery"
+	// HeaderTimestamp is the Unix time of the attempt, which receivers check to reject replays.
+	HeaderTimestamp = "X-Lawdocs-Timestamp"
+	// HeaderSignature is "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+	// webhook's secret.
+	HeaderSignature = "X-Lawdocs-Signature"
+)
+
+// NewSecret returns a random secret for a webhook that was created without one.
+func NewSecret() string {
+	return "whsec_" + rand.Text()
+}
+
+// Sign returns the HeaderSignature of a request sent at timestamp with body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the HeaderSignature of a request sent at timestamp with
+// body, comparing in constant time. Receivers written in Go can use it as is.
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
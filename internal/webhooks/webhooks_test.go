@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	const secret, timestamp = "whsec_test", 1700000000
+	body := []byte(`{"document_id":42}`)
+	// HMAC-SHA256 of "1700000000.{"document_id":42}", as receivers in any language compute it
+	want := "sha256=f50d13a1e7d3a8a9e2f3161d1434db088aa0473062c43423a968056424b3cf2f"
+	signature := Sign(secret, timestamp, body)
+	if signature != want {
+		t.Fatalf("got %s, want %s", signature, want)
+	}
+	if !Verify(secret, signature, timestamp, body) {
+		t.Error("a signature does not verify")
+	}
+
+	for name, verified := range map[string]bool{
+		"other body":      Verify(secret, signature, timestamp, []byte(`{"document_id":43}`)),
+		"other timestamp": Verify(secret, signature, timestamp+1, body),
+		"other secret":    Verify("whsec_other", signature, timestamp, body),
+		"upper case":      Verify(secret, strings.ToUpper(signature), timestamp, body),
+		"no prefix":       Verify(secret, strings.TrimPrefix(signature, "sha256="), timestamp, body),
+		"truncated":       Verify(secret, signature[:len(signature)-2], timestamp, body),
+		"empty":           Verify(secret, "", timestamp, body),
+	} {
+		if verified {
+			t.Errorf("%s: verified", name)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, b := NewSecret(), NewSecret()
+	if !strings.HasPrefix(a, "whsec_") || len(a) < len("whsec_")+26 || a == b {
+		t.Errorf("got %q and %q", a, b)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(nil, Options{})
+	var total time.Duration
+	for attempts, want := range []time.Duration{
+		30 * time.Second,
+		2 * time.Minute,
+		8 * time.Minute,
+		32 * time.Minute,
+		128 * time.Minute,
+	} {
+		if got := d.backoff(attempts + 1); got != want {
+			t.Errorf("after %d failed attempts: got %s, want %s", attempts+1, got, want)
+		}
+		total += want
+	}
+	// the sixth attempt is the last
+	if total < 2*time.Hour || total > 3*time.Hour {
+		t.Errorf("gives up after %s", total)
+	}
+
+	d = New(nil, Options{Backoff: time.Hour, MaxAttempts: 100})
+	for attempts, want := range map[int]time.Duration{1: time.Hour, 2: 4 * time.Hour, 3: 16 * time.Hour, 4: maxBackoff, 99: maxBackoff} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("capped, after %d failed attempts: got %s, want %s", attempts, got, want)
+		}
+	}
+}
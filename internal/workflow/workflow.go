@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	"context"
+
+	"github.com/looplab/fsm"
+)
+
+// Document statuses, matching the check constraint on documents.status.
+const (
+	StatusDraft        = "draft"
+	StatusPreProcessed = "pre-processed"
+	StatusAuditing     = "auditing"
+	StatusAudited      = "audited"
+)
+
+// Events that move a document between statuses.
+const (
+	EventPreprocess    = "preprocess"
+	EventStartAudit    = "start-audit"
+	EventCompleteAudit = "complete-audit"
+	EventReopen        = "reopen"
+)
+
+var events = fsm.Events{
+	{Name: EventPreprocess, Src: []string{StatusDraft}, Dst: StatusPreProcessed},
+	{Name: EventStartAudit, Src: []string{StatusPreProcessed}, Dst: StatusAuditing},
+	{Name: EventCompleteAudit, Src: []string{StatusAuditing}, Dst: StatusAudited},
+	{Name: EventReopen, Src: []string{StatusAudited}, Dst: StatusAuditing},
+}
+
+// New returns a state machine positioned at the given document status.
+func New(status string) *fsm.FSM {
+	if status == "" {
+		status = StatusDraft
+	}
+	return fsm.NewFSM(status, events, fsm.Callbacks{})
+}
+
+// Next returns the status reached by applying event to status,
+// or an error if the transition is not allowed.
+func Next(ctx context.Context, status, event string) (string, error) {
+	machine := New(status)
+	if err := machine.Event(ctx, event); err != nil {
+		return status, err
+	}
+	return machine.Current(), nil
+}
+
+// EventFor returns the event that moves a document from one status to another,
+// or false if no single transition connects them.
+func EventFor(from, to string) (string, bool) {
+	if from == "" {
+		from = StatusDraft
+	}
+	for _, e := range events {
+		if e.Dst != to {
+			continue
+		}
+		for _, src := range e.Src {
+			if src == from {
+				return e.Name, true
+			}
+		}
+	}
+	return "", false
+}
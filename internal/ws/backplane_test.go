@@ -0,0 +1,124 @@
+package ws_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/wilbyang/law-docs/internal/backplane"
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+// node starts a hub on the cluster and returns it with its websocket URL.
+func node(t *testing.T, ctx context.Context, cluster *backplane.Memory, id string) (*ws.Hub, string) {
+	t.Helper()
+	hub := ws.NewHub(ws.Options{
+		Authorize: func(tenant.Principal, ws.Action, string) bool { return true },
+		Backplane: cluster.Node(id),
+	})
+	runCtx, stop := context.WithCancel(ctx)
+	t.Cleanup(stop)
+	go hub.Run(runCtx)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tenant.WithPrincipal(r.Context(), tenant.Principal{TenantID: 7, UserID: 1})
+		hub.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(srv.Close)
+	return hub, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// subscribe connects to url and subscribes to pattern.
+func subscribe(t *testing.T, ctx context.Context, url, pattern string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	var welcome ws.Message
+	if err := wsjson.Read(ctx, conn, &welcome); err != nil || welcome.Type != ws.TypeWelcome {
+		t.Fatalf("got %+v (%v), want a welcome", welcome, err)
+	}
+	if err := wsjson.Write(ctx, conn, ws.Message{Type: ws.TypeSubscribe, Topic: pattern}); err != nil {
+		t.Fatal(err)
+	}
+	var confirmation ws.Message
+	if err := wsjson.Read(ctx, conn, &confirmation); err != nil || confirmation.Type != ws.TypeConfirmation {
+		t.Fatalf("got %+v (%v), want a confirmation", confirmation, err)
+	}
+	return conn
+}
+
+// next reads the next published message of conn.
+func next(t *testing.T, ctx context.Context, conn *websocket.Conn) ws.Message {
+	t.Helper()
+	var msg ws.Message
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != ws.TypePublish {
+		t.Fatalf("got %+v, want a published message", msg)
+	}
+	return msg
+}
+
+func TestBackplaneFanOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cluster := backplane.NewMemory()
+	a, urlA := node(t, ctx, cluster, "a")
+	b, urlB := node(t, ctx, cluster, "b")
+	topic := "tenants.7.documents.42"
+	onA := subscribe(t, ctx, urlA, topic)
+	onB := subscribe(t, ctx, urlB, "tenants.7.documents.>")
+
+	// each node delivers a message published on it once, not again when the backplane would echo
+	// it back, and numbers the messages of other nodes in its own epoch
+	a.Publish(topic, "from a")
+	first := []ws.Message{next(t, ctx, onA), next(t, ctx, onB)}
+	b.Publish(topic, "from b")
+	for i, conn := range []*websocket.Conn{onA, onB} {
+		second := next(t, ctx, conn)
+		if first[i].Payload != "from a" || second.Payload != "from b" {
+			t.Errorf("got %v then %v, want the message of a then the one of b", first[i].Payload, second.Payload)
+		}
+		if first[i].Seq != 1 || second.Seq != 2 || first[i].Epoch != second.Epoch {
+			t.Errorf("got seq %d and %d in epochs %s and %s, want 1 and 2 in one epoch",
+				first[i].Seq, second.Seq, first[i].Epoch, second.Epoch)
+		}
+	}
+}
+
+func TestBackplaneRetained(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cluster := backplane.NewMemory()
+	a, _ := node(t, ctx, cluster, "a")
+	_, urlB := node(t, ctx, cluster, "b")
+	topic := "tenants.7.documents.42.status"
+	watcher := subscribe(t, ctx, urlB, "tenants.7.>")
+
+	a.PublishRetained(topic, "auditing")
+	// local events are learned by every node itself and not forwarded
+	a.PublishLocal(topic, "local only", true)
+	a.Publish("tenants.7.marker", "done")
+	if msg := next(t, ctx, watcher); msg.Payload != "auditing" || !msg.Retain {
+		t.Errorf("got %+v, want the retained status", msg)
+	}
+	if msg := next(t, ctx, watcher); msg.Payload != "done" {
+		t.Errorf("got %+v, want the marker after the forwarded messages only", msg)
+	}
+
+	// a later subscriber of the other node gets the retained value first
+	late := subscribe(t, ctx, urlB, topic)
+	if msg := next(t, ctx, late); msg.Payload != "auditing" || msg.Seq != 1 {
+		t.Errorf("got %+v, want the retained status as seq 1", msg)
+	}
+}
@@ -0,0 +1,235 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/wilbyang/law-docs/internal/metrics"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// readLimit is the largest message a client may send.
+const readLimit = 64 << 10
+
+// client is one websocket connection. Only its write goroutine writes to conn; everything
+// else, including replies to the client's own requests, goes through queue.
+type client struct {
+	id        string
+	hub       *Hub
+	conn      *websocket.Conn
+	principal tenant.Principal
+	queue     chan []byte
+	// patterns is guarded by hub.mu
+	patterns map[string]struct{}
+	// acked holds the position of each topic the client has acknowledged or started from. It is
+	// only used by the read goroutine and kept in the client's session.
+	acked     map[string]uint64
+	slow      atomic.Bool
+	closeOnce sync.Once
+}
+
+// ServeHTTP upgrades an authenticated request to a websocket and serves the protocol until the
+// client disconnects. The caller must have put a tenant.Principal in the request context;
+// requests without one are refused with 401 before the upgrade. A session query parameter with
+// the ID of the welcome message of an earlier connection restores its subscriptions.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := tenant.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+		return
+	}
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.opts.OriginPatterns})
+	if err != nil {
+		h.log.WarnContext(r.Context(), "Failed to accept websocket", "error", err)
+		return
+	}
+	conn.SetReadLimit(readLimit)
+	c := &client{
+		id:        newConnID(),
+		hub:       h,
+		conn:      conn,
+		principal: principal,
+		queue:     make(chan []byte, h.opts.SendBuffer),
+		patterns:  map[string]struct{}{},
+		acked:     map[string]uint64{},
+	}
+	metrics.WebsocketClients.Inc()
+	defer metrics.WebsocketClients.Dec()
+
+	s := h.takeSession(r.URL.Query().Get("session"), principal)
+	greeting := welcome{TenantID: principal.TenantID, UserID: principal.UserID}
+	if s != nil {
+		c.id, c.acked = r.URL.Query().Get("session"), s.acked
+		greeting.Resumed, greeting.Topics = true, s.patterns
+	}
+	c.sendMessage(Message{Type: TypeWelcome, ID: c.id, Epoch: h.opts.Epoch, Payload: greeting})
+	if s != nil {
+		for _, pattern := range s.patterns {
+			h.subscribeReplay(c, Message{Topic: pattern, Epoch: h.opts.Epoch, Resume: s.acked}, true)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		c.writeLoop(ctx)
+	}()
+	c.readLoop(ctx)
+
+	// no publisher can reach c once it is unsubscribed, and queue is never closed, so a
+	// concurrent send only fills the buffer that is dropped with c
+	patterns := h.patternList(c)
+	h.unsubscribeAll(c)
+	cancel()
+	<-written
+	c.close(websocket.StatusNormalClosure, "")
+	h.saveSession(c, patterns)
+	h.closed(c)
+}
+
+func (c *client) readLoop(ctx context.Context) {
+	for {
+		var msg Message
+		if err := wsjson.Read(ctx, c.conn, &msg); err != nil {
+			if websocket.CloseStatus(err) == -1 && !errors.Is(err, context.Canceled) {
+				c.hub.log.DebugContext(ctx, "Websocket read ended", "error", err)
+			}
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *client) handle(msg Message) {
+	h := c.hub
+	switch msg.Type {
+	case TypeSubscribe:
+		if err := validPattern(msg.Topic); err != nil {
+			c.reply(msg, TypeError, err.Error())
+			return
+		}
+		if !h.authorized(c.principal, Subscribe, msg.Topic) {
+			c.reply(msg, TypeError, "Not allowed to subscribe to this topic")
+			return
+		}
+		h.subscribeReplay(c, msg, false)
+
+	case TypeUnsubscribe:
+		h.unsubscribe(msg.Topic, c)
+		c.reply(msg, TypeConfirmation, "Unsubscribed successfully")
+
+	case TypePublish:
+		if err := validTopic(msg.Topic); err != nil {
+			c.reply(msg, TypeError, err.Error())
+			return
+		}
+		if !h.authorized(c.principal, Publish, msg.Topic) {
+			c.reply(msg, TypeError, "Not allowed to publish to this topic")
+			return
+		}
+		published, err := h.publish(msg, true)
+		if err != nil {
+			c.reply(msg, TypeError, "Failed to publish message")
+			return
+		}
+		c.sendMessage(Message{
+			Type:    TypeConfirmation,
+			Topic:   msg.Topic,
+			ID:      published.ID,
+			Seq:     published.Seq,
+			Epoch:   published.Epoch,
+			Payload: "Message published successfully",
+		})
+
+	case TypeAck:
+		// acks are not answered; positions are only kept for topics the client subscribes to
+		if msg.Seq > c.acked[msg.Topic] && h.subscribed(c, msg.Topic) {
+			c.acked[msg.Topic] = msg.Seq
+		}
+
+	default:
+		if fn, ok := h.handler(msg.Type); ok {
+			fn(Conn{c}, msg)
+			return
+		}
+		c.reply(msg, TypeError, "Unknown message type")
+	}
+}
+
+// reply queues a response to the request req of the client.
+func (c *client) reply(req Message, msgType string, payload interface{}) {
+	c.sendMessage(Message{Type: msgType, Topic: req.Topic, ID: req.ID, Payload: payload})
+}
+
+// sendMessage encodes msg and queues it for the client.
+func (c *client) sendMessage(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.hub.log.Error("Failed to encode reply", "topic", msg.Topic, "error", err)
+		return
+	}
+	c.send(data)
+}
+
+// send queues data without blocking. A client whose queue is full is disconnected.
+func (c *client) send(data []byte) {
+	select {
+	case c.queue <- data:
+	default:
+		if !c.slow.CompareAndSwap(false, true) {
+			return
+		}
+		c.hub.log.Info("Disconnecting slow websocket client", "tenant_id", c.principal.TenantID, "user_id", c.principal.UserID)
+		metrics.WebsocketDropped.Inc()
+		// closing waits for the close handshake, which must not hold up the publisher
+		go c.close(websocket.StatusPolicyViolation, "client too slow")
+	}
+}
+
+// writeLoop drains queue and pings the client until ctx is done or a write fails.
+func (c *client) writeLoop(ctx context.Context) {
+	ping := time.NewTicker(c.hub.opts.PingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-c.queue:
+			err = c.write(ctx, func(ctx context.Context) error {
+				return c.conn.Write(ctx, websocket.MessageText, data)
+			})
+		case <-ping.C:
+			err = c.write(ctx, c.conn.Ping)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				c.hub.log.DebugContext(ctx, "Websocket write failed", "error", err)
+				c.close(websocket.StatusGoingAway, "write failed")
+			}
+			return
+		}
+	}
+}
+
+func (c *client) write(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, c.hub.opts.WriteTimeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// close closes the connection once; the read loop then ends and ServeHTTP cleans up.
+func (c *client) close(code websocket.StatusCode, reason string) {
+	c.closeOnce.Do(func() {
+		c.conn.Close(code, reason)
+	})
+}
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// Handler serves a message type the hub does not know itself. It runs on the client's read
+// goroutine, so messages of one client are handled in order.
+type Handler func(conn Conn, msg Message)
+
+// Conn is a client connection as seen by handlers and close hooks.
+type Conn struct {
+	c *client
+}
+
+// ID returns the session ID of the connection, which a connection resuming a session keeps.
+func (conn Conn) ID() string {
+	return conn.c.id
+}
+
+// Principal returns the tenant and user the connection authenticated as.
+func (conn Conn) Principal() tenant.Principal {
+	return conn.c.principal
+}
+
+// Can reports whether the hub's Authorize function lets the connection perform a on topic.
+func (conn Conn) Can(a Action, topic string) bool {
+	return conn.c.hub.authorized(conn.c.principal, a, topic)
+}
+
+// Reply queues a response to req for the connection alone. It carries the topic and ID of req.
+func (conn Conn) Reply(req Message, msgType string, payload interface{}) {
+	conn.c.reply(req, msgType, payload)
+}
+
+// Handle registers fn for messages of msgType. Register handlers before serving clients.
+func (h *Hub) Handle(msgType string, fn Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[msgType] = fn
+}
+
+// OnClose registers fn to be called after a connection has ended and lost its subscriptions.
+func (h *Hub) OnClose(fn func(conn Conn)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closers = append(h.closers, fn)
+}
+
+// Observe calls fn with every message published to a topic matching pattern, on this node or
+// another. fn receives the encoded Message and must not block.
+func (h *Hub) Observe(pattern string, fn func(topic string, data []byte)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.observers = append(h.observers, observer{pattern: pattern, fn: fn})
+}
+
+type observer struct {
+	pattern string
+	fn      func(topic string, data []byte)
+}
+
+func (h *Hub) handler(msgType string) (Handler, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	fn, ok := h.handlers[msgType]
+	return fn, ok
+}
+
+func (h *Hub) closed(c *client) {
+	h.mu.RLock()
+	closers := h.closers
+	h.mu.RUnlock()
+	for _, fn := range closers {
+		fn(Conn{c})
+	}
+}
+
+func (h *Hub) observe(topic string, data []byte) {
+	h.mu.RLock()
+	observers := h.observers
+	h.mu.RUnlock()
+	for _, o := range observers {
+		if Match(o.pattern, topic) {
+			o.fn(topic, data)
+		}
+	}
+}
+
+func newConnID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
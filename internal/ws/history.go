@@ -0,0 +1,95 @@
+package ws
+
+import "container/list"
+
+// topicLog numbers the messages of one topic and keeps the most recent ones for replay.
+type topicLog struct {
+	topic string
+	seq   uint64
+	// ring holds the last messages, oldest first starting at start
+	ring  []logEntry
+	start int
+	count int
+	// retained is the last message published with Retain
+	retained *logEntry
+}
+
+type logEntry struct {
+	seq  uint64
+	data []byte
+}
+
+func (l *topicLog) push(e logEntry, retain bool) {
+	if retain {
+		l.retained = &e
+	}
+	if len(l.ring) == 0 {
+		return
+	}
+	l.ring[(l.start+l.count)%len(l.ring)] = e
+	if l.count < len(l.ring) {
+		l.count++
+	} else {
+		l.start = (l.start + 1) % len(l.ring)
+	}
+}
+
+// since returns the messages after seq and whether the log reaches back far enough to hold all
+// of them.
+func (l *topicLog) since(seq uint64) ([]logEntry, bool) {
+	if seq > l.seq {
+		return nil, false
+	}
+	var out []logEntry
+	for i := 0; i < l.count; i++ {
+		if e := l.ring[(l.start+i)%len(l.ring)]; e.seq > seq {
+			out = append(out, e)
+		}
+	}
+	return out, seq+uint64(len(out)) == l.seq
+}
+
+// history holds the logs of the most recently published topics. Logs beyond max are dropped
+// least recently published first, which restarts their numbering.
+type history struct {
+	size  int
+	max   int
+	order *list.List
+	logs  map[string]*list.Element
+}
+
+func newHistory(size, max int) *history {
+	return &history{size: size, max: max, order: list.New(), logs: map[string]*list.Element{}}
+}
+
+// log returns the log of topic, creating it when create is set.
+func (h *history) log(topic string, create bool) *topicLog {
+	if e, ok := h.logs[topic]; ok {
+		if create {
+			h.order.MoveToFront(e)
+		}
+		return e.Value.(*topicLog)
+	}
+	if !create {
+		return nil
+	}
+	l := &topicLog{topic: topic, ring: make([]logEntry, h.size)}
+	h.logs[topic] = h.order.PushFront(l)
+	for len(h.logs) > h.max {
+		oldest := h.order.Back()
+		h.order.Remove(oldest)
+		delete(h.logs, oldest.Value.(*topicLog).topic)
+	}
+	return l
+}
+
+// matching returns the logs of the topics matching pattern.
+func (h *history) matching(pattern string) []*topicLog {
+	var out []*topicLog
+	for e := h.order.Front(); e != nil; e = e.Next() {
+		if l := e.Value.(*topicLog); Match(pattern, l.topic) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
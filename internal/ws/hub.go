@@ -0,0 +1,294 @@
+// Package ws serves a publish/subscribe protocol over websockets. Clients are authenticated
+// before the upgrade, subscribe to topic patterns and may publish where the hub's Authorize
+// function allows. Every client has its own bounded outbound queue drained by a write goroutine,
+// so a slow client is disconnected instead of holding up publishers.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/backplane"
+	"github.com/wilbyang/law-docs/internal/logging"
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// Message types of the protocol. Clients send subscribe, unsubscribe, publish and ack, or a type
+// registered with Handle; the hub answers each request but ack with confirmation or error. It
+// greets every connection with welcome and delivers publish and reset messages to subscribers.
+const (
+	TypeSubscribe    = "subscribe"
+	TypeUnsubscribe  = "unsubscribe"
+	TypePublish      = "publish"
+	TypeAck          = "ack"
+	TypeConfirmation = "confirmation"
+	TypeError        = "error"
+	TypeWelcome      = "welcome"
+	TypeReset        = "reset"
+)
+
+// Message is the unit exchanged in both directions. For subscribe and unsubscribe Topic is a pattern.
+//
+// Every published message gets a Seq, numbering the messages of its topic from 1, and the Epoch of
+// the hub that numbered it; sequence numbers are only comparable within one epoch. A subscriber
+// that has seen a topic up to some Seq passes it in Resume to receive what it missed. When that
+// is no longer possible the hub sends reset with the topic's current Seq instead, and the client
+// reloads its state.
+type Message struct {
+	Type    string      `json:"type"`
+	Topic   string      `json:"topic"`
+	Payload interface{} `json:"payload,omitempty"`
+	// ID identifies a published message; the hub generates one unless the publisher sets it.
+	// Replies carry the ID of their request.
+	ID     string `json:"id,omitempty"`
+	Seq    uint64 `json:"seq,omitempty"`
+	Epoch  string `json:"epoch,omitempty"`
+	Retain bool   `json:"retain,omitempty"`
+	// Resume maps topics to the last Seq the subscriber has of them, in Epoch.
+	Resume map[string]uint64 `json:"resume,omitempty"`
+}
+
+// Action is what a client asks to do with a topic.
+type Action int
+
+const (
+	Subscribe Action = iota
+	Publish
+)
+
+// Options configures a Hub. Zero values select the defaults.
+type Options struct {
+	// Authorize decides whether p may subscribe to a pattern or publish to a topic. Nil denies
+	// every client request; the server can still publish through Hub.Publish.
+	Authorize func(p tenant.Principal, a Action, topic string) bool
+	// SendBuffer is the number of messages queued per client, 64 by default.
+	SendBuffer int
+	// WriteTimeout bounds every write to a client, 10s by default.
+	WriteTimeout time.Duration
+	// PingInterval is how often clients are pinged, 30s by default. A client that does not
+	// answer within WriteTimeout is disconnected.
+	PingInterval time.Duration
+	// OriginPatterns are the cross-origin hosts allowed to connect, see websocket.AcceptOptions.
+	OriginPatterns []string
+	// Backplane, when set, forwards publishes to the hubs of other nodes. Run must be running to
+	// receive theirs. Messages from other nodes are numbered in this hub's epoch.
+	Backplane backplane.Backplane
+	// Epoch names this hub's message numbering, a random ID by default.
+	Epoch string
+	// History is the number of messages kept per topic for resuming subscribers, 100 by default.
+	History int
+	// Topics bounds the number of topics with a history, 10000 by default. The least recently
+	// published topics are forgotten first.
+	Topics int
+	// SessionTTL is how long the subscriptions and acknowledged positions of a disconnected
+	// client are kept for it to resume, 2 minutes by default.
+	SessionTTL time.Duration
+}
+
+// Hub maintains the set of active clients and routes messages to their subscriptions.
+type Hub struct {
+	opts Options
+
+	// logMu serializes publishes with each other and with the replays of new subscriptions, so
+	// every client sees the messages of a topic in Seq order. It is taken before mu.
+	logMu   sync.Mutex
+	history *history
+
+	sessionMu sync.Mutex
+	sessions  map[string]*session
+
+	mu sync.RWMutex
+	// subscribers holds the clients of each subscribed pattern
+	subscribers map[string]map[*client]struct{}
+	handlers    map[string]Handler
+	closers     []func(Conn)
+	observers   []observer
+
+	log *slog.Logger
+}
+
+// NewHub creates a Hub without clients.
+func NewHub(opts Options) *Hub {
+	if opts.SendBuffer <= 0 {
+		opts.SendBuffer = 64
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+	if opts.Epoch == "" {
+		opts.Epoch = newConnID()
+	}
+	if opts.History <= 0 {
+		opts.History = 100
+	}
+	if opts.Topics <= 0 {
+		opts.Topics = 10000
+	}
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = 2 * time.Minute
+	}
+	return &Hub{
+		opts:        opts,
+		history:     newHistory(opts.History, opts.Topics),
+		sessions:    map[string]*session{},
+		subscribers: map[string]map[*client]struct{}{},
+		handlers:    map[string]Handler{},
+		log:         logging.Component("ws"),
+	}
+}
+
+// Publish sends payload to the subscribers of topic on behalf of the server. It never blocks on
+// clients; forwarding to other nodes is bounded by WriteTimeout.
+func (h *Hub) Publish(topic string, payload interface{}) {
+	h.serverPublish(Message{Topic: topic, Payload: payload}, true)
+}
+
+// PublishRetained is Publish for a topic's last value, such as a document's status, which is
+// also sent to every later subscriber of the topic.
+func (h *Hub) PublishRetained(topic string, payload interface{}) {
+	h.serverPublish(Message{Topic: topic, Payload: payload, Retain: true}, true)
+}
+
+// PublishLocal is PublishRetained, or Publish unless retain is set, for events that every node
+// learns about by itself, such as Postgres notifications; it does not forward them.
+func (h *Hub) PublishLocal(topic string, payload interface{}, retain bool) {
+	h.serverPublish(Message{Topic: topic, Payload: payload, Retain: retain}, false)
+}
+
+func (h *Hub) serverPublish(msg Message, forward bool) {
+	if err := validTopic(msg.Topic); err != nil {
+		h.log.Error("Refusing to publish", "topic", msg.Topic, "error", err)
+		return
+	}
+	h.publish(msg, forward)
+}
+
+// publish numbers msg, queues it for every local client with a matching subscription and, if
+// forward is set, sends it to the other nodes. It returns the numbered message.
+func (h *Hub) publish(msg Message, forward bool) (Message, error) {
+	msg.Type = TypePublish
+	msg.Resume = nil
+	if msg.ID == "" {
+		msg.ID = newConnID()
+	}
+	// other nodes number the message themselves
+	remote, err := json.Marshal(msg)
+	if err != nil {
+		h.log.Error("Failed to encode message", "topic", msg.Topic, "error", err)
+		return msg, err
+	}
+
+	h.logMu.Lock()
+	log := h.history.log(msg.Topic, true)
+	log.seq++
+	msg.Seq, msg.Epoch = log.seq, h.opts.Epoch
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.seq--
+		h.logMu.Unlock()
+		return msg, err
+	}
+	log.push(logEntry{seq: msg.Seq, data: data}, msg.Retain)
+	h.deliver(msg.Topic, data)
+	h.logMu.Unlock()
+
+	if forward && h.opts.Backplane != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), h.opts.WriteTimeout)
+		defer cancel()
+		if err := h.opts.Backplane.Publish(ctx, msg.Topic, remote); err != nil {
+			h.log.Error("Failed to forward message to other nodes", "topic", msg.Topic, "error", err)
+		}
+	}
+	return msg, nil
+}
+
+// deliver must be called with logMu held.
+func (h *Hub) deliver(topic string, data []byte) {
+	h.observe(topic, data)
+	for _, c := range h.recipients(topic) {
+		c.send(data)
+	}
+}
+
+// Run delivers the messages published on other nodes until ctx is cancelled. Without a
+// Backplane it only waits for ctx.
+func (h *Hub) Run(ctx context.Context) error {
+	if h.opts.Backplane == nil {
+		<-ctx.Done()
+		return nil
+	}
+	return h.opts.Backplane.Run(ctx, func(topic string, data []byte) {
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Topic != topic {
+			h.log.WarnContext(ctx, "Ignoring undecodable message from another node", "topic", topic, "error", err)
+			return
+		}
+		h.publish(msg, false)
+	})
+}
+
+func (h *Hub) recipients(topic string) []*client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var out []*client
+	seen := map[*client]bool{}
+	for pattern, clients := range h.subscribers {
+		if !Match(pattern, topic) {
+			continue
+		}
+		for c := range clients {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+func (h *Hub) subscribe(pattern string, c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[pattern] == nil {
+		h.subscribers[pattern] = map[*client]struct{}{}
+	}
+	h.subscribers[pattern][c] = struct{}{}
+	c.patterns[pattern] = struct{}{}
+}
+
+func (h *Hub) unsubscribe(pattern string, c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(pattern, c)
+}
+
+// unsubscribeAll drops every subscription of c. The client's own pattern set is only touched with
+// h.mu held, so this cannot race with subscribe.
+func (h *Hub) unsubscribeAll(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for pattern := range c.patterns {
+		h.remove(pattern, c)
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(pattern string, c *client) {
+	delete(c.patterns, pattern)
+	if clients, ok := h.subscribers[pattern]; ok {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.subscribers, pattern)
+		}
+	}
+}
+
+func (h *Hub) authorized(p tenant.Principal, a Action, topic string) bool {
+	return h.opts.Authorize != nil && h.opts.Authorize(p, a, topic)
+}
@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Members tracks which topics this node's connections have joined through a handler, such as the
+// documents of presence or the rooms of signaling. Handlers record joins and leaves, and on close
+// take the connection's topics with LeaveAll to announce that it left them.
+type Members struct {
+	mu sync.Mutex
+	// conns holds the connection of each session by topic
+	conns map[string]map[string]Conn
+}
+
+// NewMembers creates an empty Members.
+func NewMembers() *Members {
+	return &Members{conns: map[string]map[string]Conn{}}
+}
+
+// Join records that conn joined topic and reports whether it had not before.
+func (m *Members) Join(conn Conn, topic string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	topics := m.conns[conn.ID()]
+	if topics == nil {
+		topics = map[string]Conn{}
+		m.conns[conn.ID()] = topics
+	}
+	_, joined := topics[topic]
+	topics[topic] = conn
+	return !joined
+}
+
+// Leave records that session left topic and reports whether it had joined it.
+func (m *Members) Leave(session, topic string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, joined := m.conns[session][topic]
+	delete(m.conns[session], topic)
+	if len(m.conns[session]) == 0 {
+		delete(m.conns, session)
+	}
+	return joined
+}
+
+// LeaveAll records that session left every topic and returns them.
+func (m *Members) LeaveAll(session string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	topics := make([]string, 0, len(m.conns[session]))
+	for topic := range m.conns[session] {
+		topics = append(topics, topic)
+	}
+	delete(m.conns, session)
+	return topics
+}
+
+// Conn returns the connection of session if it has joined topic.
+func (m *Members) Conn(session, topic string) (Conn, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conn, ok := m.conns[session][topic]
+	return conn, ok
+}
+
+// Topic returns the connections that have joined topic.
+func (m *Members) Topic(topic string) []Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Conn
+	for _, topics := range m.conns {
+		if conn, ok := topics[topic]; ok {
+			out = append(out, conn)
+		}
+	}
+	return out
+}
+
+// Topics returns every topic joined by a connection, with the connections that joined it.
+func (m *Members) Topics() map[string][]Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string][]Conn{}
+	for _, topics := range m.conns {
+		for topic, conn := range topics {
+			out[topic] = append(out[topic], conn)
+		}
+	}
+	return out
+}
+
+// Decode converts the decoded JSON payload of a message into v.
+func Decode(payload interface{}, v interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,147 @@
+package ws
+
+import (
+	"slices"
+	"time"
+
+	"github.com/wilbyang/law-docs/internal/tenant"
+)
+
+// session is what the hub keeps of a disconnected client so that it can reconnect with
+// ?session=<id> and have its subscriptions restored. Messages it had not acknowledged are
+// delivered again, so clients must tolerate duplicates; Message.ID identifies them.
+type session struct {
+	principal tenant.Principal
+	patterns  []string
+	// acked holds the position of each topic the client has acknowledged or started from
+	acked   map[string]uint64
+	expires time.Time
+}
+
+// welcome is the payload of the welcome message. It names the tenant and user the client
+// authenticated as, so that it can build its topics. Topics lists the restored subscriptions.
+type welcome struct {
+	TenantID int32    `json:"tenant_id"`
+	UserID   int32    `json:"user_id"`
+	Resumed  bool     `json:"resumed"`
+	Topics   []string `json:"topics,omitempty"`
+}
+
+// saveSession keeps the session of a closed client, and forgets expired ones.
+func (h *Hub) saveSession(c *client, patterns []string) {
+	now := time.Now()
+	h.sessionMu.Lock()
+	defer h.sessionMu.Unlock()
+	for id, s := range h.sessions {
+		if now.After(s.expires) {
+			delete(h.sessions, id)
+		}
+	}
+	if len(patterns) == 0 {
+		return
+	}
+	h.sessions[c.id] = &session{
+		principal: c.principal,
+		patterns:  patterns,
+		acked:     c.acked,
+		expires:   now.Add(h.opts.SessionTTL),
+	}
+}
+
+// takeSession removes and returns the session id if it belongs to p and has not expired.
+func (h *Hub) takeSession(id string, p tenant.Principal) *session {
+	if id == "" {
+		return nil
+	}
+	h.sessionMu.Lock()
+	defer h.sessionMu.Unlock()
+	s, ok := h.sessions[id]
+	if !ok || s.principal != p || time.Now().After(s.expires) {
+		return nil
+	}
+	delete(h.sessions, id)
+	return s
+}
+
+// subscribeReplay subscribes c to req.Topic and queues what it is owed of every matching topic
+// with a history: the messages after req.Resume, or a reset when they are gone or numbered in
+// another epoch, or else the retained message. When restoring a session, topics missing from
+// Resume are new since the client subscribed and are replayed in full. Replies unless restoring.
+func (h *Hub) subscribeReplay(c *client, req Message, restoring bool) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	h.subscribe(req.Topic, c)
+	if !restoring {
+		c.sendMessage(Message{Type: TypeConfirmation, Topic: req.Topic, ID: req.ID, Epoch: h.opts.Epoch, Payload: "Subscribed successfully"})
+	}
+
+	epoch := req.Epoch == h.opts.Epoch
+	// replays beyond the free space of the queue would only get the client disconnected
+	budget := cap(c.queue) - len(c.queue)
+	for _, log := range h.history.matching(req.Topic) {
+		seq, resume := req.Resume[log.topic]
+		if !resume && restoring {
+			seq, resume = 0, true
+		}
+		var replay []logEntry
+		reset := false
+		switch {
+		case resume && epoch:
+			missed, complete := log.since(seq)
+			if complete && len(missed) <= budget {
+				replay = missed
+				c.acked[log.topic] = seq
+			} else {
+				reset = true
+			}
+		case resume:
+			reset = true
+		default:
+			if log.retained != nil {
+				replay = []logEntry{*log.retained}
+			}
+			c.acked[log.topic] = log.seq
+		}
+		if reset {
+			c.sendMessage(Message{Type: TypeReset, Topic: log.topic, Seq: log.seq, Epoch: h.opts.Epoch})
+			if log.retained != nil {
+				replay = []logEntry{*log.retained}
+			}
+			c.acked[log.topic] = log.seq
+		}
+		for _, e := range replay {
+			c.send(e.data)
+		}
+		budget -= len(replay) + 1
+	}
+	// topics the client knows that this hub has no history of
+	for topic := range req.Resume {
+		if Match(req.Topic, topic) && h.history.log(topic, false) == nil && !restoring {
+			c.sendMessage(Message{Type: TypeReset, Topic: topic, Epoch: h.opts.Epoch})
+		}
+	}
+}
+
+// patternList returns the patterns c is subscribed to.
+func (h *Hub) patternList(c *client) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]string, 0, len(c.patterns))
+	for p := range c.patterns {
+		out = append(out, p)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// subscribed reports whether c has a subscription matching topic.
+func (h *Hub) subscribed(c *client, topic string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for p := range c.patterns {
+		if Match(p, topic) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package ws_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/wilbyang/law-docs/internal/tenant"
+	"github.com/wilbyang/law-docs/internal/ws"
+)
+
+const topic = "tenants.7.docs.42.status"
+
+// serve starts hub and returns its websocket URL. Clients authenticate as user 1 of tenant 7,
+// or as the user of the user query parameter.
+func serve(t *testing.T, hub *ws.Hub) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := tenant.Principal{TenantID: 7, UserID: 1}
+		if user, err := strconv.Atoi(r.URL.Query().Get("user")); err == nil {
+			p.UserID = int32(user)
+		}
+		hub.ServeHTTP(w, r.WithContext(tenant.WithPrincipal(r.Context(), p)))
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func testHub(history int) *ws.Hub {
+	return ws.NewHub(ws.Options{
+		Authorize: func(tenant.Principal, ws.Action, string) bool { return true },
+		Epoch:     "e1",
+		History:   history,
+	})
+}
+
+// dial connects to url and returns the connection with its welcome.
+func dial(t *testing.T, ctx context.Context, url string) (*websocket.Conn, ws.Message) {
+	t.Helper()
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	var welcome ws.Message
+	if err := wsjson.Read(ctx, conn, &welcome); err != nil || welcome.Type != ws.TypeWelcome {
+		t.Fatalf("got %+v (%v), want a welcome", welcome, err)
+	}
+	return conn, welcome
+}
+
+// read returns what conn was sent until now, as the type of each message and, for publishes and
+// resets, its seq. The hub answers requests in order, so everything queued for the client comes
+// before the reply to an unsubscribe request sent as a marker.
+func read(t *testing.T, ctx context.Context, conn *websocket.Conn) []string {
+	t.Helper()
+	if err := wsjson.Write(ctx, conn, ws.Message{Type: ws.TypeUnsubscribe, Topic: "marker"}); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		var msg ws.Message
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Topic == "marker":
+			return got
+		case msg.Type == ws.TypePublish, msg.Type == ws.TypeReset:
+			got = append(got, msg.Type+":"+strconv.FormatUint(msg.Seq, 10))
+		default:
+			got = append(got, msg.Type)
+		}
+	}
+}
+
+func TestSubscribeResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	hub := testHub(3)
+	url := serve(t, hub)
+	for i := range 5 {
+		hub.Publish(topic, i)
+	}
+	// 1 to 5 were published, 3 to 5 are in the history
+
+	for _, tc := range []struct {
+		name    string
+		pattern string
+		epoch   string
+		resume  map[string]uint64
+		want    []string
+	}{
+		{"new", topic, "", nil, []string{"confirmation"}},
+		{"missed some", topic, "e1", map[string]uint64{topic: 3}, []string{"confirmation", "publish:4", "publish:5"}},
+		{"up to date", topic, "e1", map[string]uint64{topic: 5}, []string{"confirmation"}},
+		{"through a pattern", "tenants.7.docs.>", "e1", map[string]uint64{topic: 4}, []string{"confirmation", "publish:5"}},
+		{"history too short", topic, "e1", map[string]uint64{topic: 1}, []string{"confirmation", "reset:5"}},
+		{"another epoch", topic, "e0", map[string]uint64{topic: 4}, []string{"confirmation", "reset:5"}},
+		{"ahead of the hub", topic, "e1", map[string]uint64{topic: 9}, []string{"confirmation", "reset:5"}},
+		{"unknown topic", "tenants.7.docs.>", "e1", map[string]uint64{"tenants.7.docs.43.status": 2}, []string{"confirmation", "reset:0"}},
+	} {
+		conn, _ := dial(t, ctx, url)
+		req := ws.Message{Type: ws.TypeSubscribe, Topic: tc.pattern, Epoch: tc.epoch, Resume: tc.resume}
+		if err := wsjson.Write(ctx, conn, req); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(t, ctx, conn); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	hub := testHub(0)
+	closed := make(chan struct{}, 1)
+	hub.OnClose(func(ws.Conn) { closed <- struct{}{} })
+	url := serve(t, hub)
+
+	conn, welcome := dial(t, ctx, url)
+	session := welcome.ID
+	if err := wsjson.Write(ctx, conn, ws.Message{Type: ws.TypeSubscribe, Topic: topic}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, ctx, conn); !slices.Equal(got, []string{"confirmation"}) {
+		t.Fatalf("got %v, want a confirmation", got)
+	}
+	for i := range 3 {
+		hub.Publish(topic, i)
+	}
+	if got := read(t, ctx, conn); !slices.Equal(got, []string{"publish:1", "publish:2", "publish:3"}) {
+		t.Fatalf("got %v", got)
+	}
+	// an older ack does not move the position back
+	for _, ack := range []ws.Message{
+		{Type: ws.TypeAck, Topic: topic, Seq: 2},
+		{Type: ws.TypeAck, Topic: topic, Seq: 1},
+	} {
+		if err := wsjson.Write(ctx, conn, ack); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+	<-closed
+	hub.Publish(topic, 3)
+
+	// the session belongs to the user that opened it
+	other, welcome := dial(t, ctx, url+"?user=2&session="+session)
+	if welcome.ID == session || read(t, ctx, other) != nil {
+		t.Errorf("user 2 resumed the session of user 1: %+v", welcome)
+	}
+	other.Close(websocket.StatusNormalClosure, "")
+	<-closed
+
+	conn, welcome = dial(t, ctx, url+"?session="+session)
+	var greeting struct {
+		Resumed bool     `json:"resumed"`
+		Topics  []string `json:"topics"`
+	}
+	if err := ws.Decode(welcome.Payload, &greeting); err != nil {
+		t.Fatal(err)
+	}
+	if welcome.ID != session || !greeting.Resumed || !slices.Equal(greeting.Topics, []string{topic}) {
+		t.Errorf("got welcome %+v, want the session restored", welcome)
+	}
+	// what was not acknowledged is delivered again, without a confirmation
+	if got := read(t, ctx, conn); !slices.Equal(got, []string{"publish:3", "publish:4"}) {
+		t.Errorf("got %v, want the messages after the last ack", got)
+	}
+}
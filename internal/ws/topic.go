@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"errors"
+	"strings"
+)
+
+// Topics are dot-separated, e.g. "tenants.1.docs.42.status". In subscription patterns "*" matches
+// exactly one segment and ">" as the last segment matches one or more remaining segments, so
+// "tenants.1.docs.*.status" and "tenants.1.docs.>" both match that topic.
+const (
+	wildcardOne  = "*"
+	wildcardRest = ">"
+)
+
+var (
+	errBadTopic   = errors.New("topic must be non-empty dot-separated segments without wildcards")
+	errBadPattern = errors.New("pattern must be non-empty dot-separated segments, with > only as the last one")
+)
+
+// validTopic reports whether topic can be published to.
+func validTopic(topic string) error {
+	for _, s := range strings.Split(topic, ".") {
+		if s == "" || s == wildcardOne || s == wildcardRest {
+			return errBadTopic
+		}
+	}
+	return nil
+}
+
+// validPattern reports whether pattern can be subscribed to.
+func validPattern(pattern string) error {
+	segments := strings.Split(pattern, ".")
+	for i, s := range segments {
+		if s == "" || s == wildcardRest && i != len(segments)-1 {
+			return errBadPattern
+		}
+	}
+	return nil
+}
+
+// Match reports whether topic matches pattern.
+func Match(pattern, topic string) bool {
+	for {
+		p, pRest, pMore := strings.Cut(pattern, ".")
+		t, tRest, tMore := strings.Cut(topic, ".")
+		if p == wildcardRest {
+			return true
+		}
+		if p != wildcardOne && p != t {
+			return false
+		}
+		if !pMore || !tMore {
+			return pMore == tMore
+		}
+		pattern, topic = pRest, tRest
+	}
+}
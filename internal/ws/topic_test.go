@@ -0,0 +1,66 @@
+package ws
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, topic string
+		match          bool
+	}{
+		{"tenants.1.docs.42.status", "tenants.1.docs.42.status", true},
+		{"tenants.1.docs.42.status", "tenants.1.docs.43.status", false},
+		{"tenants.1.docs.*.status", "tenants.1.docs.42.status", true},
+		{"tenants.1.docs.*.status", "tenants.1.docs.42.comments", false},
+		{"tenants.1.docs.*", "tenants.1.docs.42.status", false},
+		{"tenants.1.docs.>", "tenants.1.docs.42", true},
+		{"tenants.1.docs.>", "tenants.1.docs.42.status", true},
+		// > matches at least one segment
+		{"tenants.1.docs.>", "tenants.1.docs", false},
+		{"tenants.*.>", "tenants.2.docs.42", true},
+		// patterns and topics are compared by whole segments
+		{"tenants.1.docs", "tenants.1.docs.42", false},
+		{"tenants.1.docs.42", "tenants.1.docs", false},
+		{"tenants.1", "tenants.10", false},
+		{"tenants.1.*", "tenants.1", false},
+		{">", "tenants", true},
+	} {
+		if got := Match(tc.pattern, tc.topic); got != tc.match {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.pattern, tc.topic, got, tc.match)
+		}
+	}
+}
+
+func TestValidTopic(t *testing.T) {
+	for topic, valid := range map[string]bool{
+		"tenants.1.channels.general": true,
+		"tenants":                    true,
+		"":                           false,
+		"tenants..channels":          false,
+		"tenants.1.":                 false,
+		".tenants":                   false,
+		"tenants.*.channels.general": false,
+		"tenants.1.channels.>":       false,
+	} {
+		if err := validTopic(topic); (err == nil) != valid {
+			t.Errorf("validTopic(%q) = %v, want valid %v", topic, err, valid)
+		}
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for pattern, valid := range map[string]bool{
+		"tenants.1.docs.42.status": true,
+		"tenants.1.docs.*.status":  true,
+		"tenants.1.docs.>":         true,
+		">":                        true,
+		"":                         false,
+		"tenants..docs":            false,
+		"tenants.1.docs.":          false,
+		"tenants.>.docs":           false,
+		"tenants.1.>.>":            false,
+	} {
+		if err := validPattern(pattern); (err == nil) != valid {
+			t.Errorf("validPattern(%q) = %v, want valid %v", pattern, err, valid)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package client is a typed Go client for the law-docs HTTP API.
+//
+// client.gen.go is generated from docs/openapi.json by `go generate ./docs`; do not edit it by hand.
+//
+//	c, err := client.NewClientWithResponses("https://lawdocs.example.com", client.WithBearerToken(token))
+//	resp, err := c.ListDocumentsWithResponse(ctx, &client.ListDocumentsParams{})
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// WithBearerToken authenticates every request with the server's API token.
+func WithBearerToken(token string) ClientOption {
+	return WithRequestEditorFn(func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
@@ -8,7 +8,9 @@ INSERT INTO documents (
     meta,
     status,
     author_id,
-    file_path
+    file_path,
+    matter_id,
+    folder_id
 ) VALUES (
     $1,
     $2,
@@ -18,13 +20,285 @@ INSERT INTO documents (
     $6,
     $7,
     $8,
-    $9
+    $9,
+    $10,
+    $11
 ) RETURNING *;
 
 -- name: GetDocumentById :one
 SELECT * FROM documents WHERE id = $1;
+-- name: GetDocumentByIdForUpdate :one
+SELECT * FROM documents WHERE id = $1 FOR UPDATE;
 -- name: GetDocuments :many
 SELECT * FROM documents WHERE author_id = $1;
 
 -- name: UpdateDocument :one
-UPDATE documents SET title = $2, content = $3, doc_size = $4, updated_at = $5, meta = $6, status = $7, file_path = $8 WHERE id = $1 RETURNING *;
\ No newline at end of file
+UPDATE documents SET title = $2, content = $3, doc_size = $4, updated_at = $5, meta = $6, status = $7, file_path = $8 WHERE id = $1 RETURNING *;
+
+-- name: CountDocumentsByStatus :many
+SELECT status, count(*) FROM documents GROUP BY status;
+
+-- name: CountDocumentsWithFile :one
+SELECT count(*) FROM documents WHERE file_path = $1;
+
+-- name: ListDocumentChanges :many
+SELECT id, tenant_id, title, status, created_at, updated_at FROM documents
+WHERE (updated_at, id) > ($1::timestamp, $2::int)
+ORDER BY updated_at, id
+LIMIT $3;
+
+-- name: ListStaleDocuments :many
+SELECT id, tenant_id, title, updated_at FROM documents
+WHERE status = $1 AND updated_at < $2
+ORDER BY updated_at
+LIMIT $3;
+
+-- name: DeleteDocument :one
+DELETE FROM documents WHERE id = $1 RETURNING *;
+
+-- name: ListDocuments :many
+SELECT * FROM documents ORDER BY id;
+
+-- name: CreateTenant :one
+INSERT INTO tenants (name) VALUES ($1) RETURNING *;
+
+-- name: CreateUser :one
+INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (tenant_id, user_id, name, token_hash) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAPIToken :exec
+UPDATE api_tokens SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(1635083369, sqlc.arg(tenant_id)::int);
+
+-- name: GetLastAuditEvent :one
+SELECT * FROM audit_events WHERE tenant_id = $1 ORDER BY id DESC LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+    tenant_id,
+    occurred_at,
+    actor_id,
+    action,
+    document_id,
+    ip,
+    user_agent,
+    request_id,
+    before_hash,
+    after_hash,
+    prev_hash,
+    hash
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE tenant_id = sqlc.arg(tenant_id)
+  AND id > sqlc.arg(after_id)
+  AND (sqlc.narg(document_id)::int IS NULL OR document_id = sqlc.narg(document_id))
+  AND (sqlc.narg(actor_id)::int IS NULL OR actor_id = sqlc.narg(actor_id))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR occurred_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL OR occurred_at < sqlc.narg(until))
+ORDER BY id
+LIMIT sqlc.arg(max_rows);
+
+-- name: SearchDocuments :many
+SELECT * FROM documents
+WHERE (sqlc.narg(author_id)::int IS NULL OR author_id = sqlc.narg(author_id))
+  AND (sqlc.narg(matter_id)::int IS NULL OR matter_id = sqlc.narg(matter_id))
+  AND (sqlc.narg(folder_id)::int IS NULL OR folder_id = sqlc.narg(folder_id))
+  AND (sqlc.narg(title)::text IS NULL OR title ILIKE '%' || sqlc.narg(title) || '%')
+  AND (cardinality(sqlc.arg(tags)::text[]) = 0 OR id IN (
+      SELECT dt.document_id FROM document_tags dt JOIN tags t ON t.id = dt.tag_id
+      WHERE t.name = ANY(sqlc.arg(tags)::text[])
+      GROUP BY dt.document_id
+      HAVING count(*) = cardinality(sqlc.arg(tags)::text[])))
+ORDER BY id;
+
+-- name: ListDocumentsByID :many
+SELECT * FROM documents WHERE id = ANY(sqlc.arg(ids)::int[]) ORDER BY id;
+
+-- name: MoveDocuments :many
+UPDATE documents SET matter_id = sqlc.narg(matter_id), folder_id = sqlc.narg(folder_id), updated_at = sqlc.arg(updated_at)
+WHERE id = ANY(sqlc.arg(ids)::int[])
+RETURNING *;
+
+-- name: CreateMatter :one
+INSERT INTO matters (name, reference, description) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetMatter :one
+SELECT * FROM matters WHERE id = $1;
+
+-- name: ListMatters :many
+SELECT * FROM matters ORDER BY name;
+
+-- name: UpdateMatter :one
+UPDATE matters SET name = $2, reference = $3, description = $4, updated_at = $5 WHERE id = $1 RETURNING *;
+
+-- name: DeleteMatter :one
+DELETE FROM matters WHERE id = $1 RETURNING *;
+
+-- name: CreateFolder :one
+INSERT INTO folders (matter_id, parent_id, name) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetFolder :one
+SELECT * FROM folders WHERE id = $1;
+
+-- name: ListFolders :many
+SELECT * FROM folders WHERE matter_id = $1 ORDER BY parent_id NULLS FIRST, name;
+
+-- name: UpdateFolder :one
+UPDATE folders SET parent_id = $2, name = $3 WHERE id = $1 RETURNING *;
+
+-- name: DeleteFolder :one
+DELETE FROM folders WHERE id = $1 RETURNING *;
+
+-- name: IsFolderAncestor :one
+WITH RECURSIVE ancestors AS (
+    SELECT id, parent_id FROM folders WHERE id = sqlc.arg(folder_id)
+    UNION ALL
+    SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+)
+SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = sqlc.arg(ancestor_id));
+
+-- name: CreateTag :one
+INSERT INTO tags (name) VALUES ($1) RETURNING *;
+
+-- name: ListTags :many
+SELECT * FROM tags ORDER BY name;
+
+-- name: ListTagsByID :many
+SELECT * FROM tags WHERE id = ANY(sqlc.arg(ids)::int[]) ORDER BY id;
+
+-- name: UpdateTag :one
+UPDATE tags SET name = $2 WHERE id = $1 RETURNING *;
+
+-- name: DeleteTag :one
+DELETE FROM tags WHERE id = $1 RETURNING *;
+
+-- name: TagDocuments :execrows
+INSERT INTO document_tags (document_id, tag_id)
+SELECT d, t FROM unnest(sqlc.arg(document_ids)::int[]) AS d, unnest(sqlc.arg(tag_ids)::int[]) AS t
+ON CONFLICT DO NOTHING;
+
+-- name: UntagDocuments :execrows
+DELETE FROM document_tags
+WHERE document_id = ANY(sqlc.arg(document_ids)::int[]) AND tag_id = ANY(sqlc.arg(tag_ids)::int[]);
+
+-- name: ListDocumentTags :many
+SELECT dt.document_id, t.id, t.name FROM document_tags dt JOIN tags t ON t.id = dt.tag_id
+WHERE dt.document_id = ANY(sqlc.arg(document_ids)::int[])
+ORDER BY dt.document_id, t.name;
+
+-- name: CreateComment :one
+INSERT INTO comments (document_id, parent_id, author_id, body, anchor) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetComment :one
+SELECT * FROM comments WHERE id = $1;
+
+-- name: ListComments :many
+SELECT * FROM comments WHERE document_id = $1 ORDER BY id;
+
+-- name: SetCommentResolved :one
+UPDATE comments SET resolved_at = $2, resolved_by = $3 WHERE id = $1 AND parent_id IS NULL RETURNING *;
+
+-- name: CreateCommentMentions :exec
+INSERT INTO comment_mentions (comment_id, user_id)
+SELECT sqlc.arg(comment_id), unnest(sqlc.arg(user_ids)::int[]);
+
+-- name: ListCommentMentions :many
+SELECT * FROM comment_mentions WHERE comment_id = ANY(sqlc.arg(comment_ids)::int[]) ORDER BY comment_id, user_id;
+
+-- name: ListMentionedComments :many
+SELECT c.* FROM comments c JOIN comment_mentions m ON m.comment_id = c.id
+WHERE m.user_id = sqlc.arg(user_id)
+ORDER BY c.id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: ListUserIDs :many
+SELECT id FROM users WHERE id = ANY(sqlc.arg(ids)::int[]) ORDER BY id;
+
+-- name: AcquireDocumentLock :one
+INSERT INTO document_locks (document_id, user_id, acquired_at, expires_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (document_id, tenant_id) DO UPDATE
+SET user_id = excluded.user_id,
+    acquired_at = CASE WHEN document_locks.user_id = excluded.user_id AND document_locks.expires_at > excluded.acquired_at
+        THEN document_locks.acquired_at ELSE excluded.acquired_at END,
+    expires_at = excluded.expires_at
+WHERE document_locks.user_id = excluded.user_id OR document_locks.expires_at <= excluded.acquired_at
+RETURNING *;
+
+-- name: GetDocumentLock :one
+SELECT * FROM document_locks WHERE document_id = $1;
+
+-- name: ReleaseDocumentLock :execrows
+DELETE FROM document_locks WHERE document_id = $1 AND user_id = $2;
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (url, events, secret) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks ORDER BY id;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks SET url = $2, events = $3, secret = $4, enabled = $5, updated_at = $6,
+    disabled_at = CASE WHEN $5 THEN NULL ELSE coalesce(disabled_at, $6) END,
+    consecutive_failures = CASE WHEN $5 AND NOT enabled THEN 0 ELSE consecutive_failures END
+WHERE id = $1 RETURNING *;
+
+-- name: DeleteWebhook :one
+DELETE FROM webhooks WHERE id = $1 RETURNING *;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, event_type, event_key, payload, next_attempt_at)
+SELECT id, sqlc.arg(event_type), sqlc.arg(event_key), sqlc.arg(payload), sqlc.arg(next_attempt_at) FROM webhooks
+WHERE enabled AND events && sqlc.arg(events)::text[]
+ON CONFLICT (webhook_id, event_key) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d SET next_attempt_at = sqlc.arg(lease_until)
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT p.id FROM webhook_deliveries p JOIN webhooks pw ON pw.id = p.webhook_id
+    WHERE p.status = 'pending' AND p.next_attempt_at <= sqlc.arg(now) AND pw.enabled
+    ORDER BY p.next_attempt_at, p.id
+    LIMIT sqlc.arg(max_rows)
+    FOR UPDATE OF p SKIP LOCKED)
+RETURNING sqlc.embed(d), w.url, w.secret;
+
+-- name: RecordWebhookAttempt :one
+UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, response_status = $3, last_error = $4,
+    next_attempt_at = $5, delivered_at = $6
+WHERE id = $1 RETURNING *;
+
+-- name: ResetWebhookFailures :execrows
+UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0;
+
+-- name: RecordWebhookFailure :one
+UPDATE webhooks SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND consecutive_failures + 1 < sqlc.arg(disable_after),
+    disabled_at = CASE WHEN enabled AND consecutive_failures + 1 >= sqlc.arg(disable_after) THEN sqlc.arg(now) ELSE disabled_at END
+WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries WHERE webhook_id = $1 AND (sqlc.narg(before)::int IS NULL OR id < sqlc.narg(before))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: RedeliverWebhookDelivery :one
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $3, delivered_at = NULL
+WHERE id = $1 AND webhook_id = $2 RETURNING *;